@@ -1,9 +1,16 @@
 package main
 
 import (
+	"crypto/rsa"
 	"fmt"
+	"lynkly-backend/internal/apikeys"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/config"
 	"lynkly-backend/internal/logging"
 	"lynkly-backend/internal/servers"
+	"lynkly-backend/internal/sessions"
+	"lynkly-backend/internal/users"
+	"lynkly-backend/internal/workspaces"
 )
 
 const (
@@ -19,9 +26,112 @@ func main() {
 	logger.Info(fmt.Sprintf("Starting %s...", serviceName))
 	logger.Debug("Debug main.go")
 
+	authConfig := config.NewAuthConfig()
+	jwtParams := auth.JWTParams{
+		HMACSecret: []byte(authConfig.JWTSecret),
+		Issuer:     authConfig.JWTIssuer,
+		Audience:   authConfig.JWTAudience,
+		Leeway:     authConfig.JWTLeeway,
+	}
+	issuerParams := auth.TokenIssuerParams{
+		HMACSecret: []byte(authConfig.JWTSecret),
+		Issuer:     authConfig.JWTIssuer,
+		Audience:   authConfig.JWTAudience,
+		TTL:        authConfig.AccessTokenTTL,
+	}
+	if authConfig.JWTPublicKeyFile != "" {
+		publicKey, err := auth.LoadRSAPublicKey(authConfig.JWTPublicKeyFile)
+		if err != nil {
+			logger.Panic("Error encountered on loading the JWT public key", "error", err)
+		}
+		jwtParams.RSAPublicKey = publicKey
+	}
+	// the tokens are signed with an RSA key unless only a secret is configured
+	var signingKeys *auth.KeyRing
+	if authConfig.JWTPrivateKeyFile != "" || authConfig.JWTSecret == "" {
+		var privateKeys []*rsa.PrivateKey
+		keyFiles := authConfig.JWTPreviousKeyFiles
+		if authConfig.JWTPrivateKeyFile != "" {
+			keyFiles = append([]string{authConfig.JWTPrivateKeyFile}, keyFiles...)
+		} else {
+			logger.Warn("JWT_PRIVATE_KEY_FILE is not set, a signing key is generated and user tokens will not be valid after a restart")
+		}
+		for _, keyFile := range keyFiles {
+			privateKey, err := auth.LoadRSAPrivateKey(keyFile)
+			if err != nil {
+				logger.Panic("Error encountered on loading the JWT private key", "error", err)
+			}
+			privateKeys = append(privateKeys, privateKey)
+		}
+		if authConfig.JWTKeyRotationInterval > 0 && authConfig.JWTKeyRotationOverlap < authConfig.AccessTokenTTL+authConfig.JWTLeeway {
+			logger.Warn("JWT_KEY_ROTATION_OVERLAP is shorter than the lifetime of the access tokens, tokens will be rejected after a rotation")
+		}
+
+		var err error
+		signingKeys, err = auth.NewKeyRing(auth.KeyRingParams{
+			Logger:           logger,
+			Keys:             privateKeys,
+			RotationInterval: authConfig.JWTKeyRotationInterval,
+			Overlap:          authConfig.JWTKeyRotationOverlap,
+		})
+		if err != nil {
+			logger.Panic("Error encountered on creating the JWT signing keys", "error", err)
+		}
+		issuerParams.Keys = signingKeys
+		jwtParams.Keys = signingKeys
+	}
+	tokenIssuer := auth.NewTokenIssuer(issuerParams)
+
+	sessionsConfig := config.NewSessionsConfig()
+	userSessions := sessions.New(sessions.Params{
+		Store: sessions.NewMemoryStore(),
+		Revocations: sessions.NewRevocationList(sessions.RevocationListParams{
+			Store:    sessions.NewMemoryRevocationStore(),
+			CacheTTL: sessionsConfig.RevocationCacheTTL,
+		}),
+		RefreshTokenTTL: sessionsConfig.RefreshTokenTTL,
+		AccessTokenTTL:  authConfig.AccessTokenTTL,
+	})
+
+	apiKeys := apikeys.New(apikeys.Params{Store: apikeys.NewMemoryStore()})
+	authenticator := auth.NewAuthenticator(auth.Params{
+		JWT:      auth.NewJWTAuthenticator(jwtParams),
+		APIKeys:  apiKeys,
+		Sessions: userSessions,
+	})
+
 	server := servers.NewUrlShortenerServer(fmt.Sprintf("127.0.0.1:%d", 18080), servers.ServerParams{
-		Logger:     logger,
-		ServiceUrl: "http://127.0.0.1:18080",
+		Logger:          logger,
+		ServiceUrl:      "http://127.0.0.1:18080",
+		Authenticator:   authenticator,
+		APIKeys:         apiKeys,
+		Users:           users.New(users.Params{Store: users.NewMemoryStore()}),
+		TokenIssuer:     tokenIssuer,
+		SigningKeys:     signingKeys,
+		Sessions:        userSessions,
+		MFA:             config.NewMFAConfig(),
+		CORS:            config.NewCORSConfig(),
+		SecurityHeaders: config.NewSecurityHeadersConfig(),
+		TLS:             config.NewTLSConfig(),
+		ACME:            config.NewACMEConfig(),
+		CustomDomains:   config.NewCustomDomainsConfig(),
+		RateLimit:       config.NewRateLimitConfig(),
+		LiveStream:      config.NewLiveStreamConfig(),
+		Analytics:       config.NewAnalyticsConfig(),
+		Privacy:         config.NewPrivacyConfig(),
+		Links:           config.NewLinksConfig(),
+		Destinations:    config.NewDestinationsConfig(),
+		Shorteners:      config.NewShortenersConfig(),
+		Threats:         config.NewThreatsConfig(),
+		Moderation:      config.NewModerationConfig(),
+		Webhooks:        config.NewWebhooksConfig(),
+		Conversions:     config.NewConversionsConfig(),
+		Challenges:      config.NewChallengesConfig(),
+		OIDC:            config.NewOIDCConfig(),
+		Workspaces: workspaces.New(workspaces.Params{
+			Store:         workspaces.NewMemoryStore(),
+			InvitationTTL: config.NewWorkspacesConfig().InvitationTTL,
+		}),
 	})
 
 	//// Start server
@@ -29,9 +139,4 @@ func main() {
 	if err != nil {
 		logger.Panic("Error encountered on running the server", "error", err)
 	}
-
-	//
-	//// Start server
-	//log.Printf("Server listening on port %s", serverConfig.Port)
-	//log.Fatal(http.ListenAndServeTLS(":"+mongoConfig.ServerPort, mongoConfig.TLSCertFile, mongoConfig.TLSKeyFile, server.Router))
 }
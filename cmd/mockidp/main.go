@@ -0,0 +1,230 @@
+// Command mockidp is a minimal OpenID Connect identity provider for trying out the single sign-on locally.
+// It approves every authorization request without asking for credentials and logs in the user set in
+// MOCK_IDP_EMAIL with the comma separated groups of MOCK_IDP_GROUPS, e.g.
+//
+//	MOCK_IDP_EMAIL=jane@example.com MOCK_IDP_GROUPS=admins go run ./cmd/mockidp
+//	OIDC_ISSUER_URL=http://127.0.0.1:9999 OIDC_CLIENT_ID=lynkly \
+//		OIDC_REDIRECT_URL=http://127.0.0.1:18080/api/v1/auth/oidc/callback \
+//		OIDC_ROLE_MAPPING=admins=admin go run ./cmd/lynkly
+//
+// and open http://127.0.0.1:18080/api/v1/auth/oidc/login in a browser. It must never be exposed
+// outside of a development machine.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	jsoniter "github.com/json-iterator/go"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/logging"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	serviceName  = "mockidp"
+	codeTTL      = time.Minute
+	idTokenTTL   = 5 * time.Minute
+	defaultAddr  = "127.0.0.1:9999"
+	defaultEmail = "jane@example.com"
+)
+
+type authorization struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	email       string
+	groups      []string
+	expiresAt   time.Time
+}
+
+type provider struct {
+	issuer string
+	email  string
+	groups []string
+	key    *rsa.PrivateKey
+	keyID  string
+	logger logging.Logger
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+func main() {
+	logger := logging.NewLogger(serviceName)
+	logger.SetLevel(logging.DebugLevel)
+
+	addr := os.Getenv("MOCK_IDP_ADDR")
+	if addr == "" {
+		addr = defaultAddr
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		logger.Panic("Error encountered on generating the signing key", "error", err)
+	}
+
+	email := os.Getenv("MOCK_IDP_EMAIL")
+	if email == "" {
+		email = defaultEmail
+	}
+
+	p := &provider{
+		issuer: "http://" + addr,
+		email:  email,
+		groups: splitList(os.Getenv("MOCK_IDP_GROUPS")),
+		key:    key,
+		keyID:  common.RandomHex(8),
+		logger: logger,
+		codes:  make(map[string]authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+
+	logger.Info("Starting mock identity provider ", p.issuer)
+	if err = http.ListenAndServe(addr, mux); err != nil {
+		logger.Panic("Error encountered on running the server", "error", err)
+	}
+}
+
+func (p *provider) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *provider) jwks(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": p.keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURI.IsAbs() == false {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("response_type") != "code" || query.Get("client_id") == "" ||
+		query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "expected a code request with a S256 code challenge", http.StatusBadRequest)
+		return
+	}
+
+	code := common.RandomHex(16)
+	p.mu.Lock()
+	p.codes[code] = authorization{
+		clientID:    query.Get("client_id"),
+		redirectURI: redirectURI.String(),
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+		email:       p.email,
+		groups:      p.groups,
+		expiresAt:   time.Now().Add(codeTTL),
+	}
+	p.mu.Unlock()
+
+	callback := redirectURI.Query()
+	callback.Set("code", code)
+	callback.Set("state", query.Get("state"))
+	redirectURI.RawQuery = callback.Encode()
+
+	p.logger.Info("Approved the login of ", p.email)
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (p *provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	clientID := r.PostForm.Get("client_id")
+	if basicID, _, found := r.BasicAuth(); found {
+		clientID, _ = url.QueryUnescape(basicID)
+	}
+
+	if ok == false || time.Now().After(auth.expiresAt) || auth.clientID != clientID ||
+		auth.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.issuer,
+		"sub":            fmt.Sprintf("mock|%s", auth.email),
+		"aud":            auth.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(idTokenTTL).Unix(),
+		"nonce":          auth.nonce,
+		"email":          auth.email,
+		"email_verified": true,
+		"groups":         auth.groups,
+	})
+	token.Header["kid"] = p.keyID
+
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": common.RandomHex(16),
+		"token_type":   "Bearer",
+		"expires_in":   int(idTokenTTL / time.Second),
+		"id_token":     idToken,
+	})
+}
+
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' })
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = jsoniter.NewEncoder(w).Encode(v)
+}
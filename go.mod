@@ -3,15 +3,21 @@ module lynkly-backend
 go 1.20
 
 require (
-	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
-	github.com/go-http-utils/fresh v0.0.0-20161124030543-7231e26a4b27 // indirect
-	github.com/go-http-utils/headers v0.0.0-20181008091004-fed159eddc2a // indirect
-	github.com/gorilla/mux v1.8.1 // indirect
-	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/go-http-utils/fresh v0.0.0-20161124030543-7231e26a4b27
+	github.com/go-http-utils/headers v0.0.0-20181008091004-fed159eddc2a
+	github.com/gorilla/mux v1.8.1
+	github.com/json-iterator/go v1.1.12
+	github.com/rs/cors v1.10.1
+	github.com/sirupsen/logrus v1.9.3
+	github.com/urfave/negroni v1.0.0
+	golang.org/x/crypto v0.21.0
+)
+
+require (
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
-	github.com/rs/cors v1.10.1 // indirect
-	github.com/sirupsen/logrus v1.9.3 // indirect
-	github.com/urfave/negroni v1.0.0 // indirect
+	golang.org/x/net v0.21.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 )
@@ -0,0 +1,226 @@
+package analytics
+
+import (
+	"context"
+	"lynkly-backend/internal/logging"
+	"sort"
+	"time"
+)
+
+const defaultRollupInterval = 5 * time.Minute
+
+type Params struct {
+	Logger logging.Logger
+	Store  Store
+	// RollupInterval is how often raw clicks are aggregated and the retention policy is applied.
+	RollupInterval time.Duration
+	// Retention holds, per granularity, how long data is kept. A zero value keeps the data forever.
+	Retention map[Granularity]time.Duration
+}
+
+// Analytics records clicks and aggregates them into hourly, daily and monthly summaries.
+type Analytics struct {
+	logger         logging.Logger
+	store          Store
+	rollupInterval time.Duration
+	retention      map[Granularity]time.Duration
+}
+
+// Stats holds the clicks of a short link in a time range, reported at the finest granularity
+// still available for the whole range.
+type Stats struct {
+	Code        string      `json:"code"`
+	Granularity Granularity `json:"granularity"`
+	From        time.Time   `json:"from"`
+	To          time.Time   `json:"to"`
+	Total       int64       `json:"total"`
+	Series      []Point     `json:"series"`
+}
+
+type Point struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+func New(params Params) *Analytics {
+	if params.RollupInterval <= 0 {
+		params.RollupInterval = defaultRollupInterval
+	}
+
+	return &Analytics{
+		logger:         params.Logger,
+		store:          params.Store,
+		rollupInterval: params.RollupInterval,
+		retention:      params.Retention,
+	}
+}
+
+func (a *Analytics) Record(click Click) error {
+	return a.store.AddClick(click)
+}
+
+// RunRollups applies the rollups on every rollup interval until the context is cancelled.
+func (a *Analytics) RunRollups(ctx context.Context) {
+	ticker := time.NewTicker(a.rollupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := a.Rollup(now); err != nil {
+				a.logger.Error("Analytics rollup failed: ", err)
+			}
+		}
+	}
+}
+
+// Rollup aggregates the raw clicks of all completed hours into hourly buckets, the completed hours
+// into daily buckets and the completed days into monthly buckets. It then deletes the data past
+// its retention, but never data which has not been aggregated into the next granularity yet.
+func (a *Analytics) Rollup(now time.Time) error {
+	state, err := a.store.RollupState()
+	if err != nil {
+		return err
+	}
+
+	for _, granularity := range []Granularity{Hourly, Daily, Monthly} {
+		query := Query{
+			From: state.Watermarks[granularity],
+			To:   granularity.Truncate(now),
+		}
+
+		buckets, err := a.aggregate(granularity-1, granularity, query)
+		if err != nil {
+			return err
+		}
+
+		if err = a.store.IncrementBuckets(granularity, buckets); err != nil {
+			return err
+		}
+		state.Watermarks[granularity] = query.To
+	}
+
+	for _, granularity := range []Granularity{Raw, Hourly, Daily, Monthly} {
+		retention := a.retention[granularity]
+		if retention == 0 {
+			continue
+		}
+
+		cutoff := granularity.Truncate(now.Add(-retention))
+		if granularity < Monthly && cutoff.After(state.Watermarks[granularity+1]) {
+			cutoff = state.Watermarks[granularity+1]
+		}
+		if cutoff.After(state.RetainedFrom[granularity]) == false {
+			continue
+		}
+
+		if granularity == Raw {
+			err = a.store.DeleteClicksBefore(cutoff)
+		} else {
+			err = a.store.DeleteBucketsBefore(granularity, cutoff)
+		}
+		if err != nil {
+			return err
+		}
+		state.RetainedFrom[granularity] = cutoff
+	}
+
+	return a.store.SaveRollupState(state)
+}
+
+// Stats returns the clicks of the short link in the range [from, to) at the finest granularity
+// whose data has not been deleted by the retention policy since from. The start of the range is
+// aligned to the start of the period of the chosen granularity.
+func (a *Analytics) Stats(code string, from, to time.Time) (*Stats, error) {
+	state, err := a.store.RollupState()
+	if err != nil {
+		return nil, err
+	}
+
+	granularity := Hourly
+	for granularity < Monthly && state.RetainedFrom[granularity].After(from) {
+		granularity++
+	}
+	from = granularity.Truncate(from)
+
+	// Each granularity holds the data up to its watermark and the watermarks of the finer
+	// granularities are always later, so the range is covered by walking from the coarsest
+	// granularity down to the raw clicks.
+	counts := make(map[time.Time]int64)
+	rangeStart := from
+	for source := granularity; source >= Raw; source-- {
+		query := Query{
+			Code: code,
+			From: rangeStart,
+			To:   to,
+		}
+		if source > Raw && state.Watermarks[source].Before(to) {
+			query.To = state.Watermarks[source]
+		}
+		if query.To.After(query.From) == false {
+			continue
+		}
+
+		buckets, err := a.aggregate(source, granularity, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range buckets {
+			counts[bucket.Start] += bucket.Count
+		}
+		rangeStart = query.To
+	}
+
+	stats := &Stats{
+		Code:        code,
+		Granularity: granularity,
+		From:        from,
+		To:          to,
+		Series:      make([]Point, 0, len(counts)),
+	}
+	for start, count := range counts {
+		stats.Series = append(stats.Series, Point{Start: start, Count: count})
+		stats.Total += count
+	}
+	sort.Slice(stats.Series, func(i, j int) bool {
+		return stats.Series[i].Start.Before(stats.Series[j].Start)
+	})
+
+	return stats, nil
+}
+
+// aggregate sums the data of the source granularity matching the query into buckets of the target granularity.
+func (a *Analytics) aggregate(source, target Granularity, query Query) ([]Bucket, error) {
+	type bucketKey struct {
+		code  string
+		start time.Time
+	}
+	counts := make(map[bucketKey]int64)
+
+	if source == Raw {
+		clicks, err := a.store.Clicks(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, click := range clicks {
+			counts[bucketKey{click.Code, target.Truncate(click.Timestamp)}]++
+		}
+	} else {
+		buckets, err := a.store.Buckets(source, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range buckets {
+			counts[bucketKey{bucket.Code, target.Truncate(bucket.Start)}] += bucket.Count
+		}
+	}
+
+	result := make([]Bucket, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, Bucket{Code: key.code, Start: key.start, Count: count})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,200 @@
+package analytics
+
+import (
+	"lynkly-backend/internal/logging"
+	"testing"
+	"time"
+)
+
+// now is the time of the rollups in the tests, in the middle of an hour
+var now = time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+
+func newTestAnalytics(t *testing.T, retention map[Granularity]time.Duration, clicks ...time.Time) *Analytics {
+	t.Helper()
+
+	a := New(Params{
+		Logger:    logging.NewLogger("analytics_test"),
+		Store:     NewMemoryStore(),
+		Retention: retention,
+	})
+	for _, timestamp := range clicks {
+		if err := a.Record(Click{Code: "abc", Timestamp: timestamp}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the clicks of other links are not counted
+	if err := a.Record(Click{Code: "xyz", Timestamp: now.Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	return a
+}
+
+func bucketCounts(t *testing.T, a *Analytics, granularity Granularity) map[time.Time]int64 {
+	t.Helper()
+
+	buckets, err := a.store.Buckets(granularity, Query{Code: "abc", To: now.Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[time.Time]int64)
+	for _, bucket := range buckets {
+		counts[bucket.Start] = bucket.Count
+	}
+
+	return counts
+}
+
+func equalCounts(a, b map[time.Time]int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for start, count := range a {
+		if b[start] != count {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestRollup(t *testing.T) {
+	a := newTestAnalytics(t, nil,
+		time.Date(2024, 1, 20, 8, 15, 0, 0, time.UTC),
+		time.Date(2024, 3, 14, 9, 15, 0, 0, time.UTC),
+		time.Date(2024, 3, 15, 9, 10, 0, 0, time.UTC),
+		time.Date(2024, 3, 15, 9, 50, 0, 0, time.UTC),
+		// the current hour is not complete yet
+		time.Date(2024, 3, 15, 10, 5, 0, 0, time.UTC),
+	)
+
+	// repeated rollups do not count the clicks again
+	for i := 0; i < 2; i++ {
+		if err := a.Rollup(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		granularity Granularity
+		expected    map[time.Time]int64
+	}{
+		{Hourly, map[time.Time]int64{
+			time.Date(2024, 1, 20, 8, 0, 0, 0, time.UTC): 1,
+			time.Date(2024, 3, 14, 9, 0, 0, 0, time.UTC): 1,
+			time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC): 2,
+		}},
+		{Daily, map[time.Time]int64{
+			time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC): 1,
+			time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC): 1,
+		}},
+		{Monthly, map[time.Time]int64{
+			time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC): 1,
+		}},
+	}
+
+	for _, test := range tests {
+		if counts := bucketCounts(t, a, test.granularity); equalCounts(counts, test.expected) == false {
+			t.Errorf("%s: expected %v, got %v", test.granularity, test.expected, counts)
+		}
+	}
+
+	stats, err := a.Stats("abc", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Granularity != Hourly || stats.Total != 3 || len(stats.Series) != 2 {
+		t.Errorf("Expected 3 clicks in 2 hours including the current one, got %+v", stats)
+	}
+}
+
+func TestRollupRetention(t *testing.T) {
+	rawCutoff := now.Add(-24 * time.Hour)
+	a := newTestAnalytics(t, map[Granularity]time.Duration{
+		Raw:    24 * time.Hour,
+		Hourly: 7 * 24 * time.Hour,
+		Daily:  30 * 24 * time.Hour,
+	},
+		time.Date(2024, 1, 20, 8, 15, 0, 0, time.UTC),
+		rawCutoff.Add(-time.Second),
+		rawCutoff,
+		time.Date(2024, 3, 15, 9, 10, 0, 0, time.UTC),
+		time.Date(2024, 3, 15, 10, 5, 0, 0, time.UTC),
+	)
+
+	if err := a.Rollup(now); err != nil {
+		t.Fatal(err)
+	}
+
+	clicks, err := a.store.Clicks(Query{Code: "abc", To: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clicks) != 3 || clicks[0].Timestamp.Equal(rawCutoff) == false {
+		t.Errorf("Expected the raw clicks from the cutoff on to be kept, got %v", clicks)
+	}
+
+	hourly := bucketCounts(t, a, Hourly)
+	if _, ok := hourly[time.Date(2024, 1, 20, 8, 0, 0, 0, time.UTC)]; ok || len(hourly) != 2 {
+		t.Errorf("Expected the hourly buckets past the retention to be deleted, got %v", hourly)
+	}
+	daily := bucketCounts(t, a, Daily)
+	if len(daily) != 1 || daily[time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)] != 2 {
+		t.Errorf("Expected the daily buckets past the retention to be deleted, got %v", daily)
+	}
+
+	hourlyRetainedFrom := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	dailyRetainedFrom := time.Date(2024, 2, 14, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		from        time.Time
+		granularity Granularity
+		total       int64
+	}{
+		{"hourly data retained", hourlyRetainedFrom, Hourly, 4},
+		{"hourly data deleted", hourlyRetainedFrom.Add(-time.Second), Daily, 4},
+		{"daily data retained", dailyRetainedFrom, Daily, 4},
+		{"daily data deleted", dailyRetainedFrom.Add(-time.Second), Monthly, 4},
+		{"monthly data", time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), Monthly, 5},
+	}
+
+	for _, test := range tests {
+		stats, err := a.Stats("abc", test.from, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Granularity != test.granularity || stats.Total != test.total {
+			t.Errorf("%s: expected %d clicks by %s, got %d by %s",
+				test.name, test.total, test.granularity, stats.Total, stats.Granularity)
+		}
+	}
+}
+
+func TestRetentionKeepsDataNotRolledUp(t *testing.T) {
+	a := newTestAnalytics(t, map[Granularity]time.Duration{Raw: time.Minute},
+		time.Date(2024, 3, 15, 9, 59, 0, 0, time.UTC),
+		time.Date(2024, 3, 15, 10, 5, 0, 0, time.UTC),
+	)
+
+	if err := a.Rollup(now); err != nil {
+		t.Fatal(err)
+	}
+
+	// the clicks of the current hour are within the retention cutoff but are not in an hourly bucket yet
+	clicks, err := a.store.Clicks(Query{Code: "abc", To: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clicks) != 1 || clicks[0].Timestamp.Hour() != 10 {
+		t.Errorf("Expected the click of the current hour to be kept, got %v", clicks)
+	}
+
+	stats, err := a.Stats("abc", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 2 {
+		t.Errorf("Expected 2 clicks, got %d", stats.Total)
+	}
+}
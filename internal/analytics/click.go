@@ -0,0 +1,49 @@
+package analytics
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	ClickEventType = "click"
+	clickTopic     = "clicks:"
+)
+
+// Click is a single visit of a short link.
+type Click struct {
+	Code      string    `json:"code"`
+	Timestamp time.Time `json:"timestamp"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	Referrer  string    `json:"referrer,omitempty"`
+	// ClickID is the signed ID appended to the destination for attributing conversions.
+	ClickID string `json:"clickId,omitempty"`
+}
+
+// NewClick builds the click for the short link code from the redirect request.
+func NewClick(code string, r *http.Request) Click {
+	return Click{
+		Code:      code,
+		Timestamp: time.Now().UTC(),
+		IP:        ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Referrer:  r.Referer(),
+	}
+}
+
+// ClickTopic returns the events.Hub topic on which the clicks of the short link code are published.
+func ClickTopic(code string) string {
+	return clickTopic + code
+}
+
+// ClientIP returns the IP address of the client which sent the request.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
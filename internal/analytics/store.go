@@ -0,0 +1,228 @@
+package analytics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Granularity is the resolution at which clicks are stored or reported.
+type Granularity int
+
+const (
+	Raw Granularity = iota
+	Hourly
+	Daily
+	Monthly
+)
+
+var granularityNames = map[Granularity]string{
+	Raw:     "raw",
+	Hourly:  "hour",
+	Daily:   "day",
+	Monthly: "month",
+}
+
+func (g Granularity) String() string {
+	return granularityNames[g]
+}
+
+func (g Granularity) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
+
+// Truncate returns the start of the period of the granularity containing t.
+func (g Granularity) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	switch g {
+	case Hourly:
+		return t.Truncate(time.Hour)
+	case Daily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case Monthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return t
+}
+
+// Bucket holds the number of clicks of a short link in the period starting at Start.
+type Bucket struct {
+	Code  string
+	Start time.Time
+	Count int64
+}
+
+// Query selects the data of a short link, or of all links when Code is empty, in the range [From, To).
+type Query struct {
+	Code string
+	From time.Time
+	To   time.Time
+}
+
+func (q Query) matches(code string, t time.Time) bool {
+	if q.Code != "" && q.Code != code {
+		return false
+	}
+
+	return t.Before(q.From) == false && t.Before(q.To)
+}
+
+// RollupState tracks the progress of the rollups. Watermarks hold, per granularity, the time up to
+// which data has been aggregated into it. RetainedFrom holds, per granularity, the time before which
+// data has been deleted by the retention policy.
+type RollupState struct {
+	Watermarks   map[Granularity]time.Time
+	RetainedFrom map[Granularity]time.Time
+}
+
+type Store interface {
+	AddClick(click Click) error
+	Clicks(query Query) ([]Click, error)
+	DeleteClicksBefore(t time.Time) error
+	IncrementBuckets(granularity Granularity, buckets []Bucket) error
+	Buckets(granularity Granularity, query Query) ([]Bucket, error)
+	DeleteBucketsBefore(granularity Granularity, t time.Time) error
+	RollupState() (RollupState, error)
+	SaveRollupState(state RollupState) error
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu      sync.RWMutex
+	clicks  []Click
+	buckets map[Granularity]map[string]map[time.Time]int64
+	state   RollupState
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets: map[Granularity]map[string]map[time.Time]int64{
+			Hourly:  {},
+			Daily:   {},
+			Monthly: {},
+		},
+		state: RollupState{
+			Watermarks:   map[Granularity]time.Time{},
+			RetainedFrom: map[Granularity]time.Time{},
+		},
+	}
+}
+
+func (s *memoryStore) AddClick(click Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clicks = append(s.clicks, click)
+	return nil
+}
+
+func (s *memoryStore) Clicks(query Query) ([]Click, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []Click
+	for _, click := range s.clicks {
+		if query.matches(click.Code, click.Timestamp) {
+			result = append(result, click)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *memoryStore) DeleteClicksBefore(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.clicks[:0]
+	for _, click := range s.clicks {
+		if click.Timestamp.Before(t) == false {
+			kept = append(kept, click)
+		}
+	}
+	s.clicks = kept
+
+	return nil
+}
+
+func (s *memoryStore) IncrementBuckets(granularity Granularity, buckets []Bucket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, bucket := range buckets {
+		linkBuckets, ok := s.buckets[granularity][bucket.Code]
+		if ok == false {
+			linkBuckets = make(map[time.Time]int64)
+			s.buckets[granularity][bucket.Code] = linkBuckets
+		}
+		linkBuckets[bucket.Start] += bucket.Count
+	}
+
+	return nil
+}
+
+func (s *memoryStore) Buckets(granularity Granularity, query Query) ([]Bucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []Bucket
+	for code, linkBuckets := range s.buckets[granularity] {
+		for start, count := range linkBuckets {
+			if query.matches(code, start) {
+				result = append(result, Bucket{Code: code, Start: start, Count: count})
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+
+	return result, nil
+}
+
+func (s *memoryStore) DeleteBucketsBefore(granularity Granularity, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, linkBuckets := range s.buckets[granularity] {
+		for start := range linkBuckets {
+			if start.Before(t) {
+				delete(linkBuckets, start)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStore) RollupState() (RollupState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return copyRollupState(s.state), nil
+}
+
+func (s *memoryStore) SaveRollupState(state RollupState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state = copyRollupState(state)
+	return nil
+}
+
+func copyRollupState(state RollupState) RollupState {
+	result := RollupState{
+		Watermarks:   make(map[Granularity]time.Time, len(state.Watermarks)),
+		RetainedFrom: make(map[Granularity]time.Time, len(state.RetainedFrom)),
+	}
+	for k, v := range state.Watermarks {
+		result.Watermarks[k] = v
+	}
+	for k, v := range state.RetainedFrom {
+		result.RetainedFrom[k] = v
+	}
+
+	return result
+}
@@ -0,0 +1,157 @@
+package apikeys
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"lynkly-backend/internal/common"
+	"strings"
+	"time"
+)
+
+const (
+	// keyPrefix makes the keys recognizable, e.g. by secret scanners
+	keyPrefix    = "lk_"
+	lookupLength = 12
+	secretLength = 32
+	// lastUsedResolution limits how often the last-used timestamp of a key is written
+	lastUsedResolution = time.Minute
+)
+
+var (
+	ErrNotFound   = errors.New("API key not found")
+	ErrInvalidKey = errors.New("invalid API key")
+	ErrRevoked    = errors.New("API key has been revoked")
+)
+
+// APIKey is a credential for programmatic access to a workspace on behalf of its owner. Only the
+// SHA-256 hash of the key is stored, the key itself is shown once on creation. LookupID is the public
+// part of the key used to find it.
+type APIKey struct {
+	ID          string     `json:"id"`
+	OwnerID     string     `json:"ownerId"`
+	WorkspaceID string     `json:"workspaceId"`
+	Name        string     `json:"name"`
+	LookupID    string     `json:"prefix"`
+	Hash        string     `json:"-"`
+	Scopes      []string   `json:"scopes"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsKey reports whether the credential looks like an API key rather than a JWT.
+func IsKey(credential string) bool {
+	return strings.HasPrefix(credential, keyPrefix)
+}
+
+type Params struct {
+	Store Store
+}
+
+// Keys issues and verifies API keys.
+type Keys struct {
+	store Store
+}
+
+func New(params Params) *Keys {
+	return &Keys{store: params.Store}
+}
+
+func (k *Keys) Store() Store {
+	return k.store
+}
+
+// Create issues a new key for the owner in the workspace and returns it together with the plaintext
+// key, which cannot be recovered later.
+func (k *Keys) Create(ownerID, workspaceID, name string, scopes []string) (*APIKey, string, error) {
+	lookupID := common.RandomHex(lookupLength / 2)
+	plaintext := keyPrefix + lookupID + "_" + common.RandomHex(secretLength/2)
+
+	key := &APIKey{
+		ID:          common.NewID(),
+		OwnerID:     ownerID,
+		WorkspaceID: workspaceID,
+		Name:        name,
+		LookupID:    lookupID,
+		Hash:        hash(plaintext),
+		Scopes:      scopes,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := k.store.Create(key); err != nil {
+		return nil, "", err
+	}
+
+	return key, plaintext, nil
+}
+
+// Verify returns the key matching the plaintext and records its use.
+func (k *Keys) Verify(plaintext string) (*APIKey, error) {
+	parts := strings.Split(strings.TrimPrefix(plaintext, keyPrefix), "_")
+	if IsKey(plaintext) == false || len(parts) != 2 {
+		return nil, ErrInvalidKey
+	}
+
+	key, err := k.store.ByLookupID(parts[0])
+	if err == ErrNotFound {
+		return nil, ErrInvalidKey
+	} else if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash(plaintext))) != 1 {
+		return nil, ErrInvalidKey
+	}
+
+	if key.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+
+	now := time.Now().UTC()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err = k.store.TouchLastUsed(key.ID, now); err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = &now
+	}
+
+	return key, nil
+}
+
+// Revoke revokes the key of the workspace. Keys of other workspaces are reported as ErrNotFound.
+func (k *Keys) Revoke(workspaceID, id string) (*APIKey, error) {
+	key, err := k.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.WorkspaceID != workspaceID {
+		return nil, ErrNotFound
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now().UTC()
+		key.RevokedAt = &now
+		if err = k.store.Update(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func hash(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
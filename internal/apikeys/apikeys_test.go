@@ -0,0 +1,112 @@
+package apikeys
+
+import (
+	"strings"
+	"testing"
+)
+
+// interleavingStore runs afterLoad once after a key was loaded by its lookup ID, so that a
+// test can act between the load and the write of Verify.
+type interleavingStore struct {
+	Store
+	afterLoad func()
+}
+
+func (s *interleavingStore) ByLookupID(lookupID string) (*APIKey, error) {
+	key, err := s.Store.ByLookupID(lookupID)
+	if s.afterLoad != nil {
+		afterLoad := s.afterLoad
+		s.afterLoad = nil
+		afterLoad()
+	}
+
+	return key, err
+}
+
+func TestVerify(t *testing.T) {
+	keys := New(Params{Store: NewMemoryStore()})
+
+	key, plaintext, err := keys.Create("owner", "workspace", "CI", []string{"links:write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := keys.Verify(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.ID != key.ID || verified.LastUsedAt == nil || verified.HasScope("links:write") == false {
+		t.Errorf("Unexpected key %+v", verified)
+	}
+
+	stored, err := keys.Store().Get(key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.LastUsedAt == nil {
+		t.Error("Expected the use of the key to be recorded")
+	}
+
+	tests := map[string]string{
+		"other secret":    plaintext[:len(plaintext)-1] + "0",
+		"unknown lookup":  keyPrefix + "000000000000_" + strings.Repeat("0", secretLength),
+		"no prefix":       strings.TrimPrefix(plaintext, keyPrefix),
+		"extra separator": plaintext + "_abc",
+		"empty":           "",
+	}
+	for name, other := range tests {
+		if other == plaintext {
+			continue
+		}
+		if _, err = keys.Verify(other); err != ErrInvalidKey {
+			t.Errorf("%s: expected ErrInvalidKey, got %v", name, err)
+		}
+	}
+}
+
+func TestRevokeDuringVerify(t *testing.T) {
+	store := &interleavingStore{Store: NewMemoryStore()}
+	keys := New(Params{Store: store})
+
+	key, plaintext, err := keys.Create("owner", "workspace", "CI", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key is revoked after Verify loaded it but before it records the use
+	store.afterLoad = func() {
+		if _, err := keys.Revoke("workspace", key.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = keys.Verify(plaintext); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := store.Get(key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RevokedAt == nil || stored.LastUsedAt == nil {
+		t.Errorf("Expected the key to stay revoked with its use recorded, got %+v", stored)
+	}
+	if _, err = keys.Verify(plaintext); err != ErrRevoked {
+		t.Errorf("Expected ErrRevoked, got %v", err)
+	}
+}
+
+func TestRevokeOtherWorkspace(t *testing.T) {
+	keys := New(Params{Store: NewMemoryStore()})
+
+	key, plaintext, err := keys.Create("owner", "workspace", "CI", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = keys.Revoke("other", key.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err = keys.Verify(plaintext); err != nil {
+		t.Errorf("Expected the key to stay valid, got %v", err)
+	}
+}
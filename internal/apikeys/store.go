@@ -0,0 +1,123 @@
+package apikeys
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	Create(key *APIKey) error
+	// Get returns the key with the ID or ErrNotFound.
+	Get(id string) (*APIKey, error)
+	// ByLookupID returns the key with the lookup ID or ErrNotFound.
+	ByLookupID(lookupID string) (*APIKey, error)
+	// ByOwner returns the keys of the owner, oldest first.
+	ByOwner(ownerID string) ([]*APIKey, error)
+	// ByWorkspace returns the keys of the workspace, oldest first.
+	ByWorkspace(workspaceID string) ([]*APIKey, error)
+	Update(key *APIKey) error
+	// TouchLastUsed sets only the last-used timestamp of the key, so that it never overwrites a
+	// concurrent revocation. It returns ErrNotFound when the key does not exist.
+	TouchLastUsed(id string, at time.Time) error
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{keys: make(map[string]APIKey)}
+}
+
+func (s *memoryStore) Create(key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.ID] = *key
+	return nil
+}
+
+func (s *memoryStore) Get(id string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	return &key, nil
+}
+
+func (s *memoryStore) ByLookupID(lookupID string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.LookupID == lookupID {
+			return &key, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) ByOwner(ownerID string) ([]*APIKey, error) {
+	return s.filter(func(key APIKey) bool {
+		return key.OwnerID == ownerID
+	}), nil
+}
+
+func (s *memoryStore) ByWorkspace(workspaceID string) ([]*APIKey, error) {
+	return s.filter(func(key APIKey) bool {
+		return key.WorkspaceID == workspaceID
+	}), nil
+}
+
+func (s *memoryStore) filter(keep func(key APIKey) bool) []*APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*APIKey
+	for _, key := range s.keys {
+		if keep(key) {
+			key := key
+			result = append(result, &key)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result
+}
+
+func (s *memoryStore) Update(key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key.ID]; ok == false {
+		return ErrNotFound
+	}
+
+	s.keys[key.ID] = *key
+	return nil
+}
+
+func (s *memoryStore) TouchLastUsed(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if ok == false {
+		return ErrNotFound
+	}
+
+	key.LastUsedAt = &at
+	s.keys[id] = key
+	return nil
+}
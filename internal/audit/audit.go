@@ -0,0 +1,195 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/models/lhttp"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// verifyBatchSize is the number of entries loaded at once while verifying the chain
+const verifyBatchSize = 1000
+
+// ErrChainBroken is returned by Verify when an entry was altered, removed or inserted.
+var ErrChainBroken = errors.New("audit chain is broken")
+
+// Entry records a change made through the API. Entries are chained by the SHA-256 hash of their
+// predecessor, so that removing or altering an entry can be detected.
+type Entry struct {
+	ID          string            `json:"id"`
+	Sequence    int64             `json:"sequence"`
+	Time        time.Time         `json:"time"`
+	ActorID     string            `json:"actorId"`
+	APIKeyID    string            `json:"apiKeyId,omitempty"`
+	WorkspaceID string            `json:"workspaceId,omitempty"`
+	Action      string            `json:"action"`
+	TargetType  string            `json:"targetType,omitempty"`
+	TargetID    string            `json:"targetId,omitempty"`
+	Changes     map[string]Change `json:"changes,omitempty"`
+	RequestID   string            `json:"requestId"`
+	IP          string            `json:"ip"`
+	PrevHash    string            `json:"prevHash"`
+	// Hash is the SHA-256 hash of the JSON encoded entry without the hash, which includes PrevHash
+	Hash string `json:"hash"`
+}
+
+// SystemActor is the actor of the changes made by the service itself, e.g. by its background jobs.
+const SystemActor = "system"
+
+// Target is the resource changed by a request. WorkspaceID is the workspace of the resource when it
+// differs from the workspace of the request.
+type Target struct {
+	Type        string
+	ID          string
+	WorkspaceID string
+}
+
+type Params struct {
+	Logger logging.Logger
+	Store  Store
+}
+
+// Log records the mutating API requests of the authenticated callers. It implements routers.Auditor.
+type Log struct {
+	logger logging.Logger
+	store  Store
+	// mu serializes the appends, so that the entries form a single chain
+	mu sync.Mutex
+}
+
+func New(params Params) *Log {
+	return &Log{
+		logger: params.Logger,
+		store:  params.Store,
+	}
+}
+
+func (l *Log) Store() Store {
+	return l.store
+}
+
+// Track prepares the request for its handler to describe the change with Describe and RecordChange.
+func (l *Log) Track(r *http.Request) *http.Request {
+	return common.ContextSet(r, common.ContextPair{Key: detailsKey, Value: &details{}})
+}
+
+// Record appends the entry of the request when it succeeded. Anonymous requests are not recorded.
+func (l *Log) Record(r *http.Request, resp *lhttp.HttpResponse) {
+	principal := auth.PrincipalFrom(r)
+	if principal == nil || resp.IsSuccessful() == false {
+		return
+	}
+
+	entry := &Entry{
+		ID:          common.NewID(),
+		ActorID:     principal.UserID,
+		APIKeyID:    principal.APIKeyID,
+		WorkspaceID: principal.WorkspaceID,
+		Action:      r.Method + " " + r.URL.Path,
+		IP:          clientIP(r),
+	}
+	entry.RequestID, _ = common.ContextGet(r, logging.RequestIDKey).(string)
+
+	if d := detailsFrom(r); d != nil {
+		if d.action != "" {
+			entry.Action = d.action
+		}
+		entry.TargetType = d.target.Type
+		entry.TargetID = d.target.ID
+		if d.target.WorkspaceID != "" {
+			entry.WorkspaceID = d.target.WorkspaceID
+		}
+		entry.Changes = d.changes
+	}
+
+	if err := l.Append(entry); err != nil {
+		l.logger.WithRequest(r).Error("Failed to append audit entry: ", err)
+	}
+}
+
+// Append chains the entry to the last one and stores it.
+func (l *Log) Append(entry *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	last, err := l.store.Last()
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	entry.Time = time.Now().UTC()
+	entry.Sequence = 1
+	entry.PrevHash = ""
+	if last != nil {
+		entry.Sequence = last.Sequence + 1
+		entry.PrevHash = last.Hash
+	}
+
+	if entry.Hash, err = hashEntry(entry); err != nil {
+		return err
+	}
+
+	return l.store.Append(entry)
+}
+
+// Verify walks the whole chain and returns ErrChainBroken for the first entry whose hash does not
+// match its content, or which does not follow its predecessor.
+func (l *Log) Verify() error {
+	query := Query{Limit: verifyBatchSize}
+	sequence := int64(1)
+	prevHash := ""
+	for {
+		entries, err := l.store.List(query)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			hash, err := hashEntry(entry)
+			if err != nil {
+				return err
+			}
+
+			if entry.Sequence != sequence || entry.PrevHash != prevHash || entry.Hash != hash {
+				return fmt.Errorf("%w - at sequence %d", ErrChainBroken, sequence)
+			}
+			sequence++
+			prevHash = entry.Hash
+		}
+
+		if len(entries) < query.Limit {
+			return nil
+		}
+		query.AfterSequence = entries[len(entries)-1].Sequence
+	}
+}
+
+func hashEntry(entry *Entry) (string, error) {
+	unhashed := *entry
+	unhashed.Hash = ""
+	data, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"errors"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func newTestLog(t *testing.T, entries int) *Log {
+	t.Helper()
+
+	l := New(Params{Logger: logging.NewLogger("audit_test"), Store: NewMemoryStore()})
+	for i := 0; i < entries; i++ {
+		workspaceID := "workspace"
+		if i%2 == 1 {
+			workspaceID = "other"
+		}
+		entry := &Entry{ID: strconv.Itoa(i), ActorID: "user", WorkspaceID: workspaceID, Action: ActionLinkUpdate}
+		if err := l.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return l
+}
+
+func TestAppendChainsEntries(t *testing.T) {
+	l := newTestLog(t, 3)
+
+	entries, err := l.Store().List(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	for i, entry := range entries {
+		if entry.Sequence != int64(i+1) {
+			t.Errorf("Expected sequence %d, got %d", i+1, entry.Sequence)
+		}
+		if i == 0 && entry.PrevHash != "" {
+			t.Errorf("Expected the first entry to have no predecessor, got %s", entry.PrevHash)
+		} else if i > 0 && entry.PrevHash != entries[i-1].Hash {
+			t.Errorf("Expected entry %d to be chained to its predecessor, got %s", entry.Sequence, entry.PrevHash)
+		}
+	}
+
+	if err = l.Verify(); err != nil {
+		t.Errorf("Expected the chain to be valid, got %v", err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := map[string]func(store *memoryStore){
+		"altered":  func(store *memoryStore) { store.entries[1].ActorID = "other" },
+		"rehashed": func(store *memoryStore) { store.entries[1].ActorID = "other"; rehash(&store.entries[1]) },
+		"removed":  func(store *memoryStore) { store.entries = append(store.entries[:1], store.entries[2:]...) },
+		"truncated head": func(store *memoryStore) {
+			store.entries = store.entries[1:]
+		},
+		"reordered": func(store *memoryStore) {
+			store.entries[1], store.entries[2] = store.entries[2], store.entries[1]
+		},
+	}
+
+	for name, tamper := range tests {
+		l := newTestLog(t, 4)
+		tamper(l.store.(*memoryStore))
+
+		if err := l.Verify(); errors.Is(err, ErrChainBroken) == false {
+			t.Errorf("%s: expected ErrChainBroken, got %v", name, err)
+		}
+	}
+}
+
+func rehash(entry *Entry) {
+	entry.Hash, _ = hashEntry(entry)
+}
+
+func TestVerifyLongChain(t *testing.T) {
+	l := newTestLog(t, verifyBatchSize*2+1)
+	if err := l.Verify(); err != nil {
+		t.Errorf("Expected the chain to be valid across batches, got %v", err)
+	}
+
+	l.store.(*memoryStore).entries[verifyBatchSize].Action = ActionLinkDelete
+	if err := l.Verify(); errors.Is(err, ErrChainBroken) == false {
+		t.Errorf("Expected ErrChainBroken in the second batch, got %v", err)
+	}
+}
+
+func TestListPages(t *testing.T) {
+	l := newTestLog(t, 7)
+
+	var sequences []int64
+	query := Query{WorkspaceID: "workspace", Limit: 2}
+	for {
+		entries, err := l.Store().List(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if entry.WorkspaceID != "workspace" {
+				t.Errorf("Expected only the entries of the workspace, got %+v", entry)
+			}
+			sequences = append(sequences, entry.Sequence)
+		}
+
+		if len(entries) < query.Limit {
+			break
+		}
+		query.AfterSequence = entries[len(entries)-1].Sequence
+	}
+
+	expected := []int64{1, 3, 5, 7}
+	if len(sequences) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, sequences)
+	}
+	for i := range expected {
+		if sequences[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, sequences)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	l := newTestLog(t, 0)
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		resp      *lhttp.HttpResponse
+		recorded  bool
+	}{
+		{"success", &auth.Principal{UserID: "user", WorkspaceID: "workspace"}, lhttp.NoContent(), true},
+		{"failure", &auth.Principal{UserID: "user", WorkspaceID: "workspace"}, lhttp.BadRequest().FromTrustedMessage("Invalid"), false},
+		{"anonymous", nil, lhttp.NoContent(), false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("PATCH", "/api/v1/links/abc", nil)
+		r.RemoteAddr = "203.0.113.7:1234"
+		if test.principal != nil {
+			r = common.ContextSet(r, common.ContextPair{Key: logging.UserKey, Value: test.principal})
+		}
+		r = l.Track(r)
+		Describe(r, ActionLinkUpdate, Target{Type: "link", ID: "abc"})
+		RecordChange(r, map[string]string{"url": "https://a.example/"}, map[string]string{"url": "https://b.example/"})
+
+		before, _ := l.Store().List(Query{})
+		l.Record(r, test.resp)
+		after, _ := l.Store().List(Query{})
+
+		if recorded := len(after) > len(before); recorded != test.recorded {
+			t.Errorf("%s: expected recorded to be %v, got %v", test.name, test.recorded, recorded)
+			continue
+		}
+		if test.recorded == false {
+			continue
+		}
+
+		entry := after[len(after)-1]
+		if entry.Action != ActionLinkUpdate || entry.TargetID != "abc" || entry.IP != "203.0.113.7" || entry.WorkspaceID != "workspace" {
+			t.Errorf("%s: unexpected entry %+v", test.name, entry)
+		}
+		if change, ok := entry.Changes["url"]; ok == false || change.Before != "https://a.example/" || change.After != "https://b.example/" {
+			t.Errorf("%s: expected the change of the URL, got %+v", test.name, entry.Changes)
+		}
+	}
+}
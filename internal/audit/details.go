@@ -0,0 +1,125 @@
+package audit
+
+import (
+	jsoniter "github.com/json-iterator/go"
+	"lynkly-backend/internal/common"
+	"net/http"
+	"reflect"
+)
+
+const detailsKey = "auditDetails"
+
+// Change is the value of a field before and after the request. Before is nil for created and After
+// for deleted resources.
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// details are filled in by the handler of a tracked request
+type details struct {
+	action  string
+	target  Target
+	changes map[string]Change
+}
+
+func detailsFrom(r *http.Request) *details {
+	d, _ := common.ContextGet(r, detailsKey).(*details)
+	return d
+}
+
+// Describe names the action of the request, e.g. "link.update", and the resource it changes. Requests
+// which are not described are recorded with their method and path.
+func Describe(r *http.Request, action string, target Target) {
+	if d := detailsFrom(r); d != nil {
+		d.action = action
+		d.target = target
+	}
+}
+
+// RecordChange records the fields which differ between the JSON encodings of the resource before and
+// after the request. Either of them may be nil. Fields hidden from JSON are never recorded.
+func RecordChange(r *http.Request, before, after interface{}) {
+	d := detailsFrom(r)
+	if d == nil {
+		return
+	}
+
+	changes, err := Diff(before, after)
+	if err != nil {
+		return
+	}
+	d.changes = changes
+}
+
+// Diff compares the top-level fields of the JSON encodings of before and after.
+func Diff(before, after interface{}) (map[string]Change, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for name, value := range beforeFields {
+		if afterValue, ok := afterFields[name]; ok == false || reflect.DeepEqual(value, afterValue) == false {
+			changes[name] = Change{Before: value, After: afterValue}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; ok == false {
+			changes[name] = Change{After: value}
+		}
+	}
+
+	return changes, nil
+}
+
+func jsonFields(v interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return fields, nil
+	}
+
+	data, err := jsoniter.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = jsoniter.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// actions recorded in the log
+const (
+	ActionLinkCreate            = "link.create"
+	ActionLinkUpdate            = "link.update"
+	ActionLinkDelete            = "link.delete"
+	ActionLinkDisable           = "link.disable"
+	ActionLinkQuarantine        = "link.quarantine"
+	ActionLinkRestore           = "link.restore"
+	ActionReportDismiss         = "report.dismiss"
+	ActionAPIKeyCreate          = "api_key.create"
+	ActionAPIKeyRevoke          = "api_key.revoke"
+	ActionWebhookCreate         = "webhook.create"
+	ActionWebhookDelete         = "webhook.delete"
+	ActionPrivacyUpdate         = "privacy.update"
+	ActionWorkspaceCreate       = "workspace.create"
+	ActionWorkspacePolicyUpdate = "workspace.policy_update"
+	ActionWorkspaceCORSUpdate   = "workspace.cors_update"
+	ActionMemberUpdate          = "member.update"
+	ActionMemberRemove          = "member.remove"
+	ActionInvitationCreate      = "invitation.create"
+	ActionInvitationDelete      = "invitation.delete"
+	ActionInvitationAccept      = "invitation.accept"
+	ActionDomainCreate          = "domain.create"
+	ActionDomainVerify          = "domain.verify"
+	ActionDomainDelete          = "domain.delete"
+)
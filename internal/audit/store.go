@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("audit entry not found")
+
+// Query filters the entries of a workspace. Empty fields match any entry. Entries are returned in the
+// order they were recorded, starting after the AfterSequence cursor.
+type Query struct {
+	WorkspaceID   string
+	ActorID       string
+	Action        string
+	TargetType    string
+	TargetID      string
+	From          time.Time
+	To            time.Time
+	AfterSequence int64
+	Limit         int
+}
+
+func (q *Query) matches(entry *Entry) bool {
+	return entry.Sequence > q.AfterSequence &&
+		(q.WorkspaceID == "" || entry.WorkspaceID == q.WorkspaceID) &&
+		(q.ActorID == "" || entry.ActorID == q.ActorID) &&
+		(q.Action == "" || entry.Action == q.Action) &&
+		(q.TargetType == "" || entry.TargetType == q.TargetType) &&
+		(q.TargetID == "" || entry.TargetID == q.TargetID) &&
+		(q.From.IsZero() || entry.Time.Before(q.From) == false) &&
+		(q.To.IsZero() || entry.Time.Before(q.To))
+}
+
+// Store is append-only, entries cannot be changed or removed once appended.
+type Store interface {
+	Append(entry *Entry) error
+	// Last returns the most recently appended entry or ErrNotFound.
+	Last() (*Entry, error)
+	// List returns up to Limit entries matching the query, all of them when Limit is not positive.
+	List(query Query) ([]*Entry, error)
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{}
+}
+
+func (s *memoryStore) Append(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, *entry)
+	return nil
+}
+
+func (s *memoryStore) Last() (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.entries) == 0 {
+		return nil, ErrNotFound
+	}
+
+	entry := s.entries[len(s.entries)-1]
+	return &entry, nil
+}
+
+func (s *memoryStore) List(query Query) ([]*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// the entries are appended in sequence order, so the cursor can be found by a binary search
+	start := sort.Search(len(s.entries), func(i int) bool {
+		return s.entries[i].Sequence > query.AfterSequence
+	})
+
+	var result []*Entry
+	for i := start; i < len(s.entries); i++ {
+		entry := s.entries[i]
+		if query.matches(&entry) == false {
+			continue
+		}
+
+		result = append(result, &entry)
+		if query.Limit > 0 && len(result) == query.Limit {
+			break
+		}
+	}
+
+	return result, nil
+}
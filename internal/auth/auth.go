@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"lynkly-backend/internal/apikeys"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/sessions"
+	"net/http"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+type Params struct {
+	JWT      *JWTAuthenticator
+	APIKeys  *apikeys.Keys
+	Sessions *sessions.Sessions
+}
+
+// Authenticator authenticates requests with either a user token or an API key. API keys are accepted
+// in the X-API-Key header or as a bearer token and are told apart from JWTs by their prefix. User
+// tokens are rejected once they or their session are revoked.
+type Authenticator struct {
+	jwt      *JWTAuthenticator
+	apiKeys  *apikeys.Keys
+	sessions *sessions.Sessions
+}
+
+func NewAuthenticator(params Params) *Authenticator {
+	return &Authenticator{
+		jwt:      params.JWT,
+		apiKeys:  params.APIKeys,
+		sessions: params.Sessions,
+	}
+}
+
+func (a *Authenticator) Authenticate(r *http.Request) (*http.Request, error) {
+	credential := r.Header.Get(APIKeyHeader)
+	if credential == "" {
+		var err error
+		if credential, err = BearerToken(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if apikeys.IsKey(credential) == false {
+		return a.authenticateToken(r)
+	}
+
+	key, err := a.apiKeys.Verify(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	principal := &Principal{
+		UserID:      key.OwnerID,
+		Method:      MethodAPIKey,
+		Scopes:      key.Scopes,
+		APIKeyID:    key.ID,
+		WorkspaceID: key.WorkspaceID,
+	}
+
+	return common.ContextSet(r, common.ContextPair{Key: logging.UserKey, Value: principal}), nil
+}
+
+func (a *Authenticator) authenticateToken(r *http.Request) (*http.Request, error) {
+	r, err := a.jwt.Authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+
+	principal := PrincipalFrom(r)
+	if principal == nil {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := a.sessions.IsRevoked(principal.TokenID, principal.SessionID)
+	if err != nil {
+		return nil, err
+	} else if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	// the principal replaces the raw token, so that the request is logged with the account once the
+	// workspace is resolved
+	return common.ContextSet(r, common.ContextPair{Key: logging.UserKey, Value: principal}), nil
+}
+
+func (a *Authenticator) HasCredentials(r *http.Request) bool {
+	return r.Header.Get(APIKeyHeader) != "" || r.Header.Get(lhttp.AuthorizationHeader) != ""
+}
+
+func (a *Authenticator) HasScope(r *http.Request, scope string) bool {
+	principal := PrincipalFrom(r)
+	return principal != nil && principal.HasScope(scope)
+}
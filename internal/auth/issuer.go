@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/logging"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultAccessTokenTTL = 15 * time.Minute
+
+var ErrNoSigningKey = errors.New("no token signing key is configured")
+
+type TokenIssuerParams struct {
+	// Keys sign RS256 tokens with the current key of the ring. They take precedence over HMACSecret.
+	Keys *KeyRing
+	// HMACSecret signs HS256 tokens when no RSA private key is provided.
+	HMACSecret []byte
+	// Issuer and Audience are set as the "iss" and "aud" claims when not empty.
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+}
+
+// TokenIssuer issues the access tokens of the users. The tokens are verified by JWTAuthenticator
+// configured with the matching key.
+type TokenIssuer struct {
+	method   jwt.SigningMethod
+	keys     *KeyRing
+	secret   []byte
+	issuer   string
+	audience string
+	ttl      time.Duration
+}
+
+// AccessToken is a signed token together with its expiry.
+type AccessToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+func NewTokenIssuer(params TokenIssuerParams) *TokenIssuer {
+	if params.TTL <= 0 {
+		params.TTL = defaultAccessTokenTTL
+	}
+
+	issuer := &TokenIssuer{
+		issuer:   params.Issuer,
+		audience: params.Audience,
+		ttl:      params.TTL,
+	}
+
+	if params.Keys != nil {
+		issuer.method, issuer.keys = jwt.SigningMethodRS256, params.Keys
+	} else if len(params.HMACSecret) > 0 {
+		issuer.method, issuer.secret = jwt.SigningMethodHS256, params.HMACSecret
+	}
+
+	return issuer
+}
+
+// TTL returns the lifetime of the issued tokens.
+func (i *TokenIssuer) TTL() time.Duration {
+	return i.ttl
+}
+
+// IssueParams describe the access token to issue.
+type IssueParams struct {
+	Subject   string
+	SessionID string
+	// AuthMethods are the methods the session was authenticated with
+	AuthMethods []string
+	// Scopes restrict the token when they are not nil, otherwise it is unrestricted
+	Scopes []string
+}
+
+// Issue signs an access token.
+func (i *TokenIssuer) Issue(params IssueParams) (*AccessToken, error) {
+	if i.method == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(i.ttl)
+	claims := jwt.MapClaims{
+		logging.SubKey: params.Subject,
+		"iat":          now.Unix(),
+		"exp":          expiresAt.Unix(),
+		tokenIDClaim:   common.NewID(),
+	}
+	if params.SessionID != "" {
+		claims[sessionClaim] = params.SessionID
+	}
+	if len(params.AuthMethods) > 0 {
+		claims[methodsClaim] = params.AuthMethods
+	}
+	if i.issuer != "" {
+		claims["iss"] = i.issuer
+	}
+	if i.audience != "" {
+		claims["aud"] = i.audience
+	}
+	if params.Scopes != nil {
+		claims[scopeClaim] = strings.Join(params.Scopes, " ")
+	}
+
+	var key interface{} = i.secret
+	token := jwt.NewWithClaims(i.method, claims)
+	if i.keys != nil {
+		signingKey := i.keys.Current()
+		token.Header["kid"] = signingKey.ID
+		key = signingKey.PrivateKey
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccessToken{
+		Token:     signed,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// LoadRSAPrivateKey reads a PEM encoded RSA private key from a file.
+func LoadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwt.ParseRSAPrivateKeyFromPEM(data)
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const bearerPrefix = "Bearer "
+
+var (
+	ErrMissingToken      = errors.New("missing bearer token")
+	ErrInvalidToken      = errors.New("invalid bearer token")
+	ErrNoKeysConfigured  = errors.New("no token verification keys are configured")
+	ErrUnexpectedSigning = errors.New("unexpected token signing method")
+	ErrUnknownSigningKey = errors.New("token is signed with an unknown key")
+)
+
+type JWTParams struct {
+	// HMACSecret verifies HS256 tokens. HS256 tokens are rejected when it is empty.
+	HMACSecret []byte
+	// RSAPublicKey verifies RS256 tokens, e.g. of another issuer. RS256 tokens are rejected when neither
+	// it nor Keys is set.
+	RSAPublicKey *rsa.PublicKey
+	// Keys verifies the RS256 tokens issued by the service, which name their key in the "kid" header.
+	Keys *KeyRing
+	// Issuer and Audience are required to match the "iss" and "aud" claims when not empty.
+	Issuer   string
+	Audience string
+	// Leeway is the clock skew tolerated when checking the "exp" and "nbf" claims.
+	Leeway time.Duration
+}
+
+// JWTAuthenticator authenticates requests carrying a bearer JWT signed with HS256 or RS256.
+type JWTAuthenticator struct {
+	hmacSecret   []byte
+	rsaPublicKey *rsa.PublicKey
+	keys         *KeyRing
+	issuer       string
+	audience     string
+	leeway       time.Duration
+	parser       *jwt.Parser
+}
+
+// NewJWTAuthenticator creates the authenticator. When neither an HMAC secret nor an RSA public key
+// is provided every token is rejected with ErrNoKeysConfigured.
+func NewJWTAuthenticator(params JWTParams) *JWTAuthenticator {
+	var methods []string
+	if len(params.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if params.RSAPublicKey != nil || params.Keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	return &JWTAuthenticator{
+		hmacSecret:   params.HMACSecret,
+		rsaPublicKey: params.RSAPublicKey,
+		keys:         params.Keys,
+		issuer:       params.Issuer,
+		audience:     params.Audience,
+		leeway:       params.Leeway,
+		parser: &jwt.Parser{
+			ValidMethods: methods,
+			// the time based claims are validated with leeway in validateClaims
+			SkipClaimsValidation: true,
+		},
+	}
+}
+
+// IsConfigured reports whether any key for verifying tokens was provided.
+func (a *JWTAuthenticator) IsConfigured() bool {
+	return len(a.parser.ValidMethods) > 0
+}
+
+// LoadRSAPublicKey reads a PEM encoded RSA public key from a file.
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwt.ParseRSAPublicKeyFromPEM(data)
+}
+
+// Authenticate validates the bearer token of the request and returns the request with the parsed
+// *jwt.Token stored in its context under logging.UserKey.
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*http.Request, error) {
+	tokenString, err := BearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := a.Validate(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.ContextSet(r, common.ContextPair{Key: logging.UserKey, Value: token}), nil
+}
+
+// Validate parses the token, verifies its signature and checks its expiry, issuer and audience.
+func (a *JWTAuthenticator) Validate(tokenString string) (*jwt.Token, error) {
+	// an empty list of valid methods would let the parser accept any algorithm
+	if a.IsConfigured() == false {
+		return nil, ErrNoKeysConfigured
+	}
+
+	token, err := a.parser.Parse(tokenString, a.keyFunc)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if ok == false {
+		return nil, ErrInvalidToken
+	}
+
+	if err = a.validateClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (a *JWTAuthenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	// the key is chosen by the algorithm so that an RS256 public key can never be used as an HMAC secret
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return a.hmacSecret, nil
+	case jwt.SigningMethodRS256.Alg():
+		return a.rsaKey(token)
+	}
+
+	return nil, ErrUnexpectedSigning
+}
+
+// rsaKey returns the key of the ring named by the "kid" header and falls back to the configured
+// public key for the tokens of other issuers.
+func (a *JWTAuthenticator) rsaKey(token *jwt.Token) (interface{}, error) {
+	if keyID, ok := token.Header["kid"].(string); ok && a.keys != nil {
+		if key := a.keys.PublicKey(keyID); key != nil {
+			return key, nil
+		}
+	}
+
+	if a.rsaPublicKey == nil {
+		return nil, ErrUnknownSigningKey
+	}
+
+	return a.rsaPublicKey, nil
+}
+
+func (a *JWTAuthenticator) validateClaims(claims jwt.MapClaims, now time.Time) error {
+	if claims.VerifyExpiresAt(now.Add(-a.leeway).Unix(), true) == false {
+		return fmt.Errorf("%w - token is expired or has no expiry", ErrInvalidToken)
+	}
+
+	if claims.VerifyNotBefore(now.Add(a.leeway).Unix(), false) == false {
+		return fmt.Errorf("%w - token is not valid yet", ErrInvalidToken)
+	}
+
+	if a.issuer != "" && claims.VerifyIssuer(a.issuer, true) == false {
+		return fmt.Errorf("%w - unexpected issuer", ErrInvalidToken)
+	}
+
+	if a.audience != "" && hasAudience(claims, a.audience) == false {
+		return fmt.Errorf("%w - unexpected audience", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+// hasAudience checks the "aud" claim, which may either be a single string or a list of strings.
+func hasAudience(claims jwt.MapClaims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// BearerToken extracts the token of the "Authorization: Bearer" header.
+func BearerToken(r *http.Request) (string, error) {
+	authorization := r.Header.Get(lhttp.AuthorizationHeader)
+	if strings.HasPrefix(authorization, bearerPrefix) == false {
+		return "", ErrMissingToken
+	}
+
+	token := strings.TrimSpace(strings.TrimPrefix(authorization, bearerPrefix))
+	if token == "" {
+		return "", ErrMissingToken
+	}
+
+	return token, nil
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testHMACSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": "user",
+		"iss": "https://lynkly.test",
+		"aud": "lynkly-api",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	rsaKey := newTestRSAKey(t)
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})
+
+	tests := map[string]JWTParams{
+		"RSA only":      {RSAPublicKey: &rsaKey.PublicKey},
+		"RSA and HMAC":  {RSAPublicKey: &rsaKey.PublicKey, HMACSecret: testHMACSecret},
+		"key ring only": {Keys: newTestKeyRing(t, KeyRingParams{Keys: []*rsa.PrivateKey{rsaKey}})},
+	}
+
+	for name, params := range tests {
+		authenticator := NewJWTAuthenticator(params)
+
+		// the public key is known to everyone, it must never verify an HMAC signature
+		for _, secret := range [][]byte{publicKeyPEM, publicKeyDER} {
+			token := sign(t, jwt.SigningMethodHS256, secret, validClaims())
+			if _, err = authenticator.Validate(token); errors.Is(err, ErrInvalidToken) == false {
+				t.Errorf("%s: expected ErrInvalidToken for an HS256 token signed with the public key, got %v", name, err)
+			}
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims())
+		token.Header["kid"] = thumbprint(&rsaKey.PublicKey)
+		signed, err := token.SignedString(rsaKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = authenticator.Validate(signed); err != nil {
+			t.Errorf("%s: expected the RS256 token to be valid, got %v", name, err)
+		}
+	}
+}
+
+func TestRejectsUnexpectedAlgorithms(t *testing.T) {
+	authenticator := NewJWTAuthenticator(JWTParams{HMACSecret: testHMACSecret})
+
+	tests := map[string]string{
+		"none":  sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims()),
+		"HS512": sign(t, jwt.SigningMethodHS512, testHMACSecret, validClaims()),
+		// RS256 is not accepted without an RSA key
+		"RS256":        sign(t, jwt.SigningMethodRS256, newTestRSAKey(t), validClaims()),
+		"other secret": sign(t, jwt.SigningMethodHS256, []byte("other"), validClaims()),
+		"malformed":    "abc.def.ghi",
+	}
+
+	for name, token := range tests {
+		if _, err := authenticator.Validate(token); errors.Is(err, ErrInvalidToken) == false {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+
+	if _, err := NewJWTAuthenticator(JWTParams{}).Validate(tests["none"]); err != ErrNoKeysConfigured {
+		t.Errorf("Expected ErrNoKeysConfigured without keys, got %v", err)
+	}
+}
+
+func TestValidateClaims(t *testing.T) {
+	authenticator := NewJWTAuthenticator(JWTParams{
+		HMACSecret: testHMACSecret,
+		Issuer:     "https://lynkly.test",
+		Audience:   "lynkly-api",
+		Leeway:     30 * time.Second,
+	})
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		change func(claims jwt.MapClaims)
+		valid  bool
+	}{
+		{"valid", func(jwt.MapClaims) {}, true},
+		{"expired within the leeway", func(c jwt.MapClaims) { c["exp"] = now.Add(-20 * time.Second).Unix() }, true},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() }, false},
+		{"no expiry", func(c jwt.MapClaims) { delete(c, "exp") }, false},
+		{"not valid yet within the leeway", func(c jwt.MapClaims) { c["nbf"] = now.Add(20 * time.Second).Unix() }, true},
+		{"not valid yet", func(c jwt.MapClaims) { c["nbf"] = now.Add(time.Minute).Unix() }, false},
+		{"other issuer", func(c jwt.MapClaims) { c["iss"] = "https://other.test" }, false},
+		{"no issuer", func(c jwt.MapClaims) { delete(c, "iss") }, false},
+		{"audience list", func(c jwt.MapClaims) { c["aud"] = []interface{}{"other", "lynkly-api"} }, true},
+		{"other audience", func(c jwt.MapClaims) { c["aud"] = []interface{}{"other"} }, false},
+		{"no audience", func(c jwt.MapClaims) { delete(c, "aud") }, false},
+	}
+
+	for _, test := range tests {
+		claims := validClaims()
+		test.change(claims)
+
+		_, err := authenticator.Validate(sign(t, jwt.SigningMethodHS256, testHMACSecret, claims))
+		if test.valid && err != nil {
+			t.Errorf("%s: expected the token to be valid, got %v", test.name, err)
+		} else if test.valid == false && errors.Is(err, ErrInvalidToken) == false {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", test.name, err)
+		}
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := map[string]error{
+		"Bearer abc.def.ghi": nil,
+		"Bearer ":            ErrMissingToken,
+		"Basic dXNlcjpwYXNz": ErrMissingToken,
+		"":                   ErrMissingToken,
+	}
+
+	for authorization, expected := range tests {
+		r := httptest.NewRequest("GET", "/api/v1/links", nil)
+		r.Header.Set(lhttp.AuthorizationHeader, authorization)
+
+		if _, err := BearerToken(r); err != expected {
+			t.Errorf("%q: expected %v, got %v", authorization, expected, err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"lynkly-backend/internal/logging"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	generatedKeyBits       = 2048
+	defaultRotationOverlap = time.Hour
+)
+
+// SigningKey is an RSA key signing the access tokens. Its ID is the RFC 7638 thumbprint of the public
+// key, so that a key loaded from a file keeps its ID across restarts.
+type SigningKey struct {
+	ID         string
+	PrivateKey *rsa.PrivateKey
+	CreatedAt  time.Time
+	// RetiredAt is set once the key stopped signing tokens. It is still published until the overlap
+	// has passed, so that the tokens it signed stay valid.
+	RetiredAt *time.Time
+}
+
+type KeyRingParams struct {
+	Logger logging.Logger
+	// Keys are loaded from files. The first key signs the tokens and the others are only published, e.g.
+	// the keys replaced by a manual rotation. A key is generated when there are none.
+	Keys []*rsa.PrivateKey
+	// RotationInterval is how often a new signing key is generated. Keys are not rotated when zero.
+	RotationInterval time.Duration
+	// Overlap is how long a retired key is still published. It has to cover the lifetime of the tokens.
+	Overlap time.Duration
+}
+
+// KeyRing holds the signing keys of the access tokens. With rotation, the next key is published one
+// interval before it starts signing, so that the verifiers caching the key set know it in time.
+type KeyRing struct {
+	logger           logging.Logger
+	rotationInterval time.Duration
+	overlap          time.Duration
+
+	mu      sync.RWMutex
+	current *SigningKey
+	next    *SigningKey
+	retired []*SigningKey
+}
+
+func NewKeyRing(params KeyRingParams) (*KeyRing, error) {
+	if params.Overlap <= 0 {
+		params.Overlap = defaultRotationOverlap
+	}
+
+	ring := &KeyRing{
+		logger:           params.Logger,
+		rotationInterval: params.RotationInterval,
+		overlap:          params.Overlap,
+	}
+
+	now := time.Now().UTC()
+	for i, privateKey := range params.Keys {
+		key, err := newSigningKey(privateKey, now)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			ring.current = key
+		} else {
+			// keys loaded as previous keys are published until the overlap has passed since the startup
+			key.RetiredAt = &now
+			ring.retired = append(ring.retired, key)
+		}
+	}
+
+	var err error
+	if ring.current == nil {
+		if ring.current, err = generateSigningKey(now); err != nil {
+			return nil, err
+		}
+	}
+
+	if ring.rotationInterval > 0 {
+		if ring.next, err = generateSigningKey(now); err != nil {
+			return nil, err
+		}
+	}
+
+	return ring, nil
+}
+
+// Current returns the key signing the tokens.
+func (k *KeyRing) Current() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.current
+}
+
+// PublicKey returns the published key with the ID, or nil when there is none.
+func (k *KeyRing) PublicKey(id string) *rsa.PublicKey {
+	for _, key := range k.Published() {
+		if key.ID == id {
+			return &key.PrivateKey.PublicKey
+		}
+	}
+
+	return nil
+}
+
+// Published returns the keys which verify tokens, the signing key first.
+func (k *KeyRing) Published() []*SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := []*SigningKey{k.current}
+	if k.next != nil {
+		keys = append(keys, k.next)
+	}
+
+	now := time.Now()
+	for _, key := range k.retired {
+		if now.Sub(*key.RetiredAt) < k.overlap {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Run rotates the keys at the rotation interval until the context is done.
+func (k *KeyRing) Run(ctx context.Context) {
+	if k.rotationInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(k.rotationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := k.Rotate(now.UTC()); err != nil {
+				k.logger.Error("Signing key rotation failed: ", err)
+			}
+		}
+	}
+}
+
+// Rotate retires the signing key in favour of the next one and drops the keys past their overlap.
+func (k *KeyRing) Rotate(now time.Time) error {
+	next, err := generateSigningKey(now)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	retired := []*SigningKey{k.current}
+	for _, key := range k.retired {
+		if now.Sub(*key.RetiredAt) < k.overlap {
+			retired = append(retired, key)
+		}
+	}
+	k.current.RetiredAt = &now
+	k.retired = retired
+
+	if k.next != nil {
+		k.current, k.next = k.next, next
+	} else {
+		k.current = next
+	}
+
+	k.logger.Info("Rotated the token signing key, signing with ", k.current.ID)
+	return nil
+}
+
+func generateSigningKey(now time.Time) (*SigningKey, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, generatedKeyBits)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSigningKey(privateKey, now)
+}
+
+func newSigningKey(privateKey *rsa.PrivateKey, now time.Time) (*SigningKey, error) {
+	if privateKey == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	return &SigningKey{
+		ID:         thumbprint(&privateKey.PublicKey),
+		PrivateKey: privateKey,
+		CreatedAt:  now,
+	}, nil
+}
+
+// JSONWebKey is the public part of an RSA signing key as published in the JWKS document.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the published keys as a JSON Web Key Set.
+func (k *KeyRing) JWKS() JSONWebKeySet {
+	published := k.Published()
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(published))}
+	for _, key := range published {
+		n, e := encodePublicKey(&key.PrivateKey.PublicKey)
+		set.Keys = append(set.Keys, JSONWebKey{
+			KeyType:   "RSA",
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: "RS256",
+			N:         n,
+			E:         e,
+		})
+	}
+
+	return set
+}
+
+func encodePublicKey(key *rsa.PublicKey) (string, string) {
+	return base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+}
+
+// thumbprint computes the RFC 7638 SHA-256 thumbprint of the key.
+func thumbprint(key *rsa.PublicKey) string {
+	n, e := encodePublicKey(key)
+	// the members are required in lexicographic order without whitespace
+	sum := sha256.Sum256([]byte(fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, e, n)))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
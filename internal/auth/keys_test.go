@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"lynkly-backend/internal/logging"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestKeyRing(t *testing.T, params KeyRingParams) *KeyRing {
+	t.Helper()
+
+	params.Logger = logging.NewLogger("auth_test")
+	ring, err := NewKeyRing(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ring
+}
+
+func publishedIDs(ring *KeyRing) map[string]bool {
+	ids := make(map[string]bool)
+	for _, key := range ring.JWKS().Keys {
+		ids[key.KeyID] = true
+	}
+
+	return ids
+}
+
+func TestKeyRotation(t *testing.T) {
+	ring := newTestKeyRing(t, KeyRingParams{RotationInterval: time.Hour, Overlap: time.Hour})
+	issuer := NewTokenIssuer(TokenIssuerParams{Keys: ring})
+	authenticator := NewJWTAuthenticator(JWTParams{Keys: ring})
+
+	first := ring.Current().ID
+	oldToken, err := issuer.Issue(IssueParams{Subject: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the next key is published one interval before it starts signing
+	published := publishedIDs(ring)
+	if len(published) != 2 || published[first] == false {
+		t.Fatalf("Expected the current and the next key to be published, got %v", published)
+	}
+
+	now := time.Now().UTC()
+	if err = ring.Rotate(now); err != nil {
+		t.Fatal(err)
+	}
+
+	second := ring.Current().ID
+	if second == first || published[second] == false {
+		t.Fatalf("Expected the published next key %v to sign after the rotation, got %s", published, second)
+	}
+
+	newToken, err := issuer.Issue(IssueParams{Subject: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := authenticator.Validate(newToken.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != second {
+		t.Errorf("Expected the token to be signed with %s, got %v", second, parsed.Header["kid"])
+	}
+
+	// the retired key verifies the tokens it signed until the overlap has passed
+	if _, err = authenticator.Validate(oldToken.Token); err != nil {
+		t.Errorf("Expected the token of the retired key to be valid, got %v", err)
+	}
+	if published = publishedIDs(ring); len(published) != 3 || published[first] == false {
+		t.Errorf("Expected the retired key to be published, got %v", published)
+	}
+
+	if err = ring.Rotate(now.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if published = publishedIDs(ring); published[first] {
+		t.Errorf("Expected the key past its overlap not to be published, got %v", published)
+	}
+	if _, err = authenticator.Validate(oldToken.Token); errors.Is(err, ErrInvalidToken) == false {
+		t.Errorf("Expected ErrInvalidToken for the token of a dropped key, got %v", err)
+	}
+}
+
+func TestUnknownKeyID(t *testing.T) {
+	ring := newTestKeyRing(t, KeyRingParams{})
+	authenticator := NewJWTAuthenticator(JWTParams{Keys: ring})
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"unknown key ID": "unknown",
+		// a token cannot borrow the ID of a published key
+		"published key ID": ring.Current().ID,
+	}
+
+	for name, keyID := range tests {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"sub": "user",
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = keyID
+		signed, err := token.SignedString(otherKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = authenticator.Validate(signed); errors.Is(err, ErrInvalidToken) == false {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadedKeysKeepTheirID(t *testing.T) {
+	current, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ring := newTestKeyRing(t, KeyRingParams{Keys: []*rsa.PrivateKey{current, previous}})
+	if ring.Current().ID != thumbprint(&current.PublicKey) {
+		t.Errorf("Expected the first key to sign")
+	}
+	if ring.PublicKey(thumbprint(&previous.PublicKey)) == nil {
+		t.Errorf("Expected the previous key to be published")
+	}
+}
+
+// TestThumbprint checks the example of RFC 7638, section 3.1.
+func TestThumbprint(t *testing.T) {
+	n, _ := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+
+	if id := thumbprint(key); id != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("Unexpected thumbprint %s", id)
+	}
+}
+
+func TestRunWithoutRotation(t *testing.T) {
+	ring := newTestKeyRing(t, KeyRingParams{})
+
+	done := make(chan struct{})
+	go func() {
+		ring.Run(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected Run to return when keys are not rotated")
+	}
+}
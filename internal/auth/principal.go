@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/logging"
+	"net/http"
+	"strings"
+)
+
+const (
+	ScopeLinksRead  = "links:read"
+	ScopeLinksWrite = "links:write"
+	ScopeStatsRead  = "stats:read"
+	// ScopeAccount covers managing the account itself, e.g. its API keys and workspace settings.
+	// It cannot be granted to API keys.
+	ScopeAccount = "account"
+
+	scopeClaim   = "scope"
+	sessionClaim = "sid"
+	tokenIDClaim = "jti"
+	methodsClaim = "amr"
+)
+
+// Authentication methods of a session, recorded in the "amr" claim of its tokens (RFC 8176)
+const (
+	AuthMethodPassword = "pwd"
+	AuthMethodOTP      = "otp"
+	AuthMethodSSO      = "sso"
+)
+
+// APIKeyScopes are the scopes which can be granted to API keys.
+var APIKeyScopes = []string{ScopeLinksRead, ScopeLinksWrite, ScopeStatsRead}
+
+func IsAPIKeyScope(scope string) bool {
+	return containsScope(APIKeyScopes, scope)
+}
+
+type Method string
+
+const (
+	MethodJWT    Method = "jwt"
+	MethodAPIKey Method = "api_key"
+)
+
+// Principal is the identity authenticated on a request.
+type Principal struct {
+	UserID string
+	Method Method
+	// Scopes restricts what the principal may do. User tokens without a "scope" claim are unrestricted.
+	Scopes   []string
+	APIKeyID string
+	// SessionID and TokenID identify the session and the access token of user tokens, so that they can
+	// be revoked.
+	SessionID string
+	TokenID   string
+	// AuthMethods are the methods the user logged in with, e.g. a password and a one-time code
+	AuthMethods []string
+	// WorkspaceID is the workspace the request is made in and Role is the role of the user in it. Once
+	// the workspace is resolved, the principal is further restricted to the scopes of the role, so a
+	// resolved principal without a workspace holds no scopes at all.
+	WorkspaceID       string
+	Role              Role
+	WorkspaceResolved bool
+}
+
+// Subject returns the ID of the user, it is logged as the client ID of the request.
+func (p *Principal) Subject() string {
+	return p.UserID
+}
+
+// Account returns the ID of the workspace, it is logged as the account ID of the request.
+func (p *Principal) Account() string {
+	return p.WorkspaceID
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	if p.WorkspaceResolved && containsScope(p.Role.Scopes(), scope) == false {
+		return false
+	}
+
+	if p.Method == MethodJWT && p.Scopes == nil {
+		return true
+	}
+
+	return containsScope(p.Scopes, scope)
+}
+
+// ActsAsUser reports whether the principal may manage the user itself, e.g. its password and its
+// workspaces. Unlike the scopes, it does not depend on the role in the current workspace.
+func (p *Principal) ActsAsUser() bool {
+	return p.Method == MethodJWT && (p.Scopes == nil || containsScope(p.Scopes, ScopeAccount))
+}
+
+// HasSecondFactor reports whether the user verified a one-time code when logging in.
+func (p *Principal) HasSecondFactor() bool {
+	for _, method := range p.AuthMethods {
+		if method == AuthMethodOTP {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PrincipalFrom returns the principal authenticated on the request, or nil for anonymous requests.
+func PrincipalFrom(r *http.Request) *Principal {
+	switch user := common.ContextGet(r, logging.UserKey).(type) {
+	case *Principal:
+		return user
+	case *jwt.Token:
+		return principalFromToken(user)
+	}
+
+	return nil
+}
+
+func principalFromToken(token *jwt.Token) *Principal {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if ok == false {
+		return nil
+	}
+
+	principal := &Principal{Method: MethodJWT}
+	principal.UserID, _ = claims[logging.SubKey].(string)
+	principal.SessionID, _ = claims[sessionClaim].(string)
+	principal.TokenID, _ = claims[tokenIDClaim].(string)
+	if methods, ok := claims[methodsClaim].([]interface{}); ok {
+		for _, method := range methods {
+			if method, ok := method.(string); ok {
+				principal.AuthMethods = append(principal.AuthMethods, method)
+			}
+		}
+	}
+	if scope, ok := claims[scopeClaim].(string); ok {
+		principal.Scopes = strings.Fields(scope)
+	}
+
+	return principal
+}
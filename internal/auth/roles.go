@@ -0,0 +1,41 @@
+package auth
+
+// Role is a named set of scopes granted to a member of a workspace.
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+var roleScopes = map[Role][]string{
+	RoleOwner:  {ScopeLinksRead, ScopeLinksWrite, ScopeStatsRead, ScopeAccount},
+	RoleAdmin:  {ScopeLinksRead, ScopeLinksWrite, ScopeStatsRead, ScopeAccount},
+	RoleEditor: {ScopeLinksRead, ScopeLinksWrite, ScopeStatsRead},
+	RoleViewer: {ScopeLinksRead, ScopeStatsRead},
+}
+
+// roleRanks orders the roles from the least to the most privileged.
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+func (r Role) IsValid() bool {
+	_, ok := roleScopes[r]
+	return ok
+}
+
+// Scopes returns the scopes granted by the role.
+func (r Role) Scopes() []string {
+	return roleScopes[r]
+}
+
+// Outranks reports whether the role is more privileged than the other role.
+func (r Role) Outranks(other Role) bool {
+	return roleRanks[r] > roleRanks[other]
+}
@@ -0,0 +1,138 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"lynkly-backend/internal/logging"
+	"net/http"
+	"os"
+	"time"
+)
+
+const defaultRenewBefore = 30 * 24 * time.Hour
+
+type ACMEParams struct {
+	Logger logging.Logger
+	// DirectoryURL defaults to Let's Encrypt, e.g. "https://localhost:14000/dir" for a local Pebble server
+	DirectoryURL string
+	// Email is the contact of the ACME account
+	Email string
+	// CARootsFile is a PEM file of the CAs trusted for the connections to the directory, e.g. the
+	// certificate of a local Pebble server. The CAs of the system are trusted when it is empty.
+	CARootsFile string
+	// RenewBefore is how long before their expiry the certificates are renewed, 30 days by default
+	RenewBefore time.Duration
+	// HostPolicy decides which hosts certificates are obtained for
+	HostPolicy func(ctx context.Context, host string) error
+	// Store caches the account key and the certificates
+	Store Store
+}
+
+// ACME obtains certificates on the first TLS handshake of a host accepted by the host policy and
+// renews them before they expire.
+type ACME struct {
+	logger     logging.Logger
+	manager    *autocert.Manager
+	hostPolicy func(ctx context.Context, host string) error
+	store      Store
+}
+
+func NewACME(params ACMEParams) (*ACME, error) {
+	if params.DirectoryURL == "" {
+		params.DirectoryURL = autocert.DefaultACMEDirectory
+	}
+	if params.RenewBefore <= 0 {
+		params.RenewBefore = defaultRenewBefore
+	}
+
+	// the default client of the ACME package is used without custom CAs
+	var httpClient *http.Client
+	if params.CARootsFile != "" {
+		roots, err := os.ReadFile(params.CARootsFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if pool.AppendCertsFromPEM(roots) == false {
+			return nil, errors.New("no certificates found in " + params.CARootsFile)
+		}
+		httpClient = &http.Client{
+			Timeout:   time.Minute,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		}
+	}
+
+	return &ACME{
+		logger: params.Logger,
+		manager: &autocert.Manager{
+			Prompt:      autocert.AcceptTOS,
+			Cache:       cache{store: params.Store},
+			HostPolicy:  params.HostPolicy,
+			RenewBefore: params.RenewBefore,
+			Email:       params.Email,
+			Client: &acme.Client{
+				DirectoryURL: params.DirectoryURL,
+				HTTPClient:   httpClient,
+			},
+		},
+		hostPolicy: params.HostPolicy,
+		store:      params.Store,
+	}, nil
+}
+
+// Accepts reports whether the certificate of the host is obtained from ACME.
+func (a *ACME) Accepts(ctx context.Context, host string) bool {
+	return host != "" && a.hostPolicy(ctx, host) == nil
+}
+
+func (a *ACME) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certificate, err := a.manager.GetCertificate(hello)
+	if err != nil {
+		a.logger.Error("Failed to get the ACME certificate of ", hello.ServerName, ": ", err)
+	}
+
+	return certificate, err
+}
+
+// HTTPHandler answers the HTTP-01 challenges and passes every other request to the fallback.
+func (a *ACME) HTTPHandler(fallback http.Handler) http.Handler {
+	return a.manager.HTTPHandler(fallback)
+}
+
+// Forget removes the cached certificates of the host, e.g. when the host is no longer served.
+func (a *ACME) Forget(ctx context.Context, host string) error {
+	// the certificates are cached per key type
+	for _, key := range []string{host, host + "+rsa"} {
+		if err := a.store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cache adapts the store to the cache of autocert
+type cache struct {
+	store Store
+}
+
+func (c cache) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.store.Get(ctx, key)
+	if err == ErrNotFound {
+		return nil, autocert.ErrCacheMiss
+	}
+
+	return data, err
+}
+
+func (c cache) Put(ctx context.Context, key string, data []byte) error {
+	return c.store.Put(ctx, key, data)
+}
+
+func (c cache) Delete(ctx context.Context, key string) error {
+	return c.store.Delete(ctx, key)
+}
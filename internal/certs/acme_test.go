@@ -0,0 +1,121 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"lynkly-backend/internal/logging"
+	"testing"
+)
+
+var errNotAccepted = errors.New("not accepted")
+
+func newTestACME(t *testing.T, store Store) *ACME {
+	t.Helper()
+
+	a, err := NewACME(ACMEParams{
+		Logger: logging.NewLogger("certs_test"),
+		// the tests never reach the directory
+		DirectoryURL: "http://127.0.0.1:0/dir",
+		HostPolicy: func(_ context.Context, host string) error {
+			if host != "links.example.com" {
+				return errNotAccepted
+			}
+			return nil
+		},
+		Store: store,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a
+}
+
+func TestCacheMiss(t *testing.T) {
+	c := cache{store: NewMemoryStore()}
+
+	if _, err := c.Get(context.Background(), "links.example.com"); err != autocert.ErrCacheMiss {
+		t.Errorf("Expected autocert.ErrCacheMiss, got %v", err)
+	}
+
+	if err := c.Put(context.Background(), "links.example.com", []byte("certificate")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := c.Get(context.Background(), "links.example.com"); err != nil || string(data) != "certificate" {
+		t.Errorf("Expected the cached certificate, got %q, %v", data, err)
+	}
+}
+
+func TestForget(t *testing.T) {
+	store := NewMemoryStore()
+	a := newTestACME(t, store)
+
+	for _, key := range []string{"links.example.com", "links.example.com+rsa", "other.example.com"} {
+		if err := store.Put(context.Background(), key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := a.Forget(context.Background(), "links.example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, kept := range map[string]bool{
+		"links.example.com":     false,
+		"links.example.com+rsa": false,
+		"other.example.com":     true,
+	} {
+		if _, err := store.Get(context.Background(), key); (err == nil) != kept {
+			t.Errorf("%s: expected kept %v, got %v", key, kept, err)
+		}
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	a := newTestACME(t, NewMemoryStore())
+
+	config, err := NewServerConfig(ServerParams{MinVersion: "1.3", ACME: a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3, got %x", config.MinVersion)
+	}
+	if len(config.NextProtos) != 3 || config.NextProtos[2] != acme.ALPNProto {
+		t.Errorf("Expected the TLS-ALPN-01 protocol, got %v", config.NextProtos)
+	}
+
+	// the hosts the policy rejects are not passed to ACME
+	hello := &tls.ClientHelloInfo{ServerName: "other.example.com"}
+	if _, err = config.GetCertificate(hello); err != errNoCertificate {
+		t.Errorf("Expected errNoCertificate, got %v", err)
+	}
+	if a.Accepts(context.Background(), "") || a.Accepts(context.Background(), "links.example.com") == false {
+		t.Error("Expected only the hosts of the policy to be accepted")
+	}
+}
+
+func TestServerConfigRejectsInvalidParams(t *testing.T) {
+	tests := map[string]ServerParams{
+		"old version":     {MinVersion: "1.0"},
+		"unknown cipher":  {CipherSuites: []string{"TLS_UNKNOWN"}},
+		"insecure cipher": {CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+	}
+
+	for name, params := range tests {
+		if _, err := NewServerConfig(params); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	config, err := NewServerConfig(ServerParams{CipherSuites: []string{" TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS12 || len(config.CipherSuites) != 1 {
+		t.Errorf("Expected TLS 1.2 with one cipher suite, got %x, %v", config.MinVersion, config.CipherSuites)
+	}
+}
@@ -0,0 +1,133 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"lynkly-backend/internal/logging"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultReloadInterval = time.Minute
+
+type Params struct {
+	Logger   logging.Logger
+	CertFile string
+	KeyFile  string
+	// ReloadInterval is how often the files are checked for changes
+	ReloadInterval time.Duration
+}
+
+// Certificates serves a certificate loaded from PEM files and reloads it when the files change, so
+// that renewed certificates are used without a restart.
+type Certificates struct {
+	logger         logging.Logger
+	certFile       string
+	keyFile        string
+	reloadInterval time.Duration
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	modTimes    [2]time.Time
+}
+
+// New loads the certificate and fails when it cannot be loaded.
+func New(params Params) (*Certificates, error) {
+	if params.ReloadInterval <= 0 {
+		params.ReloadInterval = defaultReloadInterval
+	}
+
+	certificates := &Certificates{
+		logger:         params.Logger,
+		certFile:       params.CertFile,
+		keyFile:        params.KeyFile,
+		reloadInterval: params.ReloadInterval,
+	}
+	if err := certificates.load(); err != nil {
+		return nil, err
+	}
+
+	return certificates, nil
+}
+
+func (c *Certificates) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.certificate, nil
+}
+
+// Run reloads the certificate when its files change until the context is cancelled. The current
+// certificate is kept when the new one cannot be loaded, e.g. while only one of the files is replaced.
+func (c *Certificates) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if c.changed() == false {
+				continue
+			}
+
+			if err := c.load(); err != nil {
+				c.logger.Error("Failed to reload the TLS certificate: ", err)
+			}
+		}
+	}
+}
+
+func (c *Certificates) changed() bool {
+	modTimes, err := c.stat()
+	if err != nil {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return modTimes != c.modTimes
+}
+
+func (c *Certificates) stat() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+func (c *Certificates) load() error {
+	// the times are taken first, so that a change during the load is picked up by the next check
+	modTimes, err := c.stat()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	if certificate.Leaf, err = x509.ParseCertificate(certificate.Certificate[0]); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.certificate = &certificate
+	c.modTimes = modTimes
+	c.logger.Info("Loaded the TLS certificate for ", strings.Join(certificate.Leaf.DNSNames, ", "),
+		" valid until ", certificate.Leaf.NotAfter.UTC().Format(time.RFC3339))
+	return nil
+}
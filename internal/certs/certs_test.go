@@ -0,0 +1,152 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"lynkly-backend/internal/logging"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self-signed certificate for the host and its key as PEM files. The modification
+// times are moved forward, since they may not change within the resolution of the file system.
+func writeKeyPair(t *testing.T, certFile, keyFile, host string, modTime time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM(t, certFile, "CERTIFICATE", certificate, modTime)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyBytes, modTime)
+}
+
+func writePEM(t *testing.T, file, blockType string, data []byte, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// servedHost returns the host of the certificate served by GetCertificate
+func servedHost(t *testing.T, c *Certificates) string {
+	t.Helper()
+
+	certificate, err := c.GetCertificate(nil)
+	if err != nil || certificate == nil || certificate.Leaf == nil {
+		t.Fatalf("Expected a certificate, got %v, %v", certificate, err)
+	}
+
+	return certificate.Leaf.DNSNames[0]
+}
+
+// waitForHost polls GetCertificate until it serves the certificate of the host
+func waitForHost(t *testing.T, c *Certificates, host string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for servedHost(t, c) != host {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the certificate of %s to be served, got %s", host, servedHost(t, c))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	modTime := time.Now().Add(-time.Hour)
+	writeKeyPair(t, certFile, keyFile, "old.example.com", modTime)
+
+	c, err := New(Params{
+		Logger:         logging.NewLogger("certs_test"),
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ReloadInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host := servedHost(t, c); host != "old.example.com" {
+		t.Fatalf("Expected the certificate of old.example.com, got %s", host)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	writeKeyPair(t, certFile, keyFile, "new.example.com", modTime.Add(time.Minute))
+	waitForHost(t, c, "new.example.com")
+
+	// a certificate which does not match the key yet keeps the current pair until the key is replaced
+	otherDir := t.TempDir()
+	otherKeyFile := filepath.Join(otherDir, "key.pem")
+	writeKeyPair(t, certFile, otherKeyFile, "renewed.example.com", modTime.Add(2*time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if host := servedHost(t, c); host != "new.example.com" {
+		t.Fatalf("Expected the current certificate to be kept, got %s", host)
+	}
+
+	key, err := os.ReadFile(otherKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chtimes(keyFile, modTime.Add(3*time.Minute), modTime.Add(3*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	waitForHost(t, c, "renewed.example.com")
+}
+
+func TestNewFailsWithoutCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	if _, err := New(Params{CertFile: certFile, KeyFile: keyFile}); err == nil {
+		t.Error("Expected missing files to be rejected")
+	}
+
+	writeKeyPair(t, certFile, keyFile, "example.com", time.Now())
+	writeKeyPair(t, certFile, filepath.Join(dir, "other.pem"), "example.com", time.Now())
+	if _, err := New(Params{CertFile: certFile, KeyFile: keyFile}); err == nil {
+		t.Error("Expected a certificate which does not match the key to be rejected")
+	}
+}
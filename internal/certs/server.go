@@ -0,0 +1,91 @@
+package certs
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/acme"
+	"strings"
+)
+
+var errNoCertificate = errors.New("no certificate for the server name")
+
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+type ServerParams struct {
+	// MinVersion is "1.2" or "1.3", TLS 1.2 by default
+	MinVersion string
+	// CipherSuites are the names of the cipher suites allowed for TLS 1.2, e.g.
+	// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". The defaults of Go are used when empty. The cipher suites
+	// of TLS 1.3 are not configurable.
+	CipherSuites []string
+	// Certificates is the certificate of the service, nil when only ACME certificates are served
+	Certificates *Certificates
+	// ACME obtains the certificates of the hosts its policy accepts, nil when ACME is disabled
+	ACME *ACME
+}
+
+// NewServerConfig returns the TLS configuration of the server. The hosts accepted by the ACME host
+// policy are served their ACME certificates, every other host the certificate of the service.
+func NewServerConfig(params ServerParams) (*tls.Config, error) {
+	if params.MinVersion == "" {
+		params.MinVersion = "1.2"
+	}
+
+	minVersion, ok := versions[params.MinVersion]
+	if ok == false {
+		return nil, fmt.Errorf("unsupported minimum TLS version %s - expected 1.2 or 1.3", params.MinVersion)
+	}
+
+	cipherSuites, err := parseCipherSuites(params.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if params.ACME != nil && params.ACME.Accepts(hello.Context(), hello.ServerName) {
+				return params.ACME.GetCertificate(hello)
+			}
+			if params.Certificates != nil {
+				return params.Certificates.GetCertificate(hello)
+			}
+
+			return nil, errNoCertificate
+		},
+	}
+	if params.ACME != nil {
+		// the TLS-ALPN-01 challenges are answered during the handshake
+		config.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+	}
+
+	return config, nil
+}
+
+// parseCipherSuites returns the IDs of the named cipher suites. Insecure cipher suites are refused.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	ids := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		ids[suite.Name] = suite.ID
+	}
+
+	result := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := ids[strings.TrimSpace(name)]
+		if ok == false {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %s", name)
+		}
+		result = append(result, id)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,59 @@
+package certs
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrNotFound = errors.New("certificate data not found")
+
+// Store keeps the ACME account key and the certificates obtained from ACME, so that they survive
+// restarts and are shared by the instances of the service.
+type Store interface {
+	// Get returns the data with the key or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	// Delete removes the data with the key, a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		data: make(map[string][]byte),
+	}
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.data[key]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	return append([]byte(nil), data...), nil
+}
+
+func (s *memoryStore) Put(_ context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data, key)
+	return nil
+}
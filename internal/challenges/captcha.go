@@ -0,0 +1,104 @@
+package challenges
+
+import (
+	"context"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCaptchaTimeout = 5 * time.Second
+	maxCaptchaResponse    = 4096
+)
+
+type CaptchaParams struct {
+	// VerifyURL is the siteverify endpoint of the provider, e.g. "https://api.hcaptcha.com/siteverify"
+	// or the URL of a local mock
+	VerifyURL string
+	Secret    string
+	SiteKey   string
+	// HTTPClient defaults to a client with a 5 seconds timeout
+	HTTPClient *http.Client
+}
+
+// Captcha checks the tokens of a CAPTCHA widget with the siteverify API shared by hCaptcha, Cloudflare
+// Turnstile and reCAPTCHA.
+type Captcha struct {
+	verifyURL  string
+	secret     string
+	siteKey    string
+	httpClient *http.Client
+}
+
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+func NewCaptcha(params CaptchaParams) *Captcha {
+	if params.HTTPClient == nil {
+		params.HTTPClient = &http.Client{Timeout: defaultCaptchaTimeout}
+	}
+
+	return &Captcha{
+		verifyURL:  params.VerifyURL,
+		secret:     params.Secret,
+		siteKey:    params.SiteKey,
+		httpClient: params.HTTPClient,
+	}
+}
+
+func (c *Captcha) Challenge() (*Challenge, error) {
+	return &Challenge{
+		Type:    TypeCaptcha,
+		SiteKey: c.siteKey,
+	}, nil
+}
+
+func (c *Captcha) Verify(ctx context.Context, response, remoteIP string) error {
+	if response == "" {
+		return ErrMissingResponse
+	}
+	if len(response) > maxCaptchaResponse {
+		return ErrInvalidResponse
+	}
+
+	form := url.Values{
+		"secret":   {c.secret},
+		"response": {response},
+		"sitekey":  {c.siteKey},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: siteverify responded with %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var result siteVerifyResponse
+	if err = jsoniter.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if result.Success == false {
+		return ErrInvalidResponse
+	}
+
+	return nil
+}
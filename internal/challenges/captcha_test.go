@@ -0,0 +1,76 @@
+package challenges
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newFakeSiteVerify accepts the response "good" sent with the secret "secret".
+func newFakeSiteVerify(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.PostFormValue("sitekey") != "site-key" {
+			t.Errorf("Unexpected siteverify request %s %v", r.Method, r.PostForm)
+		}
+		if r.PostFormValue("remoteip") != "203.0.113.7" {
+			t.Errorf("Expected the remote IP to be passed on, got %q", r.PostFormValue("remoteip"))
+		}
+
+		w.WriteHeader(status)
+		if r.PostFormValue("secret") == "secret" && r.PostFormValue("response") == "good" {
+			w.Write([]byte(`{"success": true}`))
+		} else {
+			w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCaptcha(t *testing.T) {
+	server := newFakeSiteVerify(t, http.StatusOK)
+	captcha := NewCaptcha(CaptchaParams{VerifyURL: server.URL, Secret: "secret", SiteKey: "site-key"})
+
+	challenge, err := captcha.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.Type != TypeCaptcha || challenge.SiteKey != "site-key" {
+		t.Errorf("Unexpected challenge %+v", challenge)
+	}
+
+	tests := map[string]struct {
+		response string
+		err      error
+	}{
+		"good":      {"good", nil},
+		"bad":       {"bad", ErrInvalidResponse},
+		"missing":   {"", ErrMissingResponse},
+		"too large": {strings.Repeat("a", maxCaptchaResponse+1), ErrInvalidResponse},
+	}
+
+	for name, test := range tests {
+		if err = captcha.Verify(context.Background(), test.response, "203.0.113.7"); err != test.err {
+			t.Errorf("%s: expected %v, got %v", name, test.err, err)
+		}
+	}
+}
+
+func TestCaptchaProviderDown(t *testing.T) {
+	failing := newFakeSiteVerify(t, http.StatusServiceUnavailable)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for name, verifyURL := range map[string]string{"failing": failing.URL, "unreachable": closed.URL} {
+		captcha := NewCaptcha(CaptchaParams{VerifyURL: verifyURL, Secret: "secret", SiteKey: "site-key"})
+		if err := captcha.Verify(context.Background(), "good", "203.0.113.7"); errors.Is(err, ErrUnavailable) == false {
+			t.Errorf("%s: expected ErrUnavailable, got %v", name, err)
+		}
+	}
+}
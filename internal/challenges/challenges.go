@@ -0,0 +1,42 @@
+package challenges
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ResponseHeader carries the response of a client to the challenge
+const ResponseHeader = "X-Challenge-Response"
+
+const (
+	TypeProofOfWork = "pow"
+	TypeCaptcha     = "captcha"
+)
+
+var (
+	ErrMissingResponse = errors.New("missing challenge response - request a challenge and send its solution in the " + ResponseHeader + " header")
+	ErrInvalidResponse = errors.New("invalid challenge response")
+	ErrExpired         = errors.New("challenge has expired")
+	ErrAlreadyUsed     = errors.New("challenge has already been used")
+	// ErrUnavailable is returned when the response cannot be checked, e.g. when the CAPTCHA provider is down
+	ErrUnavailable = errors.New("challenge verification is not available")
+)
+
+// Challenge is what a client has to solve before creating a link anonymously.
+type Challenge struct {
+	Type string `json:"type"`
+	// Challenge and Difficulty are set for proofs of work
+	Challenge  string     `json:"challenge,omitempty"`
+	Difficulty int        `json:"difficulty,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	// SiteKey is set for CAPTCHAs, the widget of the provider is rendered with it
+	SiteKey string `json:"siteKey,omitempty"`
+}
+
+// Verifier issues challenges and checks the responses of the clients to them.
+type Verifier interface {
+	Challenge() (*Challenge, error)
+	// Verify checks the response sent in ResponseHeader. The remote IP is passed on to CAPTCHA providers.
+	Verify(ctx context.Context, response, remoteIP string) error
+}
@@ -0,0 +1,146 @@
+package challenges
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"lynkly-backend/internal/common"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDifficulty = 20
+	maxDifficulty     = 32
+	defaultTTL        = 5 * time.Minute
+	signatureSize     = 16
+	// maxCounterLength bounds the part of the response chosen by the client
+	maxCounterLength = 32
+)
+
+type ProofOfWorkParams struct {
+	Secret []byte
+	// Difficulty is the number of leading zero bits of the hash of a solution, 20 by default
+	Difficulty int
+	// TTL is how long a challenge can be solved, 5 minutes by default
+	TTL time.Duration
+	// Spent remembers the solved challenges until they expire, so that a solution is accepted once
+	Spent SpentStore
+}
+
+// ProofOfWork issues signed challenges, which are not stored. A client solves a challenge by finding
+// a counter for which the SHA-256 hash of "<challenge>:<counter>" starts with Difficulty zero bits and
+// responds with "<challenge>:<counter>".
+type ProofOfWork struct {
+	secret     []byte
+	difficulty int
+	ttl        time.Duration
+	spent      SpentStore
+}
+
+func NewProofOfWork(params ProofOfWorkParams) *ProofOfWork {
+	if params.Difficulty <= 0 {
+		params.Difficulty = defaultDifficulty
+	}
+	if params.Difficulty > maxDifficulty {
+		params.Difficulty = maxDifficulty
+	}
+	if params.TTL <= 0 {
+		params.TTL = defaultTTL
+	}
+
+	return &ProofOfWork{
+		secret:     params.Secret,
+		difficulty: params.Difficulty,
+		ttl:        params.TTL,
+		spent:      params.Spent,
+	}
+}
+
+func (p *ProofOfWork) Challenge() (*Challenge, error) {
+	expiresAt := time.Now().Add(p.ttl).UTC().Truncate(time.Second)
+	content := fmt.Sprintf("%d.%s.%s", p.difficulty, strconv.FormatInt(expiresAt.Unix(), 36), common.RandomHex(16))
+
+	return &Challenge{
+		Type:       TypeProofOfWork,
+		Challenge:  content + "." + p.sign(content),
+		Difficulty: p.difficulty,
+		ExpiresAt:  &expiresAt,
+	}, nil
+}
+
+func (p *ProofOfWork) Verify(ctx context.Context, response, _ string) error {
+	if response == "" {
+		return ErrMissingResponse
+	}
+
+	challenge, counter, found := strings.Cut(response, ":")
+	if found == false || counter == "" || len(counter) > maxCounterLength {
+		return ErrInvalidResponse
+	}
+
+	separator := strings.LastIndexByte(challenge, '.')
+	if separator < 0 {
+		return ErrInvalidResponse
+	}
+	content, signature := challenge[:separator], challenge[separator+1:]
+	if hmac.Equal([]byte(signature), []byte(p.sign(content))) == false {
+		return ErrInvalidResponse
+	}
+
+	parts := strings.Split(content, ".")
+	if len(parts) != 3 {
+		return ErrInvalidResponse
+	}
+	difficulty, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return ErrInvalidResponse
+	}
+	unix, err := strconv.ParseInt(parts[1], 36, 64)
+	if err != nil {
+		return ErrInvalidResponse
+	}
+
+	expiresAt := time.Unix(unix, 0).UTC()
+	if time.Now().After(expiresAt) {
+		return ErrExpired
+	}
+
+	// challenges issued before the difficulty was raised are not accepted anymore
+	hash := sha256.Sum256([]byte(response))
+	if difficulty < p.difficulty || leadingZeroBits(hash[:]) < difficulty {
+		return ErrInvalidResponse
+	}
+
+	spent, err := p.spent.Spend(ctx, challenge, expiresAt)
+	if err != nil {
+		return err
+	}
+	if spent == false {
+		return ErrAlreadyUsed
+	}
+
+	return nil
+}
+
+func (p *ProofOfWork) sign(content string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(content))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureSize])
+}
+
+func leadingZeroBits(hash []byte) int {
+	count := 0
+	for _, b := range hash {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+
+	return count
+}
@@ -0,0 +1,153 @@
+package challenges
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDifficulty = 8
+
+func newTestProofOfWork() *ProofOfWork {
+	return NewProofOfWork(ProofOfWorkParams{
+		Secret:     []byte("secret"),
+		Difficulty: testDifficulty,
+		Spent:      NewMemorySpentStore(),
+	})
+}
+
+// solve returns the response with the first counter from start solving the challenge.
+func solve(t *testing.T, challenge string, difficulty, start int) (string, int) {
+	t.Helper()
+
+	for counter := start; counter < start+1<<24; counter++ {
+		response := challenge + ":" + strconv.Itoa(counter)
+		hash := sha256.Sum256([]byte(response))
+		if leadingZeroBits(hash[:]) >= difficulty {
+			return response, counter
+		}
+	}
+
+	t.Fatal("No solution found")
+	return "", 0
+}
+
+func TestProofOfWork(t *testing.T) {
+	pow := newTestProofOfWork()
+
+	challenge, err := pow.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.Type != TypeProofOfWork || challenge.Difficulty != testDifficulty || challenge.ExpiresAt == nil {
+		t.Fatalf("Unexpected challenge %+v", challenge)
+	}
+
+	response, counter := solve(t, challenge.Challenge, challenge.Difficulty, 0)
+	if err = pow.Verify(context.Background(), response, ""); err != nil {
+		t.Fatalf("Expected the solution to be accepted, got %v", err)
+	}
+
+	if err = pow.Verify(context.Background(), response, ""); err != ErrAlreadyUsed {
+		t.Errorf("Expected ErrAlreadyUsed for a replayed solution, got %v", err)
+	}
+
+	// the challenge is spent, not the solution
+	other, _ := solve(t, challenge.Challenge, challenge.Difficulty, counter+1)
+	if err = pow.Verify(context.Background(), other, ""); err != ErrAlreadyUsed {
+		t.Errorf("Expected ErrAlreadyUsed for another solution of a used challenge, got %v", err)
+	}
+}
+
+func TestProofOfWorkRejectsInvalidResponses(t *testing.T) {
+	pow := newTestProofOfWork()
+
+	challenge, err := pow.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := challenge.Challenge[:strings.LastIndexByte(challenge.Challenge, '.')]
+	parts := strings.Split(content, ".")
+
+	// a lower difficulty cannot be signed without the secret
+	easier := fmt.Sprintf("0.%s.%s", parts[1], parts[2])
+	easier += challenge.Challenge[len(content):]
+
+	var unsolved string
+	for counter := 0; ; counter++ {
+		unsolved = challenge.Challenge + ":" + strconv.Itoa(counter)
+		hash := sha256.Sum256([]byte(unsolved))
+		if leadingZeroBits(hash[:]) < testDifficulty {
+			break
+		}
+	}
+
+	tests := map[string]struct {
+		response string
+		err      error
+	}{
+		"empty":               {"", ErrMissingResponse},
+		"no counter":          {challenge.Challenge, ErrInvalidResponse},
+		"empty counter":       {challenge.Challenge + ":", ErrInvalidResponse},
+		"long counter":        {challenge.Challenge + ":" + strings.Repeat("1", maxCounterLength+1), ErrInvalidResponse},
+		"not solved":          {unsolved, ErrInvalidResponse},
+		"lowered difficulty":  {easier + ":0", ErrInvalidResponse},
+		"no signature":        {content + ":0", ErrInvalidResponse},
+		"foreign signature":   {content + "." + strings.Repeat("A", 22) + ":0", ErrInvalidResponse},
+		"not a challenge":     {"abc:0", ErrInvalidResponse},
+		"signed by other key": {otherChallenge(t) + ":0", ErrInvalidResponse},
+	}
+
+	for name, test := range tests {
+		if err = pow.Verify(context.Background(), test.response, ""); err != test.err {
+			t.Errorf("%s: expected %v, got %v", name, test.err, err)
+		}
+	}
+}
+
+func otherChallenge(t *testing.T) string {
+	t.Helper()
+
+	challenge, err := NewProofOfWork(ProofOfWorkParams{Secret: []byte("other"), Difficulty: 1}).Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return challenge.Challenge
+}
+
+func TestProofOfWorkExpiry(t *testing.T) {
+	pow := newTestProofOfWork()
+
+	expiresAt := time.Now().Add(-time.Second).Unix()
+	content := fmt.Sprintf("%d.%s.%s", testDifficulty, strconv.FormatInt(expiresAt, 36), "0123456789abcdef")
+	response, _ := solve(t, content+"."+pow.sign(content), testDifficulty, 0)
+
+	if err := pow.Verify(context.Background(), response, ""); err != ErrExpired {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+}
+
+func TestProofOfWorkRaisedDifficulty(t *testing.T) {
+	pow := newTestProofOfWork()
+
+	challenge, err := pow.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, _ := solve(t, challenge.Challenge, testDifficulty+4, 0)
+
+	// the challenges issued before the difficulty was raised are refused even when solved well enough
+	raised := NewProofOfWork(ProofOfWorkParams{
+		Secret:     []byte("secret"),
+		Difficulty: testDifficulty + 4,
+		Spent:      NewMemorySpentStore(),
+	})
+	if err = raised.Verify(context.Background(), response, ""); err != ErrInvalidResponse {
+		t.Errorf("Expected ErrInvalidResponse, got %v", err)
+	}
+}
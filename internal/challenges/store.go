@@ -0,0 +1,48 @@
+package challenges
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SpentStore remembers the solved proof-of-work challenges until they expire.
+type SpentStore interface {
+	// Spend marks the challenge as used and reports false when it has been used before.
+	Spend(ctx context.Context, challenge string, expiresAt time.Time) (bool, error)
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memorySpentStore struct {
+	mu        sync.Mutex
+	spent     map[string]time.Time
+	lastPrune time.Time
+}
+
+func NewMemorySpentStore() SpentStore {
+	return &memorySpentStore{
+		spent: make(map[string]time.Time),
+	}
+}
+
+func (s *memorySpentStore) Spend(_ context.Context, challenge string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	// expired challenges are refused before they are looked up, so they can be forgotten
+	if now.Sub(s.lastPrune) > time.Minute {
+		for key, expiry := range s.spent {
+			if now.After(expiry) {
+				delete(s.spent, key)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	if _, ok := s.spent[challenge]; ok {
+		return false, nil
+	}
+	s.spent[challenge] = expiresAt
+	return true, nil
+}
@@ -0,0 +1,22 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewID returns a random 128-bit identifier encoded as hex.
+func NewID() string {
+	return RandomHex(16)
+}
+
+// RandomHex returns n random bytes from crypto/rand encoded as hex. It panics if the system
+// random number generator fails, as nothing can be safely generated without it.
+func RandomHex(n int) string {
+	buffer := make([]byte, n)
+	if _, err := rand.Read(buffer); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(buffer)
+}
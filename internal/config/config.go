@@ -1,6 +1,11 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // MongoConfig Config holds configuration settings for the application.
 type MongoConfig struct {
@@ -24,6 +29,480 @@ func NewServerConfig() *ServerConfig {
 	}
 }
 
+// AuthConfig holds the settings for issuing and validating the bearer tokens of the management API.
+// HS256 tokens are accepted when JWTSecret is set and RS256 tokens when JWTPublicKeyFile
+// points to a PEM encoded RSA public key. The tokens of the users are signed with the RSA key in
+// JWTPrivateKeyFile when it is set, with JWTSecret when only the secret is set and with a key generated
+// at startup otherwise. JWTPreviousKeyFiles are still published and accepted after a manual rotation.
+// Generated keys are rotated every JWTKeyRotationInterval, which is disabled when zero, and retired keys
+// are published for JWTKeyRotationOverlap.
+type AuthConfig struct {
+	JWTSecret              string
+	JWTPublicKeyFile       string
+	JWTPrivateKeyFile      string
+	JWTPreviousKeyFiles    []string
+	JWTKeyRotationInterval time.Duration
+	JWTKeyRotationOverlap  time.Duration
+	JWTIssuer              string
+	JWTAudience            string
+	JWTLeeway              time.Duration
+	AccessTokenTTL         time.Duration
+}
+
+func NewAuthConfig() *AuthConfig {
+	return &AuthConfig{
+		JWTSecret:              getEnv("JWT_SECRET", ""),
+		JWTPublicKeyFile:       getEnv("JWT_PUBLIC_KEY_FILE", ""),
+		JWTPrivateKeyFile:      getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTPreviousKeyFiles:    strings.FieldsFunc(getEnv("JWT_PREVIOUS_KEY_FILES", ""), isComma),
+		JWTKeyRotationInterval: getEnvDuration("JWT_KEY_ROTATION_INTERVAL", 0),
+		JWTKeyRotationOverlap:  getEnvDuration("JWT_KEY_ROTATION_OVERLAP", time.Hour),
+		JWTIssuer:              getEnv("JWT_ISSUER", ""),
+		JWTAudience:            getEnv("JWT_AUDIENCE", ""),
+		JWTLeeway:              getEnvDuration("JWT_LEEWAY", 30*time.Second),
+		AccessTokenTTL:         getEnvDuration("JWT_ACCESS_TOKEN_TTL", 15*time.Minute),
+	}
+}
+
+// OIDCConfig holds the settings of the single sign-on with an OpenID Connect identity provider. Single
+// sign-on is disabled when IssuerURL is empty. RoleMapping maps the groups of the ID token to roles,
+// e.g. OIDC_ROLE_MAPPING="lynkly-admins=admin,marketing=editor". Users in none of the mapped groups get
+// DefaultRole or are refused when it is empty.
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string
+	RoleMapping  map[string]string
+	DefaultRole  string
+	LoginTTL     time.Duration
+	// WorkspaceID is the workspace the users of the identity provider join with their mapped role.
+	WorkspaceID   string
+	WorkspaceName string
+}
+
+func NewOIDCConfig() *OIDCConfig {
+	return &OIDCConfig{
+		IssuerURL:     getEnv("OIDC_ISSUER_URL", ""),
+		ClientID:      getEnv("OIDC_CLIENT_ID", ""),
+		ClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:   getEnv("OIDC_REDIRECT_URL", ""),
+		Scopes:        strings.Fields(getEnv("OIDC_SCOPES", "openid email profile")),
+		GroupsClaim:   getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		RoleMapping:   getEnvMap("OIDC_ROLE_MAPPING"),
+		DefaultRole:   getEnv("OIDC_DEFAULT_ROLE", ""),
+		LoginTTL:      getEnvDuration("OIDC_LOGIN_TTL", 10*time.Minute),
+		WorkspaceID:   getEnv("OIDC_WORKSPACE_ID", "sso"),
+		WorkspaceName: getEnv("OIDC_WORKSPACE_NAME", "Single sign-on"),
+	}
+}
+
+// SessionsConfig holds the settings of the user sessions. RefreshTokenTTL is the lifetime of a session
+// and RevocationCacheTTL is how long another instance takes at most to reject a revoked token.
+type SessionsConfig struct {
+	RefreshTokenTTL    time.Duration
+	RevocationCacheTTL time.Duration
+}
+
+func NewSessionsConfig() *SessionsConfig {
+	return &SessionsConfig{
+		RefreshTokenTTL:    getEnvDuration("SESSION_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		RevocationCacheTTL: getEnvDuration("SESSION_REVOCATION_CACHE_TTL", 30*time.Second),
+	}
+}
+
+// MFAConfig holds the settings of the two-factor authentication. Issuer names the service in the
+// authenticator apps and LoginTTL is how long a login waits for the second factor.
+type MFAConfig struct {
+	Issuer   string
+	LoginTTL time.Duration
+}
+
+func NewMFAConfig() *MFAConfig {
+	return &MFAConfig{
+		Issuer:   getEnv("MFA_ISSUER", "Lynkly"),
+		LoginTTL: getEnvDuration("MFA_LOGIN_TTL", 5*time.Minute),
+	}
+}
+
+// CORSConfig holds the cross-origin policies of the management API and of the public redirects. The
+// lists are comma-separated and the origins may contain a wildcard, e.g. "https://*.example.com", or be
+// "*" for every origin. The routes of a workspace in the management API additionally allow the origins
+// configured by the workspace and its verified custom domains.
+type CORSConfig struct {
+	API      CORSPolicy
+	Redirect CORSPolicy
+}
+
+type CORSPolicy struct {
+	AllowedOrigins   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+func NewCORSConfig() *CORSConfig {
+	return &CORSConfig{
+		API: CORSPolicy{
+			AllowedOrigins:   strings.FieldsFunc(getEnv("CORS_API_ALLOWED_ORIGINS", ""), isComma),
+			AllowedHeaders:   strings.FieldsFunc(getEnv("CORS_API_ALLOWED_HEADERS", "Authorization,Content-Type,X-API-Key,X-Workspace-ID,Last-Event-ID,X-Challenge-Response"), isComma),
+			ExposedHeaders:   strings.FieldsFunc(getEnv("CORS_API_EXPOSED_HEADERS", "X-Request-ID,Retry-After,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy"), isComma),
+			AllowCredentials: getEnvBool("CORS_API_ALLOW_CREDENTIALS", false),
+			MaxAge:           getEnvDuration("CORS_API_MAX_AGE", 10*time.Minute),
+		},
+		Redirect: CORSPolicy{
+			AllowedOrigins:   strings.FieldsFunc(getEnv("CORS_REDIRECT_ALLOWED_ORIGINS", "*"), isComma),
+			AllowedHeaders:   strings.FieldsFunc(getEnv("CORS_REDIRECT_ALLOWED_HEADERS", ""), isComma),
+			ExposedHeaders:   strings.FieldsFunc(getEnv("CORS_REDIRECT_EXPOSED_HEADERS", ""), isComma),
+			AllowCredentials: getEnvBool("CORS_REDIRECT_ALLOW_CREDENTIALS", false),
+			MaxAge:           getEnvDuration("CORS_REDIRECT_MAX_AGE", time.Hour),
+		},
+	}
+}
+
+// SecurityHeadersConfig holds the security headers sent with every response. HSTS is disabled when
+// HSTSMaxAge is zero. The management API and the public redirect pages have their own policies, in
+// which "{nonce}" is replaced by the nonce of the request, e.g. "style-src 'nonce-{nonce}'". A header
+// is left out when its value is empty, the Content-Security-Policy is also left out of JSON responses.
+type SecurityHeadersConfig struct {
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	API                   SecurityHeadersPolicy
+	Redirect              SecurityHeadersPolicy
+}
+
+type SecurityHeadersPolicy struct {
+	ContentSecurityPolicy string
+	ReferrerPolicy        string
+	FrameOptions          string
+	PermissionsPolicy     string
+}
+
+func NewSecurityHeadersConfig() *SecurityHeadersConfig {
+	permissionsPolicy := "accelerometer=(), camera=(), geolocation=(), gyroscope=(), magnetometer=(), microphone=(), payment=(), usb=()"
+
+	return &SecurityHeadersConfig{
+		HSTSMaxAge:            getEnvDuration("SECURITY_HSTS_MAX_AGE", 365*24*time.Hour),
+		HSTSIncludeSubdomains: getEnvBool("SECURITY_HSTS_INCLUDE_SUBDOMAINS", false),
+		HSTSPreload:           getEnvBool("SECURITY_HSTS_PRELOAD", false),
+		API: SecurityHeadersPolicy{
+			ContentSecurityPolicy: getEnv("SECURITY_API_CSP", "default-src 'none'; frame-ancestors 'none'"),
+			ReferrerPolicy:        getEnv("SECURITY_API_REFERRER_POLICY", "no-referrer"),
+			FrameOptions:          getEnv("SECURITY_API_FRAME_OPTIONS", "DENY"),
+			PermissionsPolicy:     getEnv("SECURITY_API_PERMISSIONS_POLICY", permissionsPolicy),
+		},
+		Redirect: SecurityHeadersPolicy{
+			ContentSecurityPolicy: getEnv("SECURITY_REDIRECT_CSP", "default-src 'none'; style-src 'nonce-{nonce}'; "+
+				"script-src 'nonce-{nonce}'; img-src 'self'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'"),
+			ReferrerPolicy:    getEnv("SECURITY_REDIRECT_REFERRER_POLICY", "strict-origin-when-cross-origin"),
+			FrameOptions:      getEnv("SECURITY_REDIRECT_FRAME_OPTIONS", "DENY"),
+			PermissionsPolicy: getEnv("SECURITY_REDIRECT_PERMISSIONS_POLICY", permissionsPolicy),
+		},
+	}
+}
+
+// TLSConfig holds the certificate the server is served with. TLS is disabled when CertFile is empty.
+// The files are checked for changes every ReloadInterval, so that renewed certificates are picked up
+// without a restart. CipherSuites is a comma-separated list of Go cipher suite names for TLS 1.2. When
+// HTTPRedirectAddr is set, plain HTTP requests on it are redirected to HTTPS.
+type TLSConfig struct {
+	CertFile         string
+	KeyFile          string
+	ReloadInterval   time.Duration
+	MinVersion       string
+	CipherSuites     []string
+	HTTPRedirectAddr string
+}
+
+func NewTLSConfig() *TLSConfig {
+	return &TLSConfig{
+		CertFile:         getEnv("TLS_CERT_FILE", ""),
+		KeyFile:          getEnv("TLS_KEY_FILE", ""),
+		ReloadInterval:   getEnvInterval("TLS_RELOAD_INTERVAL", time.Minute),
+		MinVersion:       getEnv("TLS_MIN_VERSION", "1.2"),
+		CipherSuites:     strings.FieldsFunc(getEnv("TLS_CIPHER_SUITES", ""), isComma),
+		HTTPRedirectAddr: getEnv("TLS_HTTP_REDIRECT_ADDR", ""),
+	}
+}
+
+// ACMEConfig holds the ACME account which obtains the certificates of the verified custom domains of
+// the workspaces. DirectoryURL defaults to Let's Encrypt. For a local test server such as Pebble,
+// CARootsFile is the certificate its directory is served with.
+type ACMEConfig struct {
+	Enabled      bool
+	DirectoryURL string
+	Email        string
+	CARootsFile  string
+	RenewBefore  time.Duration
+}
+
+func NewACMEConfig() *ACMEConfig {
+	return &ACMEConfig{
+		Enabled:      getEnvBool("ACME_ENABLED", false),
+		DirectoryURL: getEnv("ACME_DIRECTORY_URL", "https://acme-v02.api.letsencrypt.org/directory"),
+		Email:        getEnv("ACME_EMAIL", ""),
+		CARootsFile:  getEnv("ACME_CA_ROOTS_FILE", ""),
+		RenewBefore:  getEnvDuration("ACME_RENEW_BEFORE", 30*24*time.Hour),
+	}
+}
+
+// CustomDomainsConfig holds the verification of the custom domains. The TXT records are looked up with
+// the resolver of the system unless DNSServer, e.g. "127.0.0.1:8053", is set.
+type CustomDomainsConfig struct {
+	DNSServer string
+}
+
+func NewCustomDomainsConfig() *CustomDomainsConfig {
+	return &CustomDomainsConfig{
+		DNSServer: getEnv("CUSTOM_DOMAINS_DNS_SERVER", ""),
+	}
+}
+
+// DestinationsConfig holds the policy for the destinations of the short links. The domain lists are
+// comma-separated and accept wildcards, e.g. DESTINATION_DENIED_DOMAINS="evil.example,*.evil.example".
+type DestinationsConfig struct {
+	AllowedSchemes       []string
+	AllowedDomains       []string
+	DeniedDomains        []string
+	MaxURLLength         int
+	AllowPrivateNetworks bool
+}
+
+func NewDestinationsConfig() *DestinationsConfig {
+	return &DestinationsConfig{
+		AllowedSchemes:       strings.FieldsFunc(getEnv("DESTINATION_ALLOWED_SCHEMES", "http,https"), isComma),
+		AllowedDomains:       strings.FieldsFunc(getEnv("DESTINATION_ALLOWED_DOMAINS", ""), isComma),
+		DeniedDomains:        strings.FieldsFunc(getEnv("DESTINATION_DENIED_DOMAINS", ""), isComma),
+		MaxURLLength:         getEnvInt("DESTINATION_MAX_URL_LENGTH", 2048),
+		AllowPrivateNetworks: getEnvBool("DESTINATION_ALLOW_PRIVATE_NETWORKS", false),
+	}
+}
+
+// ShortenersConfig holds the settings against links pointing back at this service or at other URL
+// shorteners. OwnDomains are the domains of the service in addition to the one of the service URL.
+// Links to the known shortener Domains are rejected unless ResolveChains is set, in which case their
+// redirects are followed up to MaxHops times and the final destination is stored.
+type ShortenersConfig struct {
+	OwnDomains     []string
+	Domains        []string
+	ResolveChains  bool
+	MaxHops        int
+	ResolveTimeout time.Duration
+}
+
+func NewShortenersConfig() *ShortenersConfig {
+	return &ShortenersConfig{
+		OwnDomains:     strings.FieldsFunc(getEnv("SHORTENER_OWN_DOMAINS", ""), isComma),
+		Domains:        strings.FieldsFunc(getEnv("SHORTENER_DOMAINS", defaultShortenerDomains), isComma),
+		ResolveChains:  getEnvBool("SHORTENER_RESOLVE_CHAINS", false),
+		MaxHops:        getEnvInt("SHORTENER_MAX_HOPS", 5),
+		ResolveTimeout: getEnvDuration("SHORTENER_RESOLVE_TIMEOUT", 5*time.Second),
+	}
+}
+
+const defaultShortenerDomains = "bit.ly,bitly.com,buff.ly,cutt.ly,goo.gl,is.gd,lnkd.in,ow.ly,rb.gy,rebrand.ly," +
+	"s.id,shorturl.at,t.co,t.ly,tiny.cc,tinyurl.com,v.gd"
+
+// ThreatsConfig holds the threat lists the destinations of the links are screened against. The lists
+// are comma-separated paths, the files are checked for changes every ReloadInterval and the existing
+// links are screened again every RescanInterval. Screening is disabled when no list is configured.
+type ThreatsConfig struct {
+	DomainLists     []string
+	HashPrefixLists []string
+	ReloadInterval  time.Duration
+	RescanInterval  time.Duration
+}
+
+func NewThreatsConfig() *ThreatsConfig {
+	return &ThreatsConfig{
+		DomainLists:     strings.FieldsFunc(getEnv("THREAT_DOMAIN_LISTS", ""), isComma),
+		HashPrefixLists: strings.FieldsFunc(getEnv("THREAT_HASH_PREFIX_LISTS", ""), isComma),
+		ReloadInterval:  getEnvInterval("THREAT_LISTS_RELOAD_INTERVAL", time.Minute),
+		RescanInterval:  getEnvInterval("THREAT_RESCAN_INTERVAL", time.Hour),
+	}
+}
+
+// ModerationConfig holds the settings of the abuse reports. ModeratorIDs are the comma-separated IDs
+// of the users who review the reports of every workspace.
+type ModerationConfig struct {
+	ModeratorIDs []string
+}
+
+func NewModerationConfig() *ModerationConfig {
+	return &ModerationConfig{
+		ModeratorIDs: strings.FieldsFunc(getEnv("MODERATION_MODERATOR_IDS", ""), isComma),
+	}
+}
+
+// RateLimitConfig holds the quotas of the clients. Each client may send up to Requests per Period to
+// the management API and to the redirects respectively, and each IP address may fail to authenticate
+// up to AuthFailures per AuthFailurePeriod. A zero number of requests disables the limit.
+type RateLimitConfig struct {
+	APIRequests       int
+	APIPeriod         time.Duration
+	RedirectRequests  int
+	RedirectPeriod    time.Duration
+	AuthFailures      int
+	AuthFailurePeriod time.Duration
+}
+
+func NewRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		APIRequests:       getEnvInt("RATE_LIMIT_API_REQUESTS", 120),
+		APIPeriod:         getEnvDuration("RATE_LIMIT_API_PERIOD", time.Minute),
+		RedirectRequests:  getEnvInt("RATE_LIMIT_REDIRECT_REQUESTS", 600),
+		RedirectPeriod:    getEnvDuration("RATE_LIMIT_REDIRECT_PERIOD", time.Minute),
+		AuthFailures:      getEnvInt("RATE_LIMIT_AUTH_FAILURES", 10),
+		AuthFailurePeriod: getEnvDuration("RATE_LIMIT_AUTH_FAILURE_PERIOD", time.Minute),
+	}
+}
+
+// WorkspacesConfig holds the settings of the workspace memberships.
+type WorkspacesConfig struct {
+	InvitationTTL time.Duration
+}
+
+func NewWorkspacesConfig() *WorkspacesConfig {
+	return &WorkspacesConfig{
+		InvitationTTL: getEnvDuration("WORKSPACE_INVITATION_TTL", 7*24*time.Hour),
+	}
+}
+
+// LiveStreamConfig holds the settings of the live click stream.
+type LiveStreamConfig struct {
+	HeartbeatInterval    time.Duration
+	ReplayBufferSize     int
+	SubscriberBufferSize int
+	// ReplayWindow is how long the events of a link are kept after its last subscriber disconnected,
+	// so that the subscriber can resume the stream.
+	ReplayWindow time.Duration
+}
+
+func NewLiveStreamConfig() *LiveStreamConfig {
+	return &LiveStreamConfig{
+		HeartbeatInterval:    getEnvInterval("LIVE_HEARTBEAT_INTERVAL", 15*time.Second),
+		ReplayBufferSize:     getEnvInt("LIVE_REPLAY_BUFFER_SIZE", 64),
+		SubscriberBufferSize: getEnvInt("LIVE_SUBSCRIBER_BUFFER_SIZE", 32),
+		ReplayWindow:         getEnvInterval("LIVE_REPLAY_WINDOW", time.Minute),
+	}
+}
+
+// AnalyticsConfig holds the settings of the click rollups and their retention. A zero retention
+// keeps the data forever.
+type AnalyticsConfig struct {
+	RollupInterval  time.Duration
+	RawRetention    time.Duration
+	HourlyRetention time.Duration
+	DailyRetention  time.Duration
+}
+
+func NewAnalyticsConfig() *AnalyticsConfig {
+	return &AnalyticsConfig{
+		RollupInterval:  getEnvInterval("ANALYTICS_ROLLUP_INTERVAL", 5*time.Minute),
+		RawRetention:    getEnvDuration("ANALYTICS_RAW_RETENTION", 7*24*time.Hour),
+		HourlyRetention: getEnvDuration("ANALYTICS_HOURLY_RETENTION", 90*24*time.Hour),
+		DailyRetention:  getEnvDuration("ANALYTICS_DAILY_RETENTION", 2*365*24*time.Hour),
+	}
+}
+
+// PrivacyConfig holds the settings applied to click events before they are stored.
+// IPMode is one of "truncate", "hash" or "drop".
+type PrivacyConfig struct {
+	IPMode       string
+	SaltRotation time.Duration
+}
+
+func NewPrivacyConfig() *PrivacyConfig {
+	return &PrivacyConfig{
+		IPMode:       getEnv("PRIVACY_IP_MODE", "truncate"),
+		SaltRotation: getEnvDuration("PRIVACY_SALT_ROTATION", 24*time.Hour),
+	}
+}
+
+// LinksConfig holds the settings of the short links.
+type LinksConfig struct {
+	ExpiryCheckInterval time.Duration
+}
+
+func NewLinksConfig() *LinksConfig {
+	return &LinksConfig{
+		ExpiryCheckInterval: getEnvInterval("LINKS_EXPIRY_CHECK_INTERVAL", time.Minute),
+	}
+}
+
+// WebhooksConfig holds the delivery settings of the outbound webhooks.
+type WebhooksConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Workers        int
+	// DeliveryRetention is how long succeeded and dead deliveries are kept
+	DeliveryRetention time.Duration
+	// AllowPrivateNetworks accepts receivers on loopback and private addresses, e.g. for local testing
+	AllowPrivateNetworks bool
+}
+
+func NewWebhooksConfig() *WebhooksConfig {
+	return &WebhooksConfig{
+		MaxAttempts:          getEnvInt("WEBHOOKS_MAX_ATTEMPTS", 8),
+		InitialBackoff:       getEnvDuration("WEBHOOKS_INITIAL_BACKOFF", 10*time.Second),
+		MaxBackoff:           getEnvDuration("WEBHOOKS_MAX_BACKOFF", time.Hour),
+		Workers:              getEnvInt("WEBHOOKS_WORKERS", 4),
+		DeliveryRetention:    getEnvInterval("WEBHOOKS_DELIVERY_RETENTION", 7*24*time.Hour),
+		AllowPrivateNetworks: getEnvBool("WEBHOOKS_ALLOW_PRIVATE_NETWORKS", false),
+	}
+}
+
+// ConversionsConfig holds the settings of the conversion tracking. When Secret is empty a random
+// secret is generated on startup, so click IDs issued before a restart cannot be verified.
+type ConversionsConfig struct {
+	AppendClickID     bool
+	ClickIDParam      string
+	Secret            string
+	AttributionWindow time.Duration
+}
+
+func NewConversionsConfig() *ConversionsConfig {
+	return &ConversionsConfig{
+		AppendClickID:     getEnvBool("CONVERSIONS_APPEND_CLICK_ID", true),
+		ClickIDParam:      getEnv("CONVERSIONS_CLICK_ID_PARAM", "lynkly_cid"),
+		Secret:            getEnv("CONVERSIONS_SECRET", ""),
+		AttributionWindow: getEnvDuration("CONVERSIONS_ATTRIBUTION_WINDOW", 30*24*time.Hour),
+	}
+}
+
+// ChallengesConfig holds the challenge anonymous clients solve before creating a link. Mode is "pow"
+// for a proof of work, "captcha" for a CAPTCHA checked at CaptchaVerifyURL or empty to disable the
+// challenge. When Secret is empty a random secret is generated on startup, so proofs of work issued
+// before a restart cannot be verified.
+type ChallengesConfig struct {
+	Mode             string
+	Secret           string
+	PoWDifficulty    int
+	PoWTTL           time.Duration
+	CaptchaVerifyURL string
+	CaptchaSecret    string
+	CaptchaSiteKey   string
+	CaptchaTimeout   time.Duration
+}
+
+func NewChallengesConfig() *ChallengesConfig {
+	return &ChallengesConfig{
+		Mode:             getEnv("CHALLENGE_MODE", ""),
+		Secret:           getEnv("CHALLENGE_SECRET", ""),
+		PoWDifficulty:    getEnvInt("CHALLENGE_POW_DIFFICULTY", 20),
+		PoWTTL:           getEnvDuration("CHALLENGE_POW_TTL", 5*time.Minute),
+		CaptchaVerifyURL: getEnv("CHALLENGE_CAPTCHA_VERIFY_URL", "https://api.hcaptcha.com/siteverify"),
+		CaptchaSecret:    getEnv("CHALLENGE_CAPTCHA_SECRET", ""),
+		CaptchaSiteKey:   getEnv("CHALLENGE_CAPTCHA_SITE_KEY", ""),
+		CaptchaTimeout:   getEnvDuration("CHALLENGE_CAPTCHA_TIMEOUT", 5*time.Second),
+	}
+}
+
 // getEnv retrieves the value of the specified environment variable,
 // or returns the default value if the environment variable is not set.
 func getEnv(key, defaultValue string) string {
@@ -33,3 +512,62 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvInt retrieves the integer value of the specified environment variable,
+// or returns the default value if the environment variable is not set or is not a valid integer.
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvDuration retrieves the duration value (e.g. "15s") of the specified environment variable,
+// or returns the default value if the environment variable is not set or is not a valid duration.
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvInterval retrieves the duration value of the specified environment variable like getEnvDuration,
+// but returns the default value for zero and negative durations as well, so the result can be used as a
+// ticker interval.
+func getEnvInterval(key string, defaultValue time.Duration) time.Duration {
+	value := getEnvDuration(key, defaultValue)
+	if value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvMap retrieves a comma separated list of key=value pairs of the specified environment variable.
+// Malformed pairs are skipped.
+func getEnvMap(key string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, found := strings.Cut(pair, "=")
+		if found == false || strings.TrimSpace(k) == "" {
+			continue
+		}
+		result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return result
+}
+
+func isComma(r rune) bool {
+	return r == ','
+}
+
+// getEnvBool retrieves the boolean value of the specified environment variable,
+// or returns the default value if the environment variable is not set or is not a valid boolean.
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
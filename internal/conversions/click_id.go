@@ -0,0 +1,98 @@
+package conversions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"lynkly-backend/internal/common"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const signatureSize = 16
+
+var (
+	ErrInvalidClickID = errors.New("invalid click ID")
+	ErrClickIDExpired = errors.New("click ID is past the attribution window")
+)
+
+// ClickRef is the content of a click ID: the short link which was clicked and when.
+type ClickRef struct {
+	ID        string
+	Code      string
+	ClickedAt time.Time
+}
+
+// ClickIDSigner issues and verifies click IDs. A click ID carries the short link code and the time
+// of the click, so conversions can be attributed without looking up the click, and is signed with
+// HMAC-SHA256 so it cannot be forged for other links.
+type ClickIDSigner struct {
+	secret            []byte
+	attributionWindow time.Duration
+}
+
+func NewClickIDSigner(secret []byte, attributionWindow time.Duration) *ClickIDSigner {
+	return &ClickIDSigner{
+		secret:            secret,
+		attributionWindow: attributionWindow,
+	}
+}
+
+// Issue returns a new click ID for a click of the short link code at clickedAt.
+func (s *ClickIDSigner) Issue(code string, clickedAt time.Time) string {
+	content := fmt.Sprintf("%s.%s.%s",
+		base64.RawURLEncoding.EncodeToString([]byte(code)),
+		strconv.FormatInt(clickedAt.Unix(), 36),
+		common.RandomHex(8),
+	)
+
+	return content + "." + s.sign(content)
+}
+
+// Parse verifies the click ID and returns its content.
+func (s *ClickIDSigner) Parse(clickID string, now time.Time) (*ClickRef, error) {
+	separator := strings.LastIndexByte(clickID, '.')
+	if separator < 0 {
+		return nil, ErrInvalidClickID
+	}
+
+	content, signature := clickID[:separator], clickID[separator+1:]
+	if hmac.Equal([]byte(signature), []byte(s.sign(content))) == false {
+		return nil, ErrInvalidClickID
+	}
+
+	parts := strings.Split(content, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidClickID
+	}
+
+	code, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidClickID
+	}
+
+	unix, err := strconv.ParseInt(parts[1], 36, 64)
+	if err != nil {
+		return nil, ErrInvalidClickID
+	}
+
+	clickedAt := time.Unix(unix, 0).UTC()
+	if s.attributionWindow > 0 && now.Sub(clickedAt) > s.attributionWindow {
+		return nil, ErrClickIDExpired
+	}
+
+	return &ClickRef{
+		ID:        clickID,
+		Code:      string(code),
+		ClickedAt: clickedAt,
+	}, nil
+}
+
+func (s *ClickIDSigner) sign(content string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(content))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureSize])
+}
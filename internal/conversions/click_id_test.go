@@ -0,0 +1,65 @@
+package conversions
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClickIDRoundTrip(t *testing.T) {
+	signer := NewClickIDSigner([]byte("secret"), 24*time.Hour)
+	clickedAt := time.Date(2024, 3, 1, 12, 30, 15, 0, time.UTC)
+
+	clickID := signer.Issue("abc_-123", clickedAt)
+	ref, err := signer.Parse(clickID, clickedAt.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ref.ID != clickID || ref.Code != "abc_-123" || ref.ClickedAt.Equal(clickedAt) == false {
+		t.Errorf("Unexpected click reference %+v", ref)
+	}
+
+	if other := signer.Issue("abc_-123", clickedAt); other == clickID {
+		t.Error("Expected click IDs of separate clicks to differ")
+	}
+}
+
+func TestClickIDTampering(t *testing.T) {
+	signer := NewClickIDSigner([]byte("secret"), 24*time.Hour)
+	clickedAt := time.Now().UTC()
+	clickID := signer.Issue("abc", clickedAt)
+	parts := strings.Split(clickID, ".")
+
+	otherCode := base64.RawURLEncoding.EncodeToString([]byte("xyz"))
+	tests := map[string]string{
+		"other code":      strings.Join([]string{otherCode, parts[1], parts[2], parts[3]}, "."),
+		"other time":      strings.Join([]string{parts[0], "0", parts[2], parts[3]}, "."),
+		"other signature": strings.Join(parts[:3], ".") + "." + strings.Repeat("A", len(parts[3])),
+		"no signature":    strings.Join(parts[:3], "."),
+		"empty":           "",
+		"foreign secret":  NewClickIDSigner([]byte("other"), 24*time.Hour).Issue("abc", clickedAt),
+	}
+
+	for name, tampered := range tests {
+		if _, err := signer.Parse(tampered, clickedAt); err != ErrInvalidClickID {
+			t.Errorf("%s: expected ErrInvalidClickID, got %v", name, err)
+		}
+	}
+}
+
+func TestClickIDAttributionWindow(t *testing.T) {
+	clickedAt := time.Now().UTC()
+
+	signer := NewClickIDSigner([]byte("secret"), time.Hour)
+	if _, err := signer.Parse(signer.Issue("abc", clickedAt), clickedAt.Add(2*time.Hour)); err != ErrClickIDExpired {
+		t.Errorf("Expected ErrClickIDExpired, got %v", err)
+	}
+
+	// a zero window never expires
+	signer = NewClickIDSigner([]byte("secret"), 0)
+	if _, err := signer.Parse(signer.Issue("abc", clickedAt), clickedAt.Add(365*24*time.Hour)); err != nil {
+		t.Errorf("Expected the click ID to be valid, got %v", err)
+	}
+}
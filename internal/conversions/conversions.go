@@ -0,0 +1,114 @@
+package conversions
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const maxEventLength = 64
+
+var (
+	ErrDuplicate    = errors.New("conversion already recorded for this click")
+	ErrInvalidEvent = errors.New("invalid event - expected a name of up to 64 characters")
+)
+
+// Conversion is an event, e.g. a signup, attributed to the click of a short link.
+type Conversion struct {
+	ID          string    `json:"id"`
+	ClickID     string    `json:"clickId"`
+	Code        string    `json:"code"`
+	WorkspaceID string    `json:"workspaceId"`
+	Event       string    `json:"event"`
+	Value       float64   `json:"value,omitempty"`
+	Currency    string    `json:"currency,omitempty"`
+	ClickedAt   time.Time `json:"clickedAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Summary holds the conversions of a short link for a single event name.
+type Summary struct {
+	Event string  `json:"event"`
+	Count int64   `json:"count"`
+	Value float64 `json:"value"`
+}
+
+func ValidateEvent(event string) error {
+	if event == "" || len(event) > maxEventLength {
+		return ErrInvalidEvent
+	}
+
+	return nil
+}
+
+// Summarize groups the conversions by event name, sorted by name.
+func Summarize(conversions []*Conversion) []Summary {
+	byEvent := make(map[string]*Summary)
+	for _, conversion := range conversions {
+		summary, ok := byEvent[conversion.Event]
+		if ok == false {
+			summary = &Summary{Event: conversion.Event}
+			byEvent[conversion.Event] = summary
+		}
+		summary.Count++
+		summary.Value += conversion.Value
+	}
+
+	result := make([]Summary, 0, len(byEvent))
+	for _, summary := range byEvent {
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Event < result[j].Event
+	})
+
+	return result
+}
+
+type Store interface {
+	// Add stores the conversion and returns ErrDuplicate when the click already converted for the same event.
+	Add(conversion *Conversion) error
+	// Conversions returns the conversions of the short link created in the range [from, to).
+	Conversions(code string, from, to time.Time) ([]*Conversion, error)
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu          sync.RWMutex
+	conversions []Conversion
+	seen        map[string]bool
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{seen: make(map[string]bool)}
+}
+
+func (s *memoryStore) Add(conversion *Conversion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := conversion.ClickID + "\x00" + conversion.Event
+	if s.seen[key] {
+		return ErrDuplicate
+	}
+
+	s.seen[key] = true
+	s.conversions = append(s.conversions, *conversion)
+	return nil
+}
+
+func (s *memoryStore) Conversions(code string, from, to time.Time) ([]*Conversion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Conversion
+	for _, conversion := range s.conversions {
+		if conversion.Code == code && conversion.CreatedAt.Before(from) == false && conversion.CreatedAt.Before(to) {
+			conversion := conversion
+			result = append(result, &conversion)
+		}
+	}
+
+	return result, nil
+}
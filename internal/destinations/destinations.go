@@ -0,0 +1,254 @@
+package destinations
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultMaxLength = 2048
+
+var (
+	ErrInvalidURL  = errors.New("invalid URL - expected an absolute URL with a scheme and a host")
+	ErrCredentials = errors.New("URLs with credentials are not allowed")
+)
+
+var defaultSchemes = []string{"http", "https"}
+
+// reservedNetworks are not public, but not covered by the methods of net.IP either
+var reservedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+}
+
+type Params struct {
+	// AllowedSchemes defaults to http and https
+	AllowedSchemes []string
+	// AllowedDomains restricts the destinations to the domains when not empty. DeniedDomains take
+	// precedence. A domain matches itself only, "*.example.com" matches the subdomains of example.com.
+	AllowedDomains []string
+	DeniedDomains  []string
+	// MaxLength defaults to 2048 characters
+	MaxLength int
+	// AllowPrivateNetworks accepts loopback, private and link-local addresses, e.g. for development
+	AllowPrivateNetworks bool
+}
+
+// Policy decides which URLs short links may point to. Only the URL itself is checked, host names are
+// not resolved.
+type Policy struct {
+	schemes              map[string]bool
+	schemeList           string
+	allowedDomains       []string
+	deniedDomains        []string
+	maxLength            int
+	allowPrivateNetworks bool
+}
+
+func New(params Params) *Policy {
+	if len(params.AllowedSchemes) == 0 {
+		params.AllowedSchemes = defaultSchemes
+	}
+	if params.MaxLength <= 0 {
+		params.MaxLength = defaultMaxLength
+	}
+
+	schemes := make(map[string]bool, len(params.AllowedSchemes))
+	for _, scheme := range params.AllowedSchemes {
+		schemes[strings.ToLower(scheme)] = true
+	}
+
+	return &Policy{
+		schemes:              schemes,
+		schemeList:           strings.Join(params.AllowedSchemes, ", "),
+		allowedDomains:       normalizeDomains(params.AllowedDomains),
+		deniedDomains:        normalizeDomains(params.DeniedDomains),
+		maxLength:            params.MaxLength,
+		allowPrivateNetworks: params.AllowPrivateNetworks,
+	}
+}
+
+// Check returns the reason why the URL is not an allowed destination, or nil. The reasons are meant
+// for the client.
+func (p *Policy) Check(rawURL string) error {
+	if len(rawURL) > p.maxLength {
+		return fmt.Errorf("URL is too long - expected up to %d characters", p.maxLength)
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme == "" {
+		return ErrInvalidURL
+	}
+
+	if p.schemes[strings.ToLower(parsed.Scheme)] == false {
+		return fmt.Errorf("URL scheme %s is not allowed - expected one of %s", parsed.Scheme, p.schemeList)
+	}
+
+	if parsed.Host == "" {
+		return ErrInvalidURL
+	}
+
+	if parsed.User != nil {
+		return ErrCredentials
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "" {
+		return ErrInvalidURL
+	}
+
+	if p.allowPrivateNetworks == false {
+		if err = checkPublicHost(host); err != nil {
+			return err
+		}
+	}
+
+	if matchesAny(host, p.deniedDomains) {
+		return fmt.Errorf("links to %s are not allowed", host)
+	}
+
+	if len(p.allowedDomains) > 0 && matchesAny(host, p.allowedDomains) == false {
+		return fmt.Errorf("links to %s are not allowed - the domain is not on the allow list", host)
+	}
+
+	return nil
+}
+
+// checkPublicHost rejects the names and addresses of the local machine and of private networks
+func checkPublicHost(host string) error {
+	if isLocalhost(host) {
+		return fmt.Errorf("links to %s are not allowed - the host is not public", host)
+	}
+
+	if ip := parseIP(host); ip != nil && IsPublicIP(ip) == false {
+		return fmt.Errorf("links to %s are not allowed - the address is not public", host)
+	}
+
+	return nil
+}
+
+// IsPublicHost reports whether the host, a lower case name or address without a port, may be public.
+// Host names other than localhost are not resolved and are reported as public.
+func IsPublicHost(host string) bool {
+	if isLocalhost(host) {
+		return false
+	}
+
+	ip := parseIP(host)
+	return ip == nil || IsPublicIP(ip)
+}
+
+// IsPublicIP reports whether the address is not a loopback, private, link-local, multicast or
+// otherwise reserved address. It is meant for checking resolved addresses before connecting to them.
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isLocalhost(host string) bool {
+	return host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
+
+// parseIP parses IPv6 addresses, dotted-decimal IPv4 addresses and the numeric IPv4 forms browsers
+// still accept, e.g. 2130706433 and 0x7f.1 for 127.0.0.1. It returns nil for host names.
+func parseIP(host string) net.IP {
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		// IPv6 zone
+		host = host[:i]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return nil
+	}
+
+	values := make([]uint64, len(parts))
+	for i, part := range parts {
+		value, ok := parseIPv4Part(part)
+		if ok == false {
+			return nil
+		}
+		values[i] = value
+	}
+
+	// the last part fills the remaining bytes of the address
+	var address uint64
+	for i, value := range values[:len(values)-1] {
+		if value > 0xff {
+			return nil
+		}
+		address |= value << (8 * uint(3-i))
+	}
+	last := values[len(values)-1]
+	if last >= 1<<(8*uint(5-len(values))) {
+		return nil
+	}
+	address |= last
+
+	return net.IPv4(byte(address>>24), byte(address>>16), byte(address>>8), byte(address))
+}
+
+// parseIPv4Part parses a decimal, octal ("0" prefix) or hexadecimal ("0x" prefix) part of an address.
+// Unlike base 0 of strconv, an explicit base rejects underscores and the "0b" and "0o" prefixes.
+func parseIPv4Part(part string) (uint64, bool) {
+	base, digits := 10, part
+	if len(part) > 2 && part[:2] == "0x" {
+		base, digits = 16, part[2:]
+	} else if len(part) > 1 && part[0] == '0' {
+		base, digits = 8, part[1:]
+	}
+
+	value, err := strconv.ParseUint(digits, base, 32)
+	return value, err == nil
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+
+	return network
+}
+
+func normalizeDomains(domains []string) []string {
+	result := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			result = append(result, domain)
+		}
+	}
+
+	return result
+}
+
+func matchesAny(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,260 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lynkly-backend/internal/common"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	MaxDomainsPerWorkspace = 20
+	// verificationPrefix is the label of the TXT record proving the control of a domain
+	verificationPrefix = "_lynkly-verification."
+	lookupTimeout      = 5 * time.Second
+)
+
+var (
+	ErrNotFound       = errors.New("domain not found")
+	ErrInvalidDomain  = errors.New("invalid domain - expected a host name such as links.example.com")
+	ErrOwnDomain      = errors.New("the domains of the service cannot be added")
+	ErrAlreadyExists  = errors.New("domain is already used by another workspace")
+	ErrTooManyDomains = fmt.Errorf("too many domains - expected up to %d", MaxDomainsPerWorkspace)
+	ErrNotVerified    = errors.New("domain is not verified")
+	ErrRecordNotFound = errors.New("verification record not found - the TXT record may take a while to propagate")
+	ErrLookupFailed   = errors.New("failed to look up the verification record")
+	errNotRegistered  = errors.New("domain is not registered")
+)
+
+// Domain is a custom domain of a workspace. The workspace proves that it controls the domain by
+// publishing the verification token in a TXT record, only verified domains are served.
+type Domain struct {
+	Name              string     `json:"name"`
+	WorkspaceID       string     `json:"workspaceId"`
+	VerificationToken string     `json:"verificationToken"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	VerifiedAt        *time.Time `json:"verifiedAt,omitempty"`
+}
+
+func (d *Domain) IsVerified() bool {
+	return d.VerifiedAt != nil
+}
+
+// VerificationRecord is the name of the TXT record which has to contain the verification token.
+func (d *Domain) VerificationRecord() string {
+	return verificationPrefix + d.Name
+}
+
+// Resolver looks up the TXT records of the verification, *net.Resolver implements it.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+type Params struct {
+	Store Store
+	// Resolver defaults to the resolver of the system
+	Resolver Resolver
+	// OwnDomains are the domains of the service, which cannot be added by a workspace
+	OwnDomains []string
+}
+
+// Domains manages the custom domains of the workspaces.
+type Domains struct {
+	store      Store
+	resolver   Resolver
+	ownDomains []string
+}
+
+func New(params Params) *Domains {
+	if params.Resolver == nil {
+		params.Resolver = net.DefaultResolver
+	}
+
+	ownDomains := make([]string, 0, len(params.OwnDomains))
+	for _, domain := range params.OwnDomains {
+		if domain = normalize(domain); domain != "" {
+			ownDomains = append(ownDomains, domain)
+		}
+	}
+
+	return &Domains{
+		store:      params.Store,
+		resolver:   params.Resolver,
+		ownDomains: ownDomains,
+	}
+}
+
+// NewResolver returns a resolver querying the DNS server at the address, e.g. "127.0.0.1:8053" for a
+// local test server, or the resolver of the system when the address is empty.
+func NewResolver(address string) Resolver {
+	if address == "" {
+		return net.DefaultResolver
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
+
+func (d *Domains) Store() Store {
+	return d.store
+}
+
+// Add registers the domain with the workspace and returns it with a new verification token. An
+// unverified domain of another workspace is taken over, so that it cannot be claimed by a workspace
+// which does not control it.
+func (d *Domains) Add(workspaceID, name string) (*Domain, error) {
+	name = normalize(name)
+	if isHostName(name) == false {
+		return nil, ErrInvalidDomain
+	}
+	for _, own := range d.ownDomains {
+		if name == own || strings.HasSuffix(name, "."+own) {
+			return nil, ErrOwnDomain
+		}
+	}
+
+	existing, err := d.store.Domain(name)
+	if err == nil && existing.IsVerified() && existing.WorkspaceID != workspaceID {
+		return nil, ErrAlreadyExists
+	} else if err == nil && existing.WorkspaceID == workspaceID {
+		return existing, nil
+	} else if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+
+	domains, err := d.store.Domains(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(domains) >= MaxDomainsPerWorkspace {
+		return nil, ErrTooManyDomains
+	}
+
+	domain := &Domain{
+		Name:              name,
+		WorkspaceID:       workspaceID,
+		VerificationToken: common.RandomHex(16),
+		CreatedAt:         time.Now().UTC(),
+	}
+	if err = d.store.SaveDomain(domain); err != nil {
+		return nil, err
+	}
+
+	return domain, nil
+}
+
+// Domain returns the domain of the workspace or ErrNotFound.
+func (d *Domains) Domain(workspaceID, name string) (*Domain, error) {
+	domain, err := d.store.Domain(normalize(name))
+	if err != nil {
+		return nil, err
+	}
+	if domain.WorkspaceID != workspaceID {
+		return nil, ErrNotFound
+	}
+
+	return domain, nil
+}
+
+// Verify looks up the verification record of the domain and marks the domain as verified when the
+// record contains its token.
+func (d *Domains) Verify(ctx context.Context, workspaceID, name string) (*Domain, error) {
+	domain, err := d.Domain(workspaceID, name)
+	if err != nil {
+		return nil, err
+	}
+	if domain.IsVerified() {
+		return domain, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	records, err := d.resolver.LookupTXT(ctx, domain.VerificationRecord())
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, ErrRecordNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLookupFailed, err)
+	}
+
+	for _, record := range records {
+		if strings.TrimSpace(record) == domain.VerificationToken {
+			now := time.Now().UTC()
+			domain.VerifiedAt = &now
+			if err = d.store.SaveDomain(domain); err != nil {
+				return nil, err
+			}
+
+			return domain, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+// Remove deletes the domain of the workspace.
+func (d *Domains) Remove(workspaceID, name string) (*Domain, error) {
+	domain, err := d.Domain(workspaceID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = d.store.DeleteDomain(domain.Name); err != nil {
+		return nil, err
+	}
+
+	return domain, nil
+}
+
+// HostPolicy accepts the verified custom domains only. It is the host policy of the ACME certificates.
+func (d *Domains) HostPolicy(_ context.Context, host string) error {
+	domain, err := d.store.Domain(normalize(host))
+	if err == ErrNotFound {
+		return errNotRegistered
+	} else if err != nil {
+		return err
+	}
+	if domain.IsVerified() == false {
+		return ErrNotVerified
+	}
+
+	return nil
+}
+
+func normalize(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// isHostName accepts the names with at least two labels of letters, digits and hyphens, which are not
+// IP addresses.
+func isHostName(name string) bool {
+	if len(name) > 253 || net.ParseIP(name) != nil {
+		return false
+	}
+
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
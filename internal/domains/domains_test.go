@@ -0,0 +1,181 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// fakeResolver answers the TXT lookups from the records, the names without records are not found.
+type fakeResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (r *fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	records, ok := r.records[name]
+	if ok == false {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return records, nil
+}
+
+func newTestDomains() (*Domains, *fakeResolver) {
+	resolver := &fakeResolver{records: make(map[string][]string)}
+
+	return New(Params{
+		Store:      NewMemoryStore(),
+		Resolver:   resolver,
+		OwnDomains: []string{"Lynkly.test."},
+	}), resolver
+}
+
+func TestAddValidatesTheName(t *testing.T) {
+	domains, _ := newTestDomains()
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"Links.Example.COM.", nil},
+		{"example", ErrInvalidDomain},
+		{"-links.example.com", ErrInvalidDomain},
+		{"links..example.com", ErrInvalidDomain},
+		{"links_example.com", ErrInvalidDomain},
+		{"10.0.0.1", ErrInvalidDomain},
+		{"lynkly.test", ErrOwnDomain},
+		{"go.lynkly.test", ErrOwnDomain},
+		{"notlynkly.test", nil},
+	}
+
+	for _, test := range tests {
+		if _, err := domains.Add("workspace", test.name); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	if _, err := domains.Domain("workspace", "links.example.com"); err != nil {
+		t.Errorf("Expected the name to be normalized, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	domains, resolver := newTestDomains()
+
+	domain, err := domains.Add("workspace", "links.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = domains.Verify(context.Background(), "workspace", domain.Name); err != ErrRecordNotFound {
+		t.Errorf("Expected ErrRecordNotFound without a record, got %v", err)
+	}
+
+	resolver.records[domain.VerificationRecord()] = []string{"other"}
+	if _, err = domains.Verify(context.Background(), "workspace", domain.Name); err != ErrRecordNotFound {
+		t.Errorf("Expected ErrRecordNotFound for another token, got %v", err)
+	}
+
+	resolver.err = errors.New("timeout")
+	if _, err = domains.Verify(context.Background(), "workspace", domain.Name); errors.Is(err, ErrLookupFailed) == false {
+		t.Errorf("Expected ErrLookupFailed, got %v", err)
+	}
+	resolver.err = nil
+
+	if _, err = domains.Verify(context.Background(), "other", domain.Name); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for another workspace, got %v", err)
+	}
+
+	resolver.records[domain.VerificationRecord()] = []string{"other", " " + domain.VerificationToken + " "}
+	verified, err := domains.Verify(context.Background(), "workspace", domain.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.IsVerified() == false {
+		t.Error("Expected the domain to be verified")
+	}
+}
+
+func TestAddTakesOverUnverifiedDomains(t *testing.T) {
+	domains, resolver := newTestDomains()
+
+	claimed, err := domains.Add("squatter", "links.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	domain, err := domains.Add("workspace", "links.example.com")
+	if err != nil {
+		t.Fatalf("Expected the unverified domain to be taken over, got %v", err)
+	}
+	if domain.WorkspaceID != "workspace" || domain.VerificationToken == claimed.VerificationToken {
+		t.Errorf("Expected a new verification token for the workspace, got %+v", domain)
+	}
+	if _, err = domains.Domain("squatter", domain.Name); err != ErrNotFound {
+		t.Errorf("Expected the domain to be removed from the previous workspace, got %v", err)
+	}
+
+	resolver.records[domain.VerificationRecord()] = []string{domain.VerificationToken}
+	if _, err = domains.Verify(context.Background(), "workspace", domain.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = domains.Add("squatter", "links.example.com"); err != ErrAlreadyExists {
+		t.Errorf("Expected ErrAlreadyExists for a verified domain, got %v", err)
+	}
+}
+
+func TestAddLimitsTheDomains(t *testing.T) {
+	domains, _ := newTestDomains()
+
+	for i := 0; i < MaxDomainsPerWorkspace; i++ {
+		if _, err := domains.Add("workspace", string(rune('a'+i))+".example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// adding a domain again does not count
+	if _, err := domains.Add("workspace", "a.example.com"); err != nil {
+		t.Errorf("Expected the existing domain, got %v", err)
+	}
+	if _, err := domains.Add("workspace", "links.example.com"); err != ErrTooManyDomains {
+		t.Errorf("Expected ErrTooManyDomains, got %v", err)
+	}
+}
+
+func TestHostPolicy(t *testing.T) {
+	domains, resolver := newTestDomains()
+
+	domain, err := domains.Add("workspace", "links.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = domains.HostPolicy(context.Background(), "links.example.com"); err != ErrNotVerified {
+		t.Errorf("Expected ErrNotVerified, got %v", err)
+	}
+	if err = domains.HostPolicy(context.Background(), "other.example.com"); err == nil {
+		t.Error("Expected an unknown host to be rejected")
+	}
+
+	resolver.records[domain.VerificationRecord()] = []string{domain.VerificationToken}
+	if _, err = domains.Verify(context.Background(), "workspace", domain.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err = domains.HostPolicy(context.Background(), "LINKS.example.com."); err != nil {
+		t.Errorf("Expected the verified domain to be accepted, got %v", err)
+	}
+
+	if _, err = domains.Remove("workspace", domain.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err = domains.HostPolicy(context.Background(), domain.Name); err == nil {
+		t.Error("Expected a removed domain to be rejected")
+	}
+}
@@ -0,0 +1,78 @@
+package domains
+
+import (
+	"sort"
+	"sync"
+)
+
+type Store interface {
+	// SaveDomain creates or replaces the domain with the name.
+	SaveDomain(domain *Domain) error
+	// Domain returns the domain with the name or ErrNotFound.
+	Domain(name string) (*Domain, error)
+	// Domains returns the domains of the workspace, oldest first.
+	Domains(workspaceID string) ([]*Domain, error)
+	// DeleteDomain removes the domain and returns ErrNotFound when it does not exist.
+	DeleteDomain(name string) error
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu      sync.RWMutex
+	domains map[string]Domain
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		domains: make(map[string]Domain),
+	}
+}
+
+func (s *memoryStore) SaveDomain(domain *Domain) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.domains[domain.Name] = *domain
+	return nil
+}
+
+func (s *memoryStore) Domain(name string) (*Domain, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	domain, ok := s.domains[name]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	return &domain, nil
+}
+
+func (s *memoryStore) Domains(workspaceID string) ([]*Domain, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Domain
+	for _, domain := range s.domains {
+		if domain.WorkspaceID == workspaceID {
+			domain := domain
+			result = append(result, &domain)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+func (s *memoryStore) DeleteDomain(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.domains[name]; ok == false {
+		return ErrNotFound
+	}
+	delete(s.domains, name)
+	return nil
+}
@@ -0,0 +1,217 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultReplayBufferSize     = 64
+	defaultSubscriberBufferSize = 32
+	defaultReplayWindow         = time.Minute
+)
+
+// Event is a single message published on a Hub topic. IDs are assigned by the hub and are
+// monotonically increasing, so they can be used as SSE event IDs for resuming a stream.
+type Event struct {
+	ID   uint64
+	Type string
+	Data interface{}
+}
+
+type HubParams struct {
+	// ReplayBufferSize is the number of most recent events kept per topic for resuming subscribers.
+	ReplayBufferSize int
+	// SubscriberBufferSize is the number of events a subscriber may fall behind before it gets dropped.
+	SubscriberBufferSize int
+	// ReplayWindow is how long a topic without subscribers is kept, so that a disconnected subscriber
+	// can still resume it. The topic is removed afterwards.
+	ReplayWindow time.Duration
+	// Now is the clock of the hub, time.Now by default
+	Now func() time.Time
+}
+
+// Hub is an in-process pub/sub hub. Publishing never blocks: subscribers which cannot keep up
+// are dropped and their channel is closed. Only topics which have been subscribed to are buffered,
+// events published on other topics are discarded.
+type Hub struct {
+	mu                   sync.Mutex
+	topics               map[string]*topic
+	lastID               uint64
+	replayBufferSize     int
+	subscriberBufferSize int
+	replayWindow         time.Duration
+	now                  func() time.Time
+	lastPrune            time.Time
+}
+
+type topic struct {
+	replay      *ring
+	subscribers map[*Subscription]struct{}
+	// idleSince is when the last subscriber left, the topic is removed once it has been idle for
+	// the replay window. It is zero while the topic has subscribers.
+	idleSince time.Time
+}
+
+// Subscription receives the events of a single topic on C. C is closed when the subscription is
+// cancelled or when the hub drops a slow subscriber.
+type Subscription struct {
+	C     <-chan Event
+	ch    chan Event
+	topic string
+}
+
+func NewHub(params HubParams) *Hub {
+	if params.ReplayBufferSize <= 0 {
+		params.ReplayBufferSize = defaultReplayBufferSize
+	}
+	if params.SubscriberBufferSize <= 0 {
+		params.SubscriberBufferSize = defaultSubscriberBufferSize
+	}
+	if params.ReplayWindow <= 0 {
+		params.ReplayWindow = defaultReplayWindow
+	}
+	if params.Now == nil {
+		params.Now = time.Now
+	}
+
+	return &Hub{
+		topics:               make(map[string]*topic),
+		replayBufferSize:     params.ReplayBufferSize,
+		subscriberBufferSize: params.SubscriberBufferSize,
+		replayWindow:         params.ReplayWindow,
+		now:                  params.Now,
+	}
+}
+
+// Publish stores the event in the topic replay buffer and fans it out to all subscribers. The event
+// is discarded when the topic has no subscribers and is not within its replay window.
+func (h *Hub) Publish(topicName, eventType string, data interface{}) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event := Event{
+		ID:   h.lastID,
+		Type: eventType,
+		Data: data,
+	}
+
+	t, ok := h.liveTopic(topicName)
+	if ok == false {
+		return event
+	}
+	t.replay.push(event)
+
+	for sub := range t.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			// the subscriber is too slow, drop it instead of blocking the publisher
+			delete(t.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+	h.releaseIfIdle(t)
+
+	return event
+}
+
+// Subscribe registers a new subscriber for the topic which receives only events published from now on.
+func (h *Hub) Subscribe(topicName string) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.subscribe(topicName)
+}
+
+// SubscribeFrom registers a new subscriber for the topic and returns the events still held in the
+// replay buffer with an ID greater than lastEventID, so the caller can resume a previous subscription.
+func (h *Hub) SubscribeFrom(topicName string, lastEventID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := h.subscribe(topicName)
+	return sub, h.topics[topicName].replay.after(lastEventID)
+}
+
+// Unsubscribe removes the subscription from the hub. It is safe to call it for an already
+// dropped subscription.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.topics[sub.topic]
+	if ok == false {
+		return
+	}
+
+	if _, ok = t.subscribers[sub]; ok {
+		delete(t.subscribers, sub)
+		close(sub.ch)
+	}
+	h.releaseIfIdle(t)
+}
+
+// releaseIfIdle starts the replay window of a topic which has no subscribers left. The hub mutex must be held.
+func (h *Hub) releaseIfIdle(t *topic) {
+	if len(t.subscribers) > 0 || t.idleSince.IsZero() == false {
+		return
+	}
+
+	t.idleSince = h.now()
+}
+
+// liveTopic returns the topic unless it has been idle for longer than the replay window. The idle
+// topics are removed at most once per replay window, so that publishing does not scan every topic.
+// The hub mutex must be held.
+func (h *Hub) liveTopic(name string) (*topic, bool) {
+	now := h.now()
+	if now.Sub(h.lastPrune) > h.replayWindow {
+		for topicName, t := range h.topics {
+			if h.expired(t, now) {
+				delete(h.topics, topicName)
+			}
+		}
+		h.lastPrune = now
+	}
+
+	t, ok := h.topics[name]
+	if ok && h.expired(t, now) {
+		delete(h.topics, name)
+		return nil, false
+	}
+
+	return t, ok
+}
+
+func (h *Hub) expired(t *topic, now time.Time) bool {
+	return len(t.subscribers) == 0 && t.idleSince.IsZero() == false && now.Sub(t.idleSince) > h.replayWindow
+}
+
+func (h *Hub) subscribe(topicName string) *Subscription {
+	ch := make(chan Event, h.subscriberBufferSize)
+	sub := &Subscription{
+		C:     ch,
+		ch:    ch,
+		topic: topicName,
+	}
+
+	t := h.topic(topicName)
+	t.idleSince = time.Time{}
+	t.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) topic(name string) *topic {
+	t, ok := h.liveTopic(name)
+	if ok == false {
+		t = &topic{
+			replay:      newRing(h.replayBufferSize),
+			subscribers: make(map[*Subscription]struct{}),
+		}
+		h.topics[name] = t
+	}
+
+	return t
+}
@@ -0,0 +1,174 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is the clock of the hub in the tests, it only moves when advanced.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestHub(params HubParams) (*Hub, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	params.Now = clock.Now
+
+	return NewHub(params), clock
+}
+
+func eventIDs(events []Event) []uint64 {
+	ids := make([]uint64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+
+	return ids
+}
+
+func equalIDs(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestPublish(t *testing.T) {
+	hub, _ := newTestHub(HubParams{})
+
+	// nobody listens to the topic yet
+	hub.Publish("abc", "click", nil)
+
+	sub := hub.Subscribe("abc")
+	other := hub.Subscribe("xyz")
+	event := hub.Publish("abc", "click", "data")
+
+	received := <-sub.C
+	if received.ID != event.ID || received.Type != "click" || received.Data != "data" {
+		t.Errorf("Expected %+v, got %+v", event, received)
+	}
+	select {
+	case received = <-other.C:
+		t.Errorf("Expected no events on another topic, got %+v", received)
+	default:
+	}
+
+	if _, backlog := hub.SubscribeFrom("abc", 0); equalIDs(eventIDs(backlog), []uint64{event.ID}) == false {
+		t.Errorf("Expected only the events published after the first subscription, got %v", eventIDs(backlog))
+	}
+}
+
+func TestSubscribeFromReplaysTheRing(t *testing.T) {
+	hub, _ := newTestHub(HubParams{ReplayBufferSize: 3})
+
+	sub := hub.Subscribe("abc")
+	defer hub.Unsubscribe(sub)
+
+	var ids []uint64
+	for i := 0; i < 5; i++ {
+		ids = append(ids, hub.Publish("abc", "click", i).ID)
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID uint64
+		expected    []uint64
+	}{
+		{"older than the ring", 0, ids[2:]},
+		{"within the ring", ids[3], ids[4:]},
+		{"up to date", ids[4], nil},
+	}
+
+	for _, test := range tests {
+		resumed, backlog := hub.SubscribeFrom("abc", test.lastEventID)
+		if equalIDs(eventIDs(backlog), test.expected) == false {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, eventIDs(backlog))
+		}
+		hub.Unsubscribe(resumed)
+	}
+}
+
+func TestSlowSubscribersAreDropped(t *testing.T) {
+	hub, _ := newTestHub(HubParams{SubscriberBufferSize: 2})
+
+	slow := hub.Subscribe("abc")
+	fast := hub.Subscribe("abc")
+
+	for i := 0; i < 3; i++ {
+		hub.Publish("abc", "click", i)
+		<-fast.C
+	}
+
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("Expected the buffered events before the channel is closed, got %d", received)
+	}
+
+	hub.Publish("abc", "click", nil)
+	if _, ok := <-fast.C; ok == false {
+		t.Error("Expected the fast subscriber to keep receiving events")
+	}
+
+	// unsubscribing a dropped subscriber is safe
+	hub.Unsubscribe(slow)
+}
+
+func TestIdleTopicsAreRemoved(t *testing.T) {
+	hub, clock := newTestHub(HubParams{ReplayWindow: time.Minute})
+
+	sub := hub.Subscribe("abc")
+	hub.Subscribe("busy")
+	event := hub.Publish("abc", "click", nil)
+	hub.Unsubscribe(sub)
+
+	// a subscriber reconnecting within the window resumes the stream
+	clock.advance(time.Minute)
+	resumed, backlog := hub.SubscribeFrom("abc", 0)
+	if equalIDs(eventIDs(backlog), []uint64{event.ID}) == false {
+		t.Errorf("Expected the event to be replayed within the window, got %v", eventIDs(backlog))
+	}
+	hub.Unsubscribe(resumed)
+
+	// the window starts again when the last subscriber leaves
+	clock.advance(30 * time.Second)
+	hub.Publish("abc", "click", nil)
+	if _, ok := hub.topics["abc"]; ok == false {
+		t.Error("Expected the topic to be kept after it was subscribed to again")
+	}
+	if _, backlog = hub.SubscribeFrom("other", 0); len(backlog) != 0 {
+		t.Errorf("Expected no events on a new topic, got %v", eventIDs(backlog))
+	}
+
+	clock.advance(2 * time.Minute)
+	hub.Publish("busy", "click", nil)
+	if _, ok := hub.topics["abc"]; ok {
+		t.Error("Expected the idle topic to be removed after the window")
+	}
+	if _, ok := hub.topics["busy"]; ok == false {
+		t.Error("Expected the topic with a subscriber to be kept")
+	}
+
+	// events of removed topics are not replayed
+	resumed, backlog = hub.SubscribeFrom("abc", 0)
+	if len(backlog) != 0 {
+		t.Errorf("Expected no events after the topic was removed, got %v", eventIDs(backlog))
+	}
+	hub.Unsubscribe(resumed)
+}
@@ -0,0 +1,37 @@
+package events
+
+// ring is a fixed size buffer holding the most recent events of a topic.
+type ring struct {
+	events []Event
+	start  int
+	size   int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{events: make([]Event, capacity)}
+}
+
+func (r *ring) push(event Event) {
+	end := (r.start + r.size) % len(r.events)
+	r.events[end] = event
+
+	if r.size < len(r.events) {
+		r.size++
+		return
+	}
+
+	r.start = (r.start + 1) % len(r.events)
+}
+
+// after returns the buffered events with an ID greater than id, oldest first.
+func (r *ring) after(id uint64) []Event {
+	var result []Event
+	for i := 0; i < r.size; i++ {
+		event := r.events[(r.start+i)%len(r.events)]
+		if event.ID > id {
+			result = append(result, event)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,180 @@
+package links
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWorkspaceID is the workspace of the links created anonymously. It has no members, so the
+// links can only be followed but not managed.
+const DefaultWorkspaceID = "default"
+
+var (
+	ErrNotFound   = errors.New("link not found")
+	ErrCodeExists = errors.New("link code already exists")
+)
+
+type Link struct {
+	Code        string `json:"code"`
+	URL         string `json:"url"`
+	WorkspaceID string `json:"workspaceId"`
+	// CreatedBy is the ID of the user who created the link. It is empty for anonymously created links.
+	CreatedBy string     `json:"createdBy,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// ExpiryNotified is set once the expiry of the link has been announced.
+	ExpiryNotified bool `json:"-"`
+	// DisabledAt is set when the link stops redirecting. Threat names the threat list its destination
+	// was found on, such links show a warning page instead.
+	DisabledAt     *time.Time `json:"disabledAt,omitempty"`
+	DisabledReason string     `json:"disabledReason,omitempty"`
+	Threat         string     `json:"threat,omitempty"`
+	// QuarantinedAt is set when the visitors have to confirm a warning before they are redirected
+	QuarantinedAt    *time.Time `json:"quarantinedAt,omitempty"`
+	QuarantineReason string     `json:"quarantineReason,omitempty"`
+}
+
+func (l *Link) IsExpired(now time.Time) bool {
+	return l.ExpiresAt != nil && now.Before(*l.ExpiresAt) == false
+}
+
+func (l *Link) IsDisabled() bool {
+	return l.DisabledAt != nil
+}
+
+func (l *Link) IsQuarantined() bool {
+	return l.QuarantinedAt != nil
+}
+
+type Store interface {
+	// Create stores a new link and returns ErrCodeExists when its code is already taken.
+	Create(link *Link) error
+	// Get returns the link with the code or ErrNotFound.
+	Get(code string) (*Link, error)
+	// Update replaces a stored link and returns ErrNotFound when it does not exist.
+	Update(link *Link) error
+	// Modify applies the change to the current version of the stored link and saves it atomically, so
+	// that concurrent changes of other fields are kept. The link is left unchanged when the change
+	// returns an error, which is returned as is. It returns ErrNotFound when the link does not exist.
+	Modify(code string, change func(link *Link) error) (*Link, error)
+	// Delete removes the link with the code and returns ErrNotFound when it does not exist.
+	Delete(code string) error
+	// List returns all links ordered by their code.
+	List() ([]*Link, error)
+	// ExpiredUnnotified returns the links which expired by now and whose expiry was not announced yet.
+	ExpiredUnnotified(now time.Time) ([]*Link, error)
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu    sync.RWMutex
+	links map[string]Link
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{links: make(map[string]Link)}
+}
+
+func (s *memoryStore) Create(link *Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[link.Code]; ok {
+		return ErrCodeExists
+	}
+
+	s.links[link.Code] = *link
+	return nil
+}
+
+func (s *memoryStore) Get(code string) (*Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.links[code]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	return &link, nil
+}
+
+func (s *memoryStore) Update(link *Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[link.Code]; ok == false {
+		return ErrNotFound
+	}
+
+	s.links[link.Code] = *link
+	return nil
+}
+
+func (s *memoryStore) Modify(code string, change func(link *Link) error) (*Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[code]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	if err := change(&link); err != nil {
+		return nil, err
+	}
+
+	s.links[code] = link
+	return &link, nil
+}
+
+func (s *memoryStore) Delete(code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[code]; ok == false {
+		return ErrNotFound
+	}
+
+	delete(s.links, code)
+	return nil
+}
+
+func (s *memoryStore) List() ([]*Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*Link, 0, len(s.links))
+	for _, link := range s.links {
+		link := link
+		result = append(result, &link)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Code < result[j].Code
+	})
+
+	return result, nil
+}
+
+func (s *memoryStore) ExpiredUnnotified(now time.Time) ([]*Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Link
+	for _, link := range s.links {
+		if link.IsExpired(now) && link.ExpiryNotified == false {
+			link := link
+			result = append(result, &link)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ExpiresAt.Before(*result[j].ExpiresAt)
+	})
+
+	return result, nil
+}
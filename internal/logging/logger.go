@@ -84,20 +84,48 @@ func (l *lynklyLogger) SetLevel(level logrus.Level) {
 const (
 	AccountIDName = "accountID"
 	ClientIDName  = "clientID"
+	RequestIDName = "requestID"
 	SubKey        = "sub"
 	UserKey       = "user"
+	// RequestIDKey holds the ID assigned to the request by the router
+	RequestIDKey = "requestID"
 )
 
+// subject is implemented by the identities stored under UserKey which are not a *jwt.Token,
+// e.g. the principals authenticated with an API key
+type subject interface {
+	Subject() string
+}
+
+// account is implemented by the identities stored under UserKey which know the account (workspace)
+// of the request
+type account interface {
+	Account() string
+}
+
 func requestFields(r *http.Request) logrus.Fields {
 	accountID := "N/A"
 	clientID := "N/A"
-	currentUser := common.ContextGet(r, UserKey)
-	if currentUser != nil {
-		claims := currentUser.(*jwt.Token).Claims.(jwt.MapClaims)
-		clientID = claims[SubKey].(string)
+	switch currentUser := common.ContextGet(r, UserKey).(type) {
+	case *jwt.Token:
+		if claims, ok := currentUser.Claims.(jwt.MapClaims); ok {
+			if sub, ok := claims[SubKey].(string); ok {
+				clientID = sub
+			}
+		}
+	case subject:
+		clientID = currentUser.Subject()
+		if a, ok := currentUser.(account); ok && a.Account() != "" {
+			accountID = a.Account()
+		}
 	}
 
-	return logrus.Fields{
+	fields := logrus.Fields{
 		AccountIDName: accountID,
 		ClientIDName:  clientID}
+	if requestID, ok := common.ContextGet(r, RequestIDKey).(string); ok {
+		fields[RequestIDName] = requestID
+	}
+
+	return fields
 }
@@ -1,6 +1,12 @@
 package logging
 
-import "github.com/sirupsen/logrus"
+import (
+	"github.com/dgrijalva/jwt-go"
+	"github.com/sirupsen/logrus"
+	"lynkly-backend/internal/common"
+	"net/http/httptest"
+	"testing"
+)
 
 // MockLogger defines a mockable interface for testing purposes
 type MockLogger interface {
@@ -10,3 +16,43 @@ type MockLogger interface {
 	Fatal(args ...interface{})
 	WithFields(fields logrus.Fields) *MockLogger
 }
+
+// testIdentity is an identity of a request which knows its account
+type testIdentity struct {
+	subject string
+	account string
+}
+
+func (i testIdentity) Subject() string {
+	return i.subject
+}
+
+func (i testIdentity) Account() string {
+	return i.account
+}
+
+func TestRequestFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     interface{}
+		expected logrus.Fields
+	}{
+		{"anonymous", nil, logrus.Fields{AccountIDName: "N/A", ClientIDName: "N/A"}},
+		{"token", &jwt.Token{Claims: jwt.MapClaims{SubKey: "user"}}, logrus.Fields{AccountIDName: "N/A", ClientIDName: "user"}},
+		{"identity", testIdentity{"user", "workspace"}, logrus.Fields{AccountIDName: "workspace", ClientIDName: "user"}},
+		{"identity without account", testIdentity{"user", ""}, logrus.Fields{AccountIDName: "N/A", ClientIDName: "user"}},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/api/v1/links", nil)
+		r = common.ContextSet(r, common.ContextPair{Key: RequestIDKey, Value: "request"})
+		if test.user != nil {
+			r = common.ContextSet(r, common.ContextPair{Key: UserKey, Value: test.user})
+		}
+
+		fields := requestFields(r)
+		if fields[AccountIDName] != test.expected[AccountIDName] || fields[ClientIDName] != test.expected[ClientIDName] || fields[RequestIDName] != "request" {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, fields)
+		}
+	}
+}
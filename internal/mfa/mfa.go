@@ -0,0 +1,306 @@
+package mfa
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"lynkly-backend/internal/common"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIssuer       = "lynkly"
+	defaultChallengeTTL = 5 * time.Minute
+	recoveryCodeCount   = 10
+	// maxChallengeAttempts is the number of wrong codes after which the login has to start over
+	maxChallengeAttempts = 5
+)
+
+var (
+	ErrNotEnrolled      = errors.New("two-factor authentication is not enabled")
+	ErrAlreadyEnrolled  = errors.New("two-factor authentication is already enabled")
+	ErrInvalidCode      = errors.New("invalid two-factor authentication code")
+	ErrUnknownChallenge = errors.New("unknown or expired two-factor login - please log in again")
+)
+
+// Enrollment is the TOTP secret of a user. It takes effect once confirmed with a code. Only the
+// SHA-256 hashes of the recovery codes are stored.
+type Enrollment struct {
+	UserID             string
+	Secret             string
+	CreatedAt          time.Time
+	ConfirmedAt        *time.Time
+	LastUsedStep       int64
+	RecoveryCodeHashes []string
+}
+
+func (e *Enrollment) IsConfirmed() bool {
+	return e.ConfirmedAt != nil
+}
+
+// Challenge is a login waiting for the second factor. It is addressed by the hash of its token.
+type Challenge struct {
+	TokenHash string
+	UserID    string
+	// AuthMethod is the first factor the user logged in with
+	AuthMethod string
+	ExpiresAt  time.Time
+	Attempts   int
+}
+
+type Params struct {
+	Store      Store
+	Challenges ChallengeStore
+	// Issuer names the service in the authenticator apps
+	Issuer       string
+	ChallengeTTL time.Duration
+}
+
+// MFA enrolls users in TOTP two-factor authentication and verifies their codes.
+type MFA struct {
+	store        Store
+	challenges   ChallengeStore
+	issuer       string
+	challengeTTL time.Duration
+	// mu serializes the verifications, so that a code cannot be used twice by concurrent requests
+	mu sync.Mutex
+}
+
+func New(params Params) *MFA {
+	if params.Issuer == "" {
+		params.Issuer = defaultIssuer
+	}
+	if params.ChallengeTTL <= 0 {
+		params.ChallengeTTL = defaultChallengeTTL
+	}
+
+	return &MFA{
+		store:        params.Store,
+		challenges:   params.Challenges,
+		issuer:       params.Issuer,
+		challengeTTL: params.ChallengeTTL,
+	}
+}
+
+func (m *MFA) Store() Store {
+	return m.store
+}
+
+// Enabled reports whether the user has confirmed two-factor authentication.
+func (m *MFA) Enabled(userID string) (bool, error) {
+	enrollment, err := m.store.Get(userID)
+	if err == ErrNotEnrolled {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return enrollment.IsConfirmed(), nil
+}
+
+// Begin generates a new secret for the user and returns it with its provisioning URI. An unconfirmed
+// enrollment is replaced.
+func (m *MFA) Begin(userID, account string) (string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	enrollment, err := m.store.Get(userID)
+	if err == nil && enrollment.IsConfirmed() {
+		return "", "", ErrAlreadyEnrolled
+	} else if err != nil && err != ErrNotEnrolled {
+		return "", "", err
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	err = m.store.Save(&Enrollment{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return secret, ProvisioningURI(m.issuer, account, secret), nil
+}
+
+// Confirm enables two-factor authentication with the first code of the authenticator app and returns
+// the recovery codes, which cannot be recovered later.
+func (m *MFA) Confirm(userID, code string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	enrollment, err := m.store.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if enrollment.IsConfirmed() {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	now := time.Now().UTC()
+	step, ok := validateCode(enrollment.Secret, code, now, enrollment.LastUsedStep)
+	if ok == false {
+		return nil, ErrInvalidCode
+	}
+
+	codes := newRecoveryCodes(enrollment)
+	enrollment.ConfirmedAt = &now
+	enrollment.LastUsedStep = step
+	if err = m.store.Save(enrollment); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Verify checks a TOTP code or a recovery code of the user. A recovery code can be used once.
+func (m *MFA) Verify(userID, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	enrollment, err := m.store.Get(userID)
+	if err != nil {
+		return err
+	}
+
+	if enrollment.IsConfirmed() == false {
+		return ErrNotEnrolled
+	}
+
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if step, ok := validateCode(enrollment.Secret, code, time.Now(), enrollment.LastUsedStep); ok {
+		enrollment.LastUsedStep = step
+		return m.store.Save(enrollment)
+	}
+
+	codeHash := hashRecoveryCode(code)
+	for i, recoveryCodeHash := range enrollment.RecoveryCodeHashes {
+		if subtle.ConstantTimeCompare([]byte(recoveryCodeHash), []byte(codeHash)) == 1 {
+			enrollment.RecoveryCodeHashes = append(enrollment.RecoveryCodeHashes[:i:i], enrollment.RecoveryCodeHashes[i+1:]...)
+			return m.store.Save(enrollment)
+		}
+	}
+
+	return ErrInvalidCode
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user after verifying a code.
+func (m *MFA) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	if err := m.Verify(userID, code); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	enrollment, err := m.store.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := newRecoveryCodes(enrollment)
+	if err = m.store.Save(enrollment); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable turns two-factor authentication off after verifying a code.
+func (m *MFA) Disable(userID, code string) error {
+	if err := m.Verify(userID, code); err != nil {
+		return err
+	}
+
+	return m.store.Delete(userID)
+}
+
+// StartChallenge holds the login of the user with the first factor until the second factor is
+// verified and returns the token completing it.
+func (m *MFA) StartChallenge(userID, authMethod string) (string, error) {
+	token := common.RandomHex(32)
+	err := m.challenges.Save(&Challenge{
+		TokenHash:  hashToken(token),
+		UserID:     userID,
+		AuthMethod: authMethod,
+		ExpiresAt:  time.Now().UTC().Add(m.challengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// CompleteChallenge verifies the code of the login and returns it. The login can be completed once
+// and is dropped after too many wrong codes.
+func (m *MFA) CompleteChallenge(token, code string) (*Challenge, error) {
+	tokenHash := hashToken(token)
+	challenge, err := m.challenges.Get(tokenHash, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// the attempt is counted before the code is verified, so that concurrent guesses are bounded as well
+	attempts, err := m.challenges.IncrementAttempts(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if attempts > maxChallengeAttempts {
+		if err = m.challenges.Delete(tokenHash); err != nil && err != ErrUnknownChallenge {
+			return nil, err
+		}
+		return nil, ErrUnknownChallenge
+	}
+
+	err = m.Verify(challenge.UserID, code)
+	if err == ErrInvalidCode {
+		if attempts == maxChallengeAttempts {
+			if err = m.challenges.Delete(tokenHash); err != nil && err != ErrUnknownChallenge {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidCode
+	} else if err != nil {
+		return nil, err
+	}
+
+	// fails with ErrUnknownChallenge when a concurrent request completed the login first
+	if err = m.challenges.Delete(tokenHash); err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// newRecoveryCodes replaces the recovery codes of the enrollment and returns them.
+func newRecoveryCodes(enrollment *Enrollment) []string {
+	codes := make([]string, recoveryCodeCount)
+	enrollment.RecoveryCodeHashes = make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = common.RandomHex(5) + "-" + common.RandomHex(5)
+		enrollment.RecoveryCodeHashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	return codes
+}
+
+// hashRecoveryCode hashes the code ignoring its case and dashes. The codes are random enough for a
+// plain SHA-256 hash.
+func hashRecoveryCode(code string) string {
+	return hashToken(strings.ToLower(strings.ReplaceAll(code, "-", "")))
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,173 @@
+package mfa
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the test vectors of RFC 6238, appendix B
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestCodeAtRFC6238(t *testing.T) {
+	// the vectors have 8 digits, the 6 digit codes are their last digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, test := range tests {
+		if code := codeAt(rfc6238Secret, test.unix/30); code != test.code {
+			t.Errorf("Expected code %s at %d, got %s", test.code, test.unix, code)
+		}
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	secret := secretEncoding.EncodeToString(rfc6238Secret)
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / 30
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		step     int64
+		valid    bool
+	}{
+		{"current step", "050471", 0, current, true},
+		{"previous step", codeAt(rfc6238Secret, current-1), 0, current - 1, true},
+		{"next step", codeAt(rfc6238Secret, current+1), 0, current + 1, true},
+		{"outside of the skew", codeAt(rfc6238Secret, current-2), 0, 0, false},
+		{"replayed", "050471", current, 0, false},
+		{"wrong code", "123456", 0, 0, false},
+		{"wrong length", "50471", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, ok := validateCode(secret, test.code, now, test.lastStep)
+			if ok != test.valid || step != test.step {
+				t.Errorf("Expected %v at step %d, got %v at step %d", test.valid, test.step, ok, step)
+			}
+		})
+	}
+}
+
+func newEnrolledMFA(t *testing.T) (*MFA, string) {
+	t.Helper()
+
+	secret, err := generateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	m := New(Params{Store: NewMemoryStore(), Challenges: NewMemoryChallengeStore()})
+	err = m.Store().Save(&Enrollment{
+		UserID:      "user",
+		Secret:      secret,
+		CreatedAt:   now,
+		ConfirmedAt: &now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m, secret
+}
+
+func currentCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	key, err := secretEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return codeAt(key, time.Now().Unix()/30)
+}
+
+func TestCompleteChallenge(t *testing.T) {
+	m, secret := newEnrolledMFA(t)
+
+	token, err := m.StartChallenge("user", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.CompleteChallenge(token, "000000"); err != ErrInvalidCode {
+		t.Errorf("Expected ErrInvalidCode, got %v", err)
+	}
+
+	challenge, err := m.CompleteChallenge(token, currentCode(t, secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.UserID != "user" || challenge.AuthMethod != "password" {
+		t.Errorf("Unexpected challenge %+v", challenge)
+	}
+
+	if _, err = m.CompleteChallenge(token, currentCode(t, secret)); err != ErrUnknownChallenge {
+		t.Errorf("Expected the challenge to be completed once, got %v", err)
+	}
+}
+
+func TestCompleteChallengeLimitsConcurrentAttempts(t *testing.T) {
+	m, secret := newEnrolledMFA(t)
+
+	token, err := m.StartChallenge("user", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	verified := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.CompleteChallenge(token, "000000"); err == ErrInvalidCode {
+				mu.Lock()
+				verified++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if verified > maxChallengeAttempts {
+		t.Errorf("Expected at most %d codes to be verified, got %d", maxChallengeAttempts, verified)
+	}
+
+	if _, err = m.CompleteChallenge(token, currentCode(t, secret)); err != ErrUnknownChallenge {
+		t.Errorf("Expected the challenge to be dropped after too many attempts, got %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	m, secret := newEnrolledMFA(t)
+
+	codes, err := m.RegenerateRecoveryCodes("user", currentCode(t, secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %d", recoveryCodeCount, len(codes))
+	}
+
+	if err = m.Verify("user", codes[0]); err != nil {
+		t.Errorf("Expected the recovery code to be valid, got %v", err)
+	}
+	if err = m.Verify("user", codes[0]); err != ErrInvalidCode {
+		t.Errorf("Expected the recovery code to be used once, got %v", err)
+	}
+}
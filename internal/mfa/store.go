@@ -0,0 +1,135 @@
+package mfa
+
+import (
+	"sync"
+	"time"
+)
+
+type Store interface {
+	// Get returns the enrollment of the user or ErrNotEnrolled.
+	Get(userID string) (*Enrollment, error)
+	// Save creates or replaces the enrollment of the user.
+	Save(enrollment *Enrollment) error
+	// Delete removes the enrollment and returns ErrNotEnrolled when it does not exist.
+	Delete(userID string) error
+}
+
+// ChallengeStore holds the logins waiting for the second factor.
+type ChallengeStore interface {
+	Save(challenge *Challenge) error
+	// Get returns the unexpired challenge with the token hash or ErrUnknownChallenge.
+	Get(tokenHash string, now time.Time) (*Challenge, error)
+	// Delete removes the challenge and returns ErrUnknownChallenge when it does not exist, so that
+	// a challenge can be completed once.
+	Delete(tokenHash string) error
+	// IncrementAttempts atomically counts an attempt of the challenge and returns the number of its
+	// attempts including this one, or ErrUnknownChallenge.
+	IncrementAttempts(tokenHash string) (int, error)
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu          sync.RWMutex
+	enrollments map[string]Enrollment
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{enrollments: make(map[string]Enrollment)}
+}
+
+func (s *memoryStore) Get(userID string) (*Enrollment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	enrollment, ok := s.enrollments[userID]
+	if ok == false {
+		return nil, ErrNotEnrolled
+	}
+
+	enrollment.RecoveryCodeHashes = append([]string(nil), enrollment.RecoveryCodeHashes...)
+	return &enrollment, nil
+}
+
+func (s *memoryStore) Save(enrollment *Enrollment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.enrollments[enrollment.UserID] = *enrollment
+	return nil
+}
+
+func (s *memoryStore) Delete(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.enrollments[userID]; ok == false {
+		return ErrNotEnrolled
+	}
+
+	delete(s.enrollments, userID)
+	return nil
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryChallengeStore struct {
+	mu         sync.Mutex
+	challenges map[string]Challenge
+}
+
+func NewMemoryChallengeStore() ChallengeStore {
+	return &memoryChallengeStore{challenges: make(map[string]Challenge)}
+}
+
+func (s *memoryChallengeStore) Save(challenge *Challenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// abandoned logins are removed here instead of by a background job
+	now := time.Now()
+	for tokenHash, pending := range s.challenges {
+		if now.After(pending.ExpiresAt) {
+			delete(s.challenges, tokenHash)
+		}
+	}
+
+	s.challenges[challenge.TokenHash] = *challenge
+	return nil
+}
+
+func (s *memoryChallengeStore) Get(tokenHash string, now time.Time) (*Challenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[tokenHash]
+	if ok == false || now.After(challenge.ExpiresAt) {
+		return nil, ErrUnknownChallenge
+	}
+
+	return &challenge, nil
+}
+
+func (s *memoryChallengeStore) Delete(tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.challenges[tokenHash]; ok == false {
+		return ErrUnknownChallenge
+	}
+
+	delete(s.challenges, tokenHash)
+	return nil
+}
+
+func (s *memoryChallengeStore) IncrementAttempts(tokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[tokenHash]
+	if ok == false {
+		return 0, ErrUnknownChallenge
+	}
+
+	challenge.Attempts++
+	s.challenges[tokenHash] = challenge
+	return challenge.Attempts, nil
+}
@@ -0,0 +1,83 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// TOTP parameters of RFC 6238 supported by every common authenticator app
+const (
+	secretLength = 20
+	codeDigits   = 6
+	stepPeriod   = 30 * time.Second
+	// skewSteps is the number of steps accepted before and after the current one to tolerate clock skew
+	skewSteps = 1
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func generateSecret() (string, error) {
+	secret := make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return secretEncoding.EncodeToString(secret), nil
+}
+
+// ProvisioningURI returns the otpauth:// URI of the secret, which authenticator apps import from a QR code.
+func ProvisioningURI(issuer, account, secret string) string {
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprint(codeDigits))
+	values.Set("period", fmt.Sprint(int(stepPeriod/time.Second)))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
+
+// validateCode checks the code against the steps around now and returns the matching step. Steps up to
+// lastStep were already used and are rejected, so that a code cannot be replayed.
+func validateCode(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	key, err := secretEncoding.DecodeString(secret)
+	if err != nil || len(code) != codeDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / int64(stepPeriod/time.Second)
+	for step := current - skewSteps; step <= current+skewSteps; step++ {
+		if step <= lastStep {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(codeAt(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// codeAt computes the HOTP value (RFC 4226) of the step.
+func codeAt(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", codeDigits, value%1000000)
+}
@@ -25,6 +25,7 @@ const (
 	jsonType
 	textType
 	redirectType
+	binaryType
 )
 
 // HttpResponse holds the data for a http response. It is meant to be used by Write
@@ -99,6 +100,8 @@ func newErrorResponse(statusCode int) *HttpResponse {
 // headers
 const (
 	AuthorizationHeader       = "Authorization"
+	RequestIDHeader           = "X-Request-ID"
+	RetryAfterHeader          = "Retry-After"
 	AcceptHeader              = "Accept"
 	ContentTypeHeader         = "Content-Type"
 	ContentTypeOptions        = "X-Content-Type-Options"
@@ -111,6 +114,7 @@ const (
 	ContentAppOctetStream     = "application/octet-stream"
 	ContentTextYAML           = "text/yaml"
 	ContentTextHTML           = "text/html;charset=utf-8"
+	ContentImageGIF           = "image/gif"
 )
 
 // Write is writing the data of the response to the provided http.ResponseWriter.
@@ -148,6 +152,9 @@ func writePayload(w http.ResponseWriter, r *http.Request, response *HttpResponse
 		return err
 	case jsonType:
 		return jsoniter.NewEncoder(w).Encode(&response.payload)
+	case binaryType:
+		_, err := w.Write(response.payload.([]byte))
+		return err
 	case emptyType:
 		return nil
 	}
@@ -179,6 +186,15 @@ func writeEtagPayload(w http.ResponseWriter, r *http.Request, response *HttpResp
 		w.WriteHeader(response.statusCode)
 		_, err = w.Write(res)
 		return err
+	case binaryType:
+		res := response.payload.([]byte)
+		if ok, err := handleEtag(w, r, res); ok {
+			return err
+		}
+
+		w.WriteHeader(response.statusCode)
+		_, err := w.Write(res)
+		return err
 	case emptyType:
 		return nil
 	}
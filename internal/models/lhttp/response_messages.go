@@ -65,6 +65,15 @@ func (p *PartialSuccess) WithText(text string) *HttpResponse {
 	return p.response
 }
 
+// WithBinary adds a raw payload of the given content type to the response, e.g. an image.
+func (p *PartialSuccess) WithBinary(contentType string, payload []byte) *HttpResponse {
+	p.response.payload = payload
+	p.response.payloadType = binaryType
+	p.response.contentType = contentType
+
+	return p.response
+}
+
 func (p *PartialSuccess) Etag() *PartialSuccess {
 	p.response.etag = true
 
@@ -275,6 +284,14 @@ func PreconditionFailed() *PartialFail {
 	)}
 }
 
+// TooManyRequests returns a PartialFail. To use it as a response you need to select either FromTrustedError
+// or FromTrustedMessage and provide a user-friendly info in both cases.
+func TooManyRequests() *PartialFail {
+	return &PartialFail{newErrorResponse(
+		http.StatusTooManyRequests,
+	)}
+}
+
 /* feel free to add 4xx responses above this line */
 
 //endregion 4xx
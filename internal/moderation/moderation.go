@@ -0,0 +1,266 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"lynkly-backend/internal/common"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MaxDetailsLength = 1000
+	MaxNoteLength    = 1000
+)
+
+var (
+	ErrNotFound        = errors.New("report not found")
+	ErrInvalidReason   = errors.New("invalid reason - expected one of phishing, malware, spam, illegal or other")
+	ErrDetailsTooLong  = fmt.Errorf("details are too long - expected up to %d characters", MaxDetailsLength)
+	ErrNoteTooLong     = fmt.Errorf("note is too long - expected up to %d characters", MaxNoteLength)
+	ErrInvalidAction   = errors.New("invalid action - expected one of disable, quarantine, restore or delete")
+	ErrAlreadyResolved = errors.New("report has already been resolved")
+)
+
+// Reason is the kind of abuse a link is reported for.
+type Reason string
+
+const (
+	ReasonPhishing Reason = "phishing"
+	ReasonMalware  Reason = "malware"
+	ReasonSpam     Reason = "spam"
+	ReasonIllegal  Reason = "illegal"
+	ReasonOther    Reason = "other"
+)
+
+var reasons = map[Reason]bool{
+	ReasonPhishing: true,
+	ReasonMalware:  true,
+	ReasonSpam:     true,
+	ReasonIllegal:  true,
+	ReasonOther:    true,
+}
+
+func (r Reason) IsValid() bool {
+	return reasons[r]
+}
+
+type Status string
+
+const (
+	StatusOpen      Status = "open"
+	StatusResolved  Status = "resolved"
+	StatusDismissed Status = "dismissed"
+)
+
+func (s Status) IsValid() bool {
+	return s == StatusOpen || s == StatusResolved || s == StatusDismissed
+}
+
+// Action is what a moderator does with a reported link.
+type Action string
+
+const (
+	// ActionDisable stops the link from redirecting
+	ActionDisable Action = "disable"
+	// ActionQuarantine shows a warning page which the visitors have to confirm before being redirected
+	ActionQuarantine Action = "quarantine"
+	// ActionRestore lifts a disable or a quarantine
+	ActionRestore Action = "restore"
+	ActionDelete  Action = "delete"
+	// ActionDismiss closes a report without changing the link
+	ActionDismiss Action = "dismiss"
+)
+
+// IsLinkAction reports whether the action changes the link.
+func (a Action) IsLinkAction() bool {
+	return a == ActionDisable || a == ActionQuarantine || a == ActionRestore || a == ActionDelete
+}
+
+// Report is a complaint about a short link. Reports can be filed anonymously, so only what the
+// reporter entered is stored.
+type Report struct {
+	ID       string `json:"id"`
+	Sequence int64  `json:"sequence"`
+	Code     string `json:"code"`
+	// WorkspaceID is the workspace of the link at the time of the report
+	WorkspaceID string     `json:"workspaceId"`
+	Reason      Reason     `json:"reason"`
+	Details     string     `json:"details,omitempty"`
+	Status      Status     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ResolvedAt  *time.Time `json:"resolvedAt,omitempty"`
+	ResolvedBy  string     `json:"resolvedBy,omitempty"`
+	// Resolution is the action which closed the report
+	Resolution Action `json:"resolution,omitempty"`
+}
+
+// Event is an entry of the moderation history of a link. The history outlives deleted links.
+type Event struct {
+	ID          string    `json:"id"`
+	Code        string    `json:"code"`
+	Action      Action    `json:"action"`
+	Note        string    `json:"note,omitempty"`
+	ModeratorID string    `json:"moderatorId"`
+	ReportIDs   []string  `json:"reportIds"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type Params struct {
+	Store Store
+	// ModeratorIDs are the IDs of the users who may review the reports of every workspace
+	ModeratorIDs []string
+}
+
+// Moderation collects abuse reports and records the decisions of the moderators on them.
+type Moderation struct {
+	store      Store
+	moderators map[string]bool
+	// mu serializes the resolutions, so that a report is resolved by one decision only
+	mu sync.Mutex
+}
+
+func New(params Params) *Moderation {
+	moderators := make(map[string]bool, len(params.ModeratorIDs))
+	for _, id := range params.ModeratorIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			moderators[id] = true
+		}
+	}
+
+	return &Moderation{
+		store:      params.Store,
+		moderators: moderators,
+	}
+}
+
+func (m *Moderation) Store() Store {
+	return m.store
+}
+
+func (m *Moderation) IsModerator(userID string) bool {
+	return m.moderators[userID]
+}
+
+// Report files an open report about the link with the code.
+func (m *Moderation) Report(code, workspaceID string, reason Reason, details string) (*Report, error) {
+	if reason.IsValid() == false {
+		return nil, ErrInvalidReason
+	}
+	details = strings.TrimSpace(details)
+	if len([]rune(details)) > MaxDetailsLength {
+		return nil, ErrDetailsTooLong
+	}
+
+	report := &Report{
+		ID:          common.NewID(),
+		Code:        code,
+		WorkspaceID: workspaceID,
+		Reason:      reason,
+		Details:     details,
+		Status:      StatusOpen,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := m.store.CreateReport(report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Decide records the action of the moderator on the link with the code in its history and resolves
+// the open reports of the link. Applying the action to the link is up to the caller.
+func (m *Moderation) Decide(code string, action Action, moderatorID, note string) (*Event, error) {
+	if err := ValidateDecision(action, note); err != nil {
+		return nil, err
+	}
+	note = strings.TrimSpace(note)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	open, err := m.store.ListReports(Query{Code: code, Status: StatusOpen})
+	if err != nil {
+		return nil, err
+	}
+
+	// restoring a link does not settle the complaints about it
+	var resolved []*Report
+	if action != ActionRestore {
+		resolved = open
+	}
+
+	return m.resolve(code, action, moderatorID, note, resolved)
+}
+
+// ValidateDecision checks the action and the note of a decision before it is applied to the link.
+func ValidateDecision(action Action, note string) error {
+	if action.IsLinkAction() == false {
+		return ErrInvalidAction
+	}
+	if len([]rune(strings.TrimSpace(note))) > MaxNoteLength {
+		return ErrNoteTooLong
+	}
+
+	return nil
+}
+
+// Dismiss closes the open report without changing the link and records it in the history of the link.
+func (m *Moderation) Dismiss(reportID, moderatorID, note string) (*Report, *Event, error) {
+	note = strings.TrimSpace(note)
+	if len([]rune(note)) > MaxNoteLength {
+		return nil, nil, ErrNoteTooLong
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report, err := m.store.Report(reportID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if report.Status != StatusOpen {
+		return nil, nil, ErrAlreadyResolved
+	}
+
+	event, err := m.resolve(report.Code, ActionDismiss, moderatorID, note, []*Report{report})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return report, event, nil
+}
+
+func (m *Moderation) resolve(code string, action Action, moderatorID, note string, reports []*Report) (*Event, error) {
+	now := time.Now().UTC()
+	event := &Event{
+		ID:          common.NewID(),
+		Code:        code,
+		Action:      action,
+		Note:        note,
+		ModeratorID: moderatorID,
+		ReportIDs:   make([]string, 0, len(reports)),
+		CreatedAt:   now,
+	}
+
+	for _, report := range reports {
+		report.Status = StatusResolved
+		if action == ActionDismiss {
+			report.Status = StatusDismissed
+		}
+		report.ResolvedAt = &now
+		report.ResolvedBy = moderatorID
+		report.Resolution = action
+		if err := m.store.UpdateReport(report); err != nil {
+			return nil, err
+		}
+		event.ReportIDs = append(event.ReportIDs, report.ID)
+	}
+
+	if err := m.store.AppendEvent(event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
@@ -0,0 +1,186 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestModeration() *Moderation {
+	return New(Params{Store: NewMemoryStore(), ModeratorIDs: []string{" moderator ", ""}})
+}
+
+func TestIsModerator(t *testing.T) {
+	m := newTestModeration()
+
+	tests := map[string]bool{
+		"moderator":   true,
+		" moderator ": false,
+		"":            false,
+		"user":        false,
+	}
+
+	for userID, expected := range tests {
+		if moderator := m.IsModerator(userID); moderator != expected {
+			t.Errorf("%q: expected %v, got %v", userID, expected, moderator)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	m := newTestModeration()
+
+	report, err := m.Report("abc", "workspace", ReasonPhishing, "  fake login page  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != StatusOpen || report.Details != "fake login page" || report.Sequence != 1 {
+		t.Errorf("Expected an open report with trimmed details, got %+v", report)
+	}
+
+	stored, err := m.Store().Report(report.ID)
+	if err != nil || stored.Code != "abc" || stored.WorkspaceID != "workspace" {
+		t.Errorf("Expected the report to be stored, got %+v (%v)", stored, err)
+	}
+
+	if _, err = m.Report("abc", "workspace", "abuse", ""); err != ErrInvalidReason {
+		t.Errorf("Expected ErrInvalidReason, got %v", err)
+	}
+	if _, err = m.Report("abc", "workspace", ReasonSpam, strings.Repeat("é", MaxDetailsLength+1)); err != ErrDetailsTooLong {
+		t.Errorf("Expected ErrDetailsTooLong, got %v", err)
+	}
+	if _, err = m.Report("abc", "workspace", ReasonSpam, strings.Repeat("é", MaxDetailsLength)); err != nil {
+		t.Errorf("Expected details of the maximum length to be allowed, got %v", err)
+	}
+}
+
+func TestListReports(t *testing.T) {
+	m := newTestModeration()
+	for _, code := range []string{"a", "b", "a", "c", "a"} {
+		if _, err := m.Report(code, "workspace", ReasonSpam, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []int64
+	}{
+		{"all", Query{}, []int64{1, 2, 3, 4, 5}},
+		{"code", Query{Code: "a"}, []int64{1, 3, 5}},
+		{"limit", Query{Limit: 2}, []int64{1, 2}},
+		{"next page", Query{AfterSequence: 2, Limit: 2}, []int64{3, 4}},
+		{"last page", Query{AfterSequence: 4, Limit: 2}, []int64{5}},
+		{"status", Query{Status: StatusResolved}, nil},
+	}
+
+	for _, test := range tests {
+		reports, err := m.Store().ListReports(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var sequences []int64
+		for _, report := range reports {
+			sequences = append(sequences, report.Sequence)
+		}
+		if len(sequences) != len(test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, sequences)
+			continue
+		}
+		for i := range sequences {
+			if sequences[i] != test.expected[i] {
+				t.Errorf("%s: expected %v, got %v", test.name, test.expected, sequences)
+				break
+			}
+		}
+	}
+}
+
+func TestDecide(t *testing.T) {
+	m := newTestModeration()
+	first, _ := m.Report("abc", "workspace", ReasonPhishing, "")
+	second, _ := m.Report("abc", "workspace", ReasonMalware, "")
+	other, _ := m.Report("other", "workspace", ReasonSpam, "")
+
+	// restoring a link leaves its reports open
+	event, err := m.Decide("abc", ActionRestore, "moderator", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(event.ReportIDs) != 0 {
+		t.Errorf("Expected no report to be resolved by a restore, got %v", event.ReportIDs)
+	}
+
+	event, err = m.Decide("abc", ActionDisable, "moderator", " confirmed phishing ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Action != ActionDisable || event.Note != "confirmed phishing" || event.ModeratorID != "moderator" {
+		t.Errorf("Expected the decision to be recorded, got %+v", event)
+	}
+	if len(event.ReportIDs) != 2 || event.ReportIDs[0] != first.ID || event.ReportIDs[1] != second.ID {
+		t.Errorf("Expected the open reports of the link to be resolved, got %v", event.ReportIDs)
+	}
+
+	for _, id := range []string{first.ID, second.ID} {
+		report, _ := m.Store().Report(id)
+		if report.Status != StatusResolved || report.Resolution != ActionDisable || report.ResolvedBy != "moderator" || report.ResolvedAt == nil {
+			t.Errorf("Expected report %s to be resolved, got %+v", id, report)
+		}
+	}
+	if report, _ := m.Store().Report(other.ID); report.Status != StatusOpen {
+		t.Errorf("Expected the report of another link to stay open, got %+v", report)
+	}
+
+	// the reports are resolved once
+	if event, err = m.Decide("abc", ActionDelete, "moderator", ""); err != nil || len(event.ReportIDs) != 0 {
+		t.Errorf("Expected no open report to be left, got %+v (%v)", event, err)
+	}
+
+	history, err := m.Store().History("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].Action != ActionRestore || history[1].Action != ActionDisable || history[2].Action != ActionDelete {
+		t.Errorf("Expected the decisions in the history of the link, got %v", history)
+	}
+
+	if _, err = m.Decide("abc", ActionDismiss, "moderator", ""); err != ErrInvalidAction {
+		t.Errorf("Expected ErrInvalidAction, got %v", err)
+	}
+	if _, err = m.Decide("abc", ActionDisable, "moderator", strings.Repeat("a", MaxNoteLength+1)); err != ErrNoteTooLong {
+		t.Errorf("Expected ErrNoteTooLong, got %v", err)
+	}
+}
+
+func TestDismiss(t *testing.T) {
+	m := newTestModeration()
+	dismissed, _ := m.Report("abc", "workspace", ReasonOther, "")
+	open, _ := m.Report("abc", "workspace", ReasonSpam, "")
+
+	report, event, err := m.Dismiss(dismissed.ID, "moderator", "not abusive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != StatusDismissed || report.Resolution != ActionDismiss {
+		t.Errorf("Expected the report to be dismissed, got %+v", report)
+	}
+	if event.Action != ActionDismiss || len(event.ReportIDs) != 1 || event.ReportIDs[0] != dismissed.ID {
+		t.Errorf("Expected the dismissal of the report only, got %+v", event)
+	}
+	if stored, _ := m.Store().Report(open.ID); stored.Status != StatusOpen {
+		t.Errorf("Expected the other report of the link to stay open, got %+v", stored)
+	}
+
+	if _, _, err = m.Dismiss(dismissed.ID, "moderator", ""); err != ErrAlreadyResolved {
+		t.Errorf("Expected ErrAlreadyResolved, got %v", err)
+	}
+	if _, _, err = m.Dismiss("missing", "moderator", ""); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if history, _ := m.Store().History("abc"); len(history) != 1 || history[0].Note != "not abusive" {
+		t.Errorf("Expected the dismissal in the history of the link, got %v", history)
+	}
+}
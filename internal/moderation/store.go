@@ -0,0 +1,125 @@
+package moderation
+
+import (
+	"sort"
+	"sync"
+)
+
+// Query selects reports, oldest first. Empty fields match every report and a zero Limit returns all
+// matching reports.
+type Query struct {
+	Status        Status
+	Code          string
+	AfterSequence int64
+	Limit         int
+}
+
+func (q Query) matches(report *Report) bool {
+	return (q.Status == "" || report.Status == q.Status) &&
+		(q.Code == "" || report.Code == q.Code) &&
+		report.Sequence > q.AfterSequence
+}
+
+type Store interface {
+	// CreateReport stores a new report and assigns its sequence number.
+	CreateReport(report *Report) error
+	// Report returns the report with the ID or ErrNotFound.
+	Report(id string) (*Report, error)
+	// UpdateReport replaces a stored report and returns ErrNotFound when it does not exist.
+	UpdateReport(report *Report) error
+	ListReports(query Query) ([]*Report, error)
+	AppendEvent(event *Event) error
+	// History returns the events of the link with the code, oldest first.
+	History(code string) ([]*Event, error)
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu           sync.RWMutex
+	reports      map[string]Report
+	lastSequence int64
+	events       []Event
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{reports: make(map[string]Report)}
+}
+
+func (s *memoryStore) CreateReport(report *Report) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastSequence++
+	report.Sequence = s.lastSequence
+	s.reports[report.ID] = *report
+	return nil
+}
+
+func (s *memoryStore) Report(id string) (*Report, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	report, ok := s.reports[id]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	return &report, nil
+}
+
+func (s *memoryStore) UpdateReport(report *Report) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.reports[report.ID]; ok == false {
+		return ErrNotFound
+	}
+
+	s.reports[report.ID] = *report
+	return nil
+}
+
+func (s *memoryStore) ListReports(query Query) ([]*Report, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Report
+	for _, report := range s.reports {
+		report := report
+		if query.matches(&report) {
+			result = append(result, &report)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Sequence < result[j].Sequence
+	})
+	if query.Limit > 0 && len(result) > query.Limit {
+		result = result[:query.Limit]
+	}
+
+	return result, nil
+}
+
+func (s *memoryStore) AppendEvent(event *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, *event)
+	return nil
+}
+
+func (s *memoryStore) History(code string) ([]*Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Event
+	for _, event := range s.events {
+		if event.Code == code {
+			event := event
+			result = append(result, &event)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// minKeyRefreshInterval limits how often the key set is fetched again for an unknown key ID, so that
+// tokens with made up key IDs cannot be used to flood the identity provider
+const minKeyRefreshInterval = time.Minute
+
+var ErrUnknownKey = errors.New("ID token is signed with an unknown key")
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// keySet caches the RSA signing keys published by the identity provider and fetches them again when
+// a token is signed with a key which is not known yet, e.g. after a key rotation.
+type keySet struct {
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	fetch     func(ctx context.Context) (*jsonWebKeySet, error)
+}
+
+func newKeySet(fetch func(ctx context.Context) (*jsonWebKeySet, error)) *keySet {
+	return &keySet{
+		keys:  make(map[string]*rsa.PublicKey),
+		fetch: fetch,
+	}
+}
+
+// key returns the key with the ID. Tokens without a key ID are accepted only when the provider
+// publishes a single key.
+func (s *keySet) key(ctx context.Context, keyID string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.lookup(keyID); ok {
+		return key, nil
+	}
+
+	if time.Since(s.fetchedAt) < minKeyRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+
+	set, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.keys = parseKeys(set)
+	s.fetchedAt = time.Now()
+
+	if key, ok := s.lookup(keyID); ok {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKey
+}
+
+func (s *keySet) lookup(keyID string) (*rsa.PublicKey, bool) {
+	if keyID == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+
+	key, ok := s.keys[keyID]
+	return key, ok
+}
+
+// parseKeys returns the RSA signing keys of the set by their key ID. Other keys are skipped.
+func parseKeys(set *jsonWebKeySet) map[string]*rsa.PublicKey {
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.KeyType != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+
+		keys[jwk.KeyID] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys
+}
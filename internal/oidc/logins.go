@@ -0,0 +1,77 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrUnknownState = errors.New("unknown or expired login state")
+
+// PendingLogin holds the secrets of an authorization request until the user returns from the
+// identity provider. The state protects the callback against CSRF, the nonce binds the ID token to the
+// request and the verifier is the PKCE secret proving that the code is redeemed by its requester.
+type PendingLogin struct {
+	State     string
+	Nonce     string
+	Verifier  string
+	ExpiresAt time.Time
+}
+
+// LoginStore holds the pending logins. Take has to remove the login, so that a state can be used once.
+type LoginStore interface {
+	Save(login PendingLogin) error
+	// Take returns and removes the unexpired login with the state or ErrUnknownState.
+	Take(state string, now time.Time) (*PendingLogin, error)
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryLoginStore struct {
+	mu     sync.Mutex
+	logins map[string]PendingLogin
+}
+
+func NewMemoryLoginStore() LoginStore {
+	return &memoryLoginStore{logins: make(map[string]PendingLogin)}
+}
+
+func (s *memoryLoginStore) Save(login PendingLogin) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// abandoned logins are removed here instead of by a background job
+	now := time.Now()
+	for state, pending := range s.logins {
+		if now.After(pending.ExpiresAt) {
+			delete(s.logins, state)
+		}
+	}
+
+	s.logins[login.State] = login
+	return nil
+}
+
+func (s *memoryLoginStore) Take(state string, now time.Time) (*PendingLogin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	login, ok := s.logins[state]
+	if ok == false {
+		return nil, ErrUnknownState
+	}
+
+	delete(s.logins, state)
+	if now.After(login.ExpiresAt) {
+		return nil, ErrUnknownState
+	}
+
+	return &login, nil
+}
+
+// codeChallenge derives the S256 PKCE challenge of the verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,377 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"lynkly-backend/internal/common"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	discoveryPath    = "/.well-known/openid-configuration"
+	discoveryTTL     = time.Hour
+	defaultLoginTTL  = 10 * time.Minute
+	httpTimeout      = 10 * time.Second
+	maxResponseSize  = 1 << 20
+	idTokenLeeway    = time.Minute
+	defaultGroupsKey = "groups"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	ErrProviderFailed = errors.New("identity provider request failed")
+)
+
+type Params struct {
+	// IssuerURL is the issuer of the identity provider. The discovery document is read from
+	// <IssuerURL>/.well-known/openid-configuration.
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// GroupsClaim is the ID token claim holding the groups of the user, "groups" by default.
+	GroupsClaim string
+	// LoginTTL is the time the user has for logging in at the identity provider.
+	LoginTTL time.Duration
+	Logins   LoginStore
+	// HTTPClient is used for the requests to the identity provider. A client with a timeout is used when nil.
+	HTTPClient *http.Client
+}
+
+// Discovery is the part of the provider metadata used for the authorization code flow.
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Identity holds the validated claims of an ID token.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Groups        []string
+}
+
+// Provider performs the OpenID Connect authorization code flow with PKCE against an identity provider.
+type Provider struct {
+	issuerURL    string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	groupsClaim  string
+	loginTTL     time.Duration
+	logins       LoginStore
+	client       *http.Client
+	keys         *keySet
+
+	mu                 sync.Mutex
+	discovery          *Discovery
+	discoveryFetchedAt time.Time
+}
+
+func New(params Params) *Provider {
+	if params.GroupsClaim == "" {
+		params.GroupsClaim = defaultGroupsKey
+	}
+	if params.LoginTTL <= 0 {
+		params.LoginTTL = defaultLoginTTL
+	}
+	if params.HTTPClient == nil {
+		params.HTTPClient = &http.Client{Timeout: httpTimeout}
+	}
+
+	provider := &Provider{
+		issuerURL:    params.IssuerURL,
+		clientID:     params.ClientID,
+		clientSecret: params.ClientSecret,
+		redirectURL:  params.RedirectURL,
+		scopes:       params.Scopes,
+		groupsClaim:  params.GroupsClaim,
+		loginTTL:     params.LoginTTL,
+		logins:       params.Logins,
+		client:       params.HTTPClient,
+	}
+	provider.keys = newKeySet(provider.fetchKeys)
+
+	return provider
+}
+
+// LoginTTL returns the time the user has for completing a login.
+func (p *Provider) LoginTTL() time.Duration {
+	return p.loginTTL
+}
+
+// Begin starts a login and returns the authorization URL the user has to be redirected to together
+// with the state which the callback has to present.
+func (p *Provider) Begin(ctx context.Context) (string, string, error) {
+	discovery, err := p.Discovery(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	login := PendingLogin{
+		State:     common.RandomHex(16),
+		Nonce:     common.RandomHex(16),
+		Verifier:  common.RandomHex(32),
+		ExpiresAt: time.Now().Add(p.loginTTL),
+	}
+	if err = p.logins.Save(login); err != nil {
+		return "", "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientID},
+		"redirect_uri":          {p.redirectURL},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {login.State},
+		"nonce":                 {login.Nonce},
+		"code_challenge":        {codeChallenge(login.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), login.State, nil
+}
+
+// Complete redeems the authorization code of the login with the state and returns the identity of
+// the validated ID token.
+func (p *Provider) Complete(ctx context.Context, state, code string) (*Identity, error) {
+	login, err := p.logins.Take(state, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, err := p.exchange(ctx, code, login.Verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.VerifyIDToken(ctx, rawIDToken, login.Nonce)
+}
+
+// Discovery returns the provider metadata. It is cached and fetched again after an hour.
+func (p *Provider) Discovery(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil && time.Since(p.discoveryFetchedAt) < discoveryTTL {
+		return p.discovery, nil
+	}
+
+	var discovery Discovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.issuerURL, "/")+discoveryPath, &discovery); err != nil {
+		return nil, err
+	}
+
+	// the issuer has to match exactly, otherwise another provider could be impersonated
+	if discovery.Issuer != p.issuerURL {
+		return nil, fmt.Errorf("%w - discovery document is for issuer %q", ErrProviderFailed, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%w - discovery document is missing endpoints", ErrProviderFailed)
+	}
+
+	p.discovery = &discovery
+	p.discoveryFetchedAt = time.Now()
+
+	return p.discovery, nil
+}
+
+// VerifyIDToken validates the signature and the claims of the ID token against the provider keys,
+// the client ID and the nonce of the login.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Identity, error) {
+	discovery, err := p.Discovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &jwt.Parser{
+		ValidMethods: []string{jwt.SigningMethodRS256.Alg()},
+		// the time based claims are validated with leeway below
+		SkipClaimsValidation: true,
+	}
+	token, err := parser.Parse(rawIDToken, func(token *jwt.Token) (interface{}, error) {
+		keyID, _ := token.Header["kid"].(string)
+		return p.keys.key(ctx, keyID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w - %s", ErrInvalidIDToken, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if ok == false {
+		return nil, ErrInvalidIDToken
+	}
+
+	now := time.Now()
+	if claims.VerifyExpiresAt(now.Add(-idTokenLeeway).Unix(), true) == false {
+		return nil, fmt.Errorf("%w - token is expired", ErrInvalidIDToken)
+	}
+	if claims.VerifyIssuedAt(now.Add(idTokenLeeway).Unix(), true) == false {
+		return nil, fmt.Errorf("%w - token is issued in the future", ErrInvalidIDToken)
+	}
+	if claims.VerifyIssuer(discovery.Issuer, true) == false {
+		return nil, fmt.Errorf("%w - unexpected issuer", ErrInvalidIDToken)
+	}
+	if p.verifyAudience(claims) == false {
+		return nil, fmt.Errorf("%w - unexpected audience", ErrInvalidIDToken)
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, fmt.Errorf("%w - unexpected nonce", ErrInvalidIDToken)
+	}
+
+	identity := &Identity{Issuer: discovery.Issuer}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.Groups = stringList(claims[p.groupsClaim])
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w - missing subject", ErrInvalidIDToken)
+	}
+
+	return identity, nil
+}
+
+// verifyAudience requires the client to be an audience of the token and, for tokens with several
+// audiences, to be the authorized party.
+func (p *Provider) verifyAudience(claims jwt.MapClaims) bool {
+	audiences := stringList(claims["aud"])
+	found := false
+	for _, audience := range audiences {
+		if audience == p.clientID {
+			found = true
+		}
+	}
+
+	if found == false {
+		return false
+	}
+
+	if len(audiences) > 1 {
+		azp, _ := claims["azp"].(string)
+		return azp == p.clientID
+	}
+
+	return true
+}
+
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+func (p *Provider) exchange(ctx context.Context, code, verifier string) (string, error) {
+	discovery, err := p.Discovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"client_id":     {p.clientID},
+		"code_verifier": {verifier},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.clientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	var response tokenResponse
+	if err = p.doJSON(request, &response); err != nil {
+		return "", err
+	}
+
+	if response.IDToken == "" {
+		return "", fmt.Errorf("%w - token response has no ID token", ErrProviderFailed)
+	}
+
+	return response.IDToken, nil
+}
+
+func (p *Provider) fetchKeys(ctx context.Context) (*jsonWebKeySet, error) {
+	discovery, err := p.Discovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var set jsonWebKeySet
+	if err = p.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	return &set, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	return p.doJSON(request, v)
+}
+
+func (p *Provider) doJSON(request *http.Request, v interface{}) error {
+	request.Header.Set("Accept", "application/json")
+	response, err := p.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("%w - %s", ErrProviderFailed, err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("%w - %s", ErrProviderFailed, err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w - %s %s returned %d: %s", ErrProviderFailed, request.Method, request.URL, response.StatusCode, body)
+	}
+
+	if err = jsoniter.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w - invalid JSON: %s", ErrProviderFailed, err)
+	}
+
+	return nil
+}
+
+// stringList reads a claim which may either be a single string or a list of strings.
+func stringList(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var result []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+
+	return nil
+}
@@ -0,0 +1,346 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	jsoniter "github.com/json-iterator/go"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID     = "lynkly"
+	testClientSecret = "client-secret"
+	testRedirectURL  = "https://lynkly.test/api/v1/auth/oidc/callback"
+)
+
+// authorization is an authorization code issued by the test identity provider
+type authorization struct {
+	nonce         string
+	codeChallenge string
+	claims        jwt.MapClaims
+}
+
+// testIdP is an identity provider implementing the discovery, token and JWKS endpoints.
+// Authorizations are registered by the tests instead of through the authorization endpoint.
+type testIdP struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu       sync.Mutex
+	key      *rsa.PrivateKey
+	keyID    string
+	issuer   string
+	codes    map[string]authorization
+	jwksHits int
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	idp := &testIdP{t: t, codes: make(map[string]authorization)}
+	idp.rotateKey("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, idp.discovery)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/jwks", idp.jwks)
+	idp.server = httptest.NewServer(mux)
+	idp.issuer = idp.server.URL
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *testIdP) rotateKey(keyID string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.key = key
+	idp.keyID = keyID
+}
+
+// authorize registers an authorization code as if the user logged in through the authorization URL.
+func (idp *testIdP) authorize(authorizationURL string, claims jwt.MapClaims) (string, string) {
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != testClientID || query.Get("redirect_uri") != testRedirectURL {
+		idp.t.Fatalf("Unexpected authorization request %s", authorizationURL)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	code := "code-" + query.Get("state")
+	idp.codes[code] = authorization{
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		claims:        claims,
+	}
+
+	return query.Get("state"), code
+}
+
+func (idp *testIdP) discovery(w http.ResponseWriter, _ *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	writeJSON(w, Discovery{
+		Issuer:                idp.issuer,
+		AuthorizationEndpoint: idp.server.URL + "/authorize",
+		TokenEndpoint:         idp.server.URL + "/token",
+		JWKSURI:               idp.server.URL + "/jwks",
+	})
+}
+
+func (idp *testIdP) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	if r.PostFormValue("grant_type") != "authorization_code" || clientID != testClientID || clientSecret != testClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	idp.mu.Lock()
+	authorization, ok := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	if ok == false || codeChallenge(r.PostFormValue("code_verifier")) != authorization.codeChallenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	claims := jwt.MapClaims{"nonce": authorization.nonce}
+	for name, value := range authorization.claims {
+		claims[name] = value
+	}
+	writeJSON(w, tokenResponse{IDToken: idp.sign(claims)})
+}
+
+func (idp *testIdP) jwks(w http.ResponseWriter, _ *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	idp.jwksHits++
+	writeJSON(w, jsonWebKeySet{Keys: []jsonWebKey{{
+		KeyType: "RSA",
+		KeyID:   idp.keyID,
+		Use:     "sig",
+		N:       base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+		E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+	}}})
+}
+
+// sign returns an ID token with valid default claims, which are overridden by the given claims.
+func (idp *testIdP) sign(claims jwt.MapClaims) string {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.issuer,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"email":          "user@lynkly.test",
+		"email_verified": true,
+		"groups":         []string{"engineering", "admins"},
+	})
+	for name, value := range claims {
+		token.Claims.(jwt.MapClaims)[name] = value
+	}
+	token.Header["kid"] = idp.keyID
+
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+
+	return signed
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsoniter.NewEncoder(w).Encode(v)
+}
+
+func newTestProvider(idp *testIdP) *Provider {
+	return New(Params{
+		IssuerURL:    idp.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"openid", "email"},
+		Logins:       NewMemoryLoginStore(),
+	})
+}
+
+func TestLogin(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := newTestProvider(idp)
+	ctx := context.Background()
+
+	authorizationURL, state, err := provider.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callbackState, code := idp.authorize(authorizationURL, nil)
+	if callbackState != state {
+		t.Fatalf("Expected state %s in the authorization URL, got %s", state, callbackState)
+	}
+
+	identity, err := provider.Complete(ctx, state, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if identity.Issuer != idp.server.URL || identity.Subject != "user-1" || identity.Email != "user@lynkly.test" ||
+		identity.EmailVerified == false || len(identity.Groups) != 2 || identity.Groups[1] != "admins" {
+		t.Errorf("Unexpected identity %+v", identity)
+	}
+
+	// the state can be used once
+	if _, err = provider.Complete(ctx, state, code); err != ErrUnknownState {
+		t.Errorf("Expected ErrUnknownState, got %v", err)
+	}
+}
+
+func TestLoginRequiresTheCodeVerifier(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := newTestProvider(idp)
+	ctx := context.Background()
+
+	// the code is issued to another login, so it is redeemed with a verifier which does not match
+	authorizationURL, _, err := provider.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, code := idp.authorize(authorizationURL, nil)
+
+	_, state, err := provider.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = provider.Complete(ctx, state, code); errors.Is(err, ErrProviderFailed) == false {
+		t.Errorf("Expected ErrProviderFailed, got %v", err)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := newTestProvider(idp)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		nonce  string
+		valid  bool
+	}{
+		{"valid", jwt.MapClaims{"nonce": "nonce"}, "nonce", true},
+		{"wrong nonce", jwt.MapClaims{"nonce": "other"}, "nonce", false},
+		{"missing nonce", jwt.MapClaims{}, "nonce", false},
+		{"expired", jwt.MapClaims{"nonce": "nonce", "exp": now.Add(-time.Hour).Unix()}, "nonce", false},
+		{"issued in the future", jwt.MapClaims{"nonce": "nonce", "iat": now.Add(time.Hour).Unix()}, "nonce", false},
+		{"other issuer", jwt.MapClaims{"nonce": "nonce", "iss": "https://idp.test"}, "nonce", false},
+		{"other audience", jwt.MapClaims{"nonce": "nonce", "aud": "other-client"}, "nonce", false},
+		{"several audiences without azp", jwt.MapClaims{"nonce": "nonce", "aud": []string{testClientID, "other"}}, "nonce", false},
+		{"several audiences with azp", jwt.MapClaims{"nonce": "nonce", "aud": []string{testClientID, "other"}, "azp": testClientID}, "nonce", true},
+		{"missing subject", jwt.MapClaims{"nonce": "nonce", "sub": ""}, "nonce", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(context.Background(), idp.sign(test.claims), test.nonce)
+			if test.valid && err != nil {
+				t.Errorf("Expected a valid token, got %v", err)
+			} else if test.valid == false && errors.Is(err, ErrInvalidIDToken) == false {
+				t.Errorf("Expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsOtherAlgorithms(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := newTestProvider(idp)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"sub":   "user-1",
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": "nonce",
+	})
+	signed, err := token.SignedString([]byte(testClientSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = provider.VerifyIDToken(context.Background(), signed, "nonce"); errors.Is(err, ErrInvalidIDToken) == false {
+		t.Errorf("Expected ErrInvalidIDToken, got %v", err)
+	}
+}
+
+func TestVerifyIDTokenAfterKeyRotation(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := newTestProvider(idp)
+	ctx := context.Background()
+
+	if _, err := provider.VerifyIDToken(ctx, idp.sign(jwt.MapClaims{"nonce": "nonce"}), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+
+	idp.rotateKey("key-2")
+	rotated := idp.sign(jwt.MapClaims{"nonce": "nonce"})
+
+	// the key set is not fetched again right away, so that made up key IDs cannot flood the provider
+	if _, err := provider.VerifyIDToken(ctx, rotated, "nonce"); errors.Is(err, ErrInvalidIDToken) == false {
+		t.Errorf("Expected ErrInvalidIDToken, got %v", err)
+	}
+
+	provider.keys.mu.Lock()
+	provider.keys.fetchedAt = time.Now().Add(-2 * minKeyRefreshInterval)
+	provider.keys.mu.Unlock()
+
+	if _, err := provider.VerifyIDToken(ctx, rotated, "nonce"); err != nil {
+		t.Errorf("Expected the token signed with the new key to be valid, got %v", err)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	if idp.jwksHits != 2 {
+		t.Errorf("Expected the key set to be fetched twice, got %d", idp.jwksHits)
+	}
+}
+
+func TestDiscoveryRequiresTheConfiguredIssuer(t *testing.T) {
+	idp := newTestIdP(t)
+	idp.mu.Lock()
+	idp.issuer = "https://idp.test"
+	idp.mu.Unlock()
+
+	if _, _, err := newTestProvider(idp).Begin(context.Background()); errors.Is(err, ErrProviderFailed) == false {
+		t.Errorf("Expected ErrProviderFailed, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package origins
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Wildcard allows every origin when it is the whole pattern
+	Wildcard = "*"
+	// wildcardLabel replaces the wildcard while parsing a pattern
+	wildcardLabel = "wildcard"
+)
+
+var ErrInvalidPattern = errors.New(`invalid origin - expected e.g. "https://example.com" or "https://*.example.com"`)
+
+// Validate checks that the pattern is an http or https origin without a path, whose host may start with
+// a "*." wildcard for the subdomains of a domain. The bare wildcard is not accepted.
+func Validate(pattern string) error {
+	parsed, err := url.Parse(strings.Replace(pattern, "*.", wildcardLabel+".", 1))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" ||
+		parsed.User != nil || parsed.Path != "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return ErrInvalidPattern
+	}
+
+	host := strings.TrimPrefix(pattern, parsed.Scheme+"://")
+	if strings.Count(pattern, "*") > 1 || (strings.Contains(pattern, "*") && strings.HasPrefix(host, "*.") == false) {
+		return ErrInvalidPattern
+	}
+	// the wildcard must not cover a whole top-level domain
+	domain := strings.TrimPrefix(parsed.Hostname(), wildcardLabel+".")
+	if strings.HasPrefix(host, "*.") && strings.Contains(strings.Trim(domain, "."), ".") == false {
+		return ErrInvalidPattern
+	}
+
+	return nil
+}
+
+// Match reports whether the origin sent by a browser matches the pattern. A "*" in the pattern
+// matches a non-empty host name part, the whole pattern "*" matches every origin.
+func Match(pattern, origin string) bool {
+	pattern = strings.ToLower(pattern)
+	origin = strings.ToLower(origin)
+	if pattern == Wildcard {
+		return true
+	}
+
+	prefix, suffix, found := strings.Cut(pattern, "*")
+	if found == false {
+		return pattern == origin
+	}
+
+	if len(origin) <= len(prefix)+len(suffix) || strings.HasPrefix(origin, prefix) == false ||
+		strings.HasSuffix(origin, suffix) == false {
+		return false
+	}
+
+	return isHostName(origin[len(prefix) : len(origin)-len(suffix)])
+}
+
+// MatchAny reports whether the origin matches one of the patterns.
+func MatchAny(patterns []string, origin string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isHostName(value string) bool {
+	for _, c := range value {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '.' {
+			return false
+		}
+	}
+
+	return true
+}
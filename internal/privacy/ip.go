@@ -0,0 +1,85 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"sync"
+	"time"
+)
+
+// IPMode defines how client IPs are anonymized before click events are stored.
+type IPMode string
+
+const (
+	// IPModeTruncate zeroes the host part of the address, keeping a /24 for IPv4 and a /48 for IPv6.
+	IPModeTruncate IPMode = "truncate"
+	// IPModeHash replaces the address with a keyed hash. The salt rotates, so the same visitor can
+	// only be correlated within a single rotation period.
+	IPModeHash IPMode = "hash"
+	// IPModeDrop does not store the address at all.
+	IPModeDrop IPMode = "drop"
+)
+
+const (
+	saltSize   = 32
+	hashLength = 16
+)
+
+var (
+	ipv4Mask = net.CIDRMask(24, 32)
+	ipv6Mask = net.CIDRMask(48, 128)
+)
+
+func truncateIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	if ipv4 := parsed.To4(); ipv4 != nil {
+		return ipv4.Mask(ipv4Mask).String()
+	}
+
+	return parsed.Mask(ipv6Mask).String()
+}
+
+// rotatingSalt holds a random salt which is replaced once it gets older than the rotation period.
+// The salt is never persisted, so hashes cannot be reversed after a restart either.
+type rotatingSalt struct {
+	mu        sync.Mutex
+	rotation  time.Duration
+	salt      []byte
+	rotatedAt time.Time
+}
+
+func (s *rotatingSalt) hash(ip string, now time.Time) (string, error) {
+	salt, err := s.current(now)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))[:hashLength], nil
+}
+
+func (s *rotatingSalt) current(now time.Time) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.salt != nil && now.Sub(s.rotatedAt) < s.rotation {
+		return s.salt, nil
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	s.salt = salt
+	s.rotatedAt = now
+	return salt, nil
+}
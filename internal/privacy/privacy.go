@@ -0,0 +1,77 @@
+package privacy
+
+import (
+	"lynkly-backend/internal/analytics"
+	"net/http"
+	"time"
+)
+
+const (
+	doNotTrackHeader    = "DNT"
+	globalPrivacyHeader = "Sec-GPC"
+)
+
+type Params struct {
+	IPMode       IPMode
+	SaltRotation time.Duration
+	Settings     SettingsStore
+}
+
+// Policy strips or anonymizes the personal data of click events before they leave the redirect handler.
+type Policy struct {
+	ipMode   IPMode
+	salt     *rotatingSalt
+	settings SettingsStore
+}
+
+func New(params Params) *Policy {
+	ipMode := params.IPMode
+	if ipMode != IPModeHash && ipMode != IPModeDrop {
+		ipMode = IPModeTruncate
+	}
+
+	return &Policy{
+		ipMode:   ipMode,
+		salt:     &rotatingSalt{rotation: params.SaltRotation},
+		settings: params.Settings,
+	}
+}
+
+func (p *Policy) Settings() SettingsStore {
+	return p.settings
+}
+
+// Apply returns the click as it may be stored. Only the count is kept when the workspace disabled
+// detailed analytics or the visitor opted out of tracking, otherwise the client IP is anonymized.
+func (p *Policy) Apply(click analytics.Click, workspaceID string, r *http.Request) (analytics.Click, error) {
+	settings, err := p.settings.Get(workspaceID)
+	if err != nil {
+		return analytics.Click{}, err
+	}
+
+	if settings.AnalyticsMode == AnalyticsAggregate || OptedOut(r) {
+		return analytics.Click{
+			Code:      click.Code,
+			Timestamp: click.Timestamp,
+		}, nil
+	}
+
+	switch p.ipMode {
+	case IPModeHash:
+		click.IP, err = p.salt.hash(click.IP, click.Timestamp)
+		if err != nil {
+			return analytics.Click{}, err
+		}
+	case IPModeDrop:
+		click.IP = ""
+	default:
+		click.IP = truncateIP(click.IP)
+	}
+
+	return click, nil
+}
+
+// OptedOut reports whether the visitor asked not to be tracked through the DNT or Sec-GPC headers.
+func OptedOut(r *http.Request) bool {
+	return r.Header.Get(doNotTrackHeader) == "1" || r.Header.Get(globalPrivacyHeader) == "1"
+}
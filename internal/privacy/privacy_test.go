@@ -0,0 +1,146 @@
+package privacy
+
+import (
+	"lynkly-backend/internal/analytics"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testIPs = []string{"203.0.113.77", "::ffff:203.0.113.77", "2001:db8:1234:5678:9abc:def0:1234:5678"}
+
+func newTestClick(ip string) analytics.Click {
+	return analytics.Click{
+		Code:      "abc",
+		Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		IP:        ip,
+		UserAgent: "Mozilla/5.0",
+		Referrer:  "https://example.com/",
+	}
+}
+
+func TestTruncateIP(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.77":                           "203.0.113.0",
+		"::ffff:203.0.113.77":                    "203.0.113.0",
+		"2001:db8:1234:5678:9abc:def0:1234:5678": "2001:db8:1234::",
+		"fe80::1%eth0":                           "",
+		"203.0.113.77:443":                       "",
+		"203.0.113.77, 198.51.100.1":             "",
+		"":                                       "",
+	}
+
+	for ip, expected := range tests {
+		if truncated := truncateIP(ip); truncated != expected {
+			t.Errorf("%q: expected %q, got %q", ip, expected, truncated)
+		}
+	}
+}
+
+func TestApplyNeverStoresTheFullIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/abc", nil)
+
+	for _, mode := range []IPMode{IPModeTruncate, IPModeHash, IPModeDrop, "unknown"} {
+		policy := New(Params{IPMode: mode, SaltRotation: time.Hour, Settings: NewMemorySettingsStore()})
+
+		for _, ip := range testIPs {
+			click, err := policy.Apply(newTestClick(ip), "workspace", r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if click.IP == ip || strings.HasSuffix(click.IP, ".77") || strings.HasSuffix(click.IP, ":5678") {
+				t.Errorf("%s: expected %s to be anonymized, got %q", mode, ip, click.IP)
+			}
+			if mode == IPModeDrop && click.IP != "" {
+				t.Errorf("Expected the IP to be dropped, got %q", click.IP)
+			}
+			if mode == IPModeHash && len(click.IP) != hashLength {
+				t.Errorf("Expected a hash of %d characters, got %q", hashLength, click.IP)
+			}
+			if click.UserAgent == "" || click.Referrer == "" {
+				t.Errorf("%s: expected the details of the click to be kept, got %+v", mode, click)
+			}
+		}
+	}
+}
+
+func TestSaltRotation(t *testing.T) {
+	policy := New(Params{IPMode: IPModeHash, SaltRotation: time.Hour, Settings: NewMemorySettingsStore()})
+	r := httptest.NewRequest("GET", "/abc", nil)
+
+	apply := func(ip string, at time.Time) string {
+		click := newTestClick(ip)
+		click.Timestamp = at
+		click, err := policy.Apply(click, "workspace", r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return click.IP
+	}
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	first := apply(testIPs[0], start)
+
+	if hash := apply(testIPs[0], start.Add(59*time.Minute)); hash != first {
+		t.Errorf("Expected the same hash within the rotation period, got %s and %s", first, hash)
+	}
+	if hash := apply(testIPs[2], start.Add(59*time.Minute)); hash == first {
+		t.Errorf("Expected other IPs to hash differently, got %s", hash)
+	}
+	if hash := apply(testIPs[0], start.Add(time.Hour)); hash == first {
+		t.Errorf("Expected a new hash after the salt rotated, got %s", hash)
+	}
+
+	// the salt is not shared, e.g. between restarts
+	other := New(Params{IPMode: IPModeHash, SaltRotation: time.Hour, Settings: NewMemorySettingsStore()})
+	click, err := other.Apply(newTestClick(testIPs[0]), "workspace", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if click.IP == first {
+		t.Errorf("Expected another salt, got the same hash %s", click.IP)
+	}
+}
+
+func TestApplyHonorsOptOuts(t *testing.T) {
+	settings := NewMemorySettingsStore()
+	if err := settings.Save(Settings{WorkspaceID: "aggregate", AnalyticsMode: AnalyticsAggregate}); err != nil {
+		t.Fatal(err)
+	}
+	policy := New(Params{IPMode: IPModeHash, SaltRotation: time.Hour, Settings: settings})
+
+	tests := []struct {
+		name        string
+		workspaceID string
+		headers     map[string]string
+		detailed    bool
+	}{
+		{"detailed", "workspace", nil, true},
+		{"do not track", "workspace", map[string]string{"DNT": "1"}, false},
+		{"global privacy control", "workspace", map[string]string{"Sec-GPC": "1"}, false},
+		{"tracking allowed", "workspace", map[string]string{"DNT": "0"}, true},
+		{"aggregate workspace", "aggregate", nil, false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/abc", nil)
+		for name, value := range test.headers {
+			r.Header.Set(name, value)
+		}
+
+		click, err := policy.Apply(newTestClick(testIPs[0]), test.workspaceID, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stripped := analytics.Click{Code: "abc", Timestamp: newTestClick("").Timestamp}
+		if test.detailed == false && click != stripped {
+			t.Errorf("%s: expected only the count to be kept, got %+v", test.name, click)
+		} else if test.detailed && (click.IP == "" || click.UserAgent == "") {
+			t.Errorf("%s: expected the details of the click, got %+v", test.name, click)
+		}
+	}
+}
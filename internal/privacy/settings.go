@@ -0,0 +1,69 @@
+package privacy
+
+import (
+	"sync"
+)
+
+// AnalyticsMode defines how much detail is recorded for the clicks of a workspace.
+type AnalyticsMode string
+
+const (
+	// AnalyticsDetailed records the (anonymized) IP, user agent and referrer of every click, unless
+	// the visitor opted out through DNT or Sec-GPC.
+	AnalyticsDetailed AnalyticsMode = "detailed"
+	// AnalyticsAggregate records only click counts.
+	AnalyticsAggregate AnalyticsMode = "aggregate"
+)
+
+func (m AnalyticsMode) IsValid() bool {
+	return m == AnalyticsDetailed || m == AnalyticsAggregate
+}
+
+// Settings holds the privacy settings of a workspace.
+type Settings struct {
+	WorkspaceID   string        `json:"workspaceId"`
+	AnalyticsMode AnalyticsMode `json:"analyticsMode"`
+}
+
+func DefaultSettings(workspaceID string) Settings {
+	return Settings{
+		WorkspaceID:   workspaceID,
+		AnalyticsMode: AnalyticsDetailed,
+	}
+}
+
+type SettingsStore interface {
+	// Get returns the settings of the workspace, or the default settings when none were saved.
+	Get(workspaceID string) (Settings, error)
+	Save(settings Settings) error
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memorySettingsStore struct {
+	mu       sync.RWMutex
+	settings map[string]Settings
+}
+
+func NewMemorySettingsStore() SettingsStore {
+	return &memorySettingsStore{settings: make(map[string]Settings)}
+}
+
+func (s *memorySettingsStore) Get(workspaceID string) (Settings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings, ok := s.settings[workspaceID]
+	if ok == false {
+		return DefaultSettings(workspaceID), nil
+	}
+
+	return settings, nil
+}
+
+func (s *memorySettingsStore) Save(settings Settings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.settings[settings.WorkspaceID] = settings
+	return nil
+}
@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"fmt"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/models/lhttp"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Policies applied to the routes
+const (
+	PolicyAPI      = "api"
+	PolicyRedirect = "redirect"
+	// PolicyAuthFailures limits the failed authentications of each IP address
+	PolicyAuthFailures = "auth_failures"
+)
+
+// quota headers of the IETF RateLimit header fields draft
+const (
+	LimitHeader     = "RateLimit-Limit"
+	RemainingHeader = "RateLimit-Remaining"
+	ResetHeader     = "RateLimit-Reset"
+	PolicyHeader    = "RateLimit-Policy"
+)
+
+// Policy allows Requests per Period to each client. The quota refills continuously, so a client may
+// burst up to Requests at once and then continue at the average rate.
+type Policy struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (p Policy) IsEnabled() bool {
+	return p.Requests > 0 && p.Period > 0
+}
+
+// rate returns the number of requests refilled per second
+func (p Policy) rate() float64 {
+	return float64(p.Requests) / p.Period.Seconds()
+}
+
+// Quota is the state of the bucket of a client after taking a request from it.
+type Quota struct {
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the quota is refilled completely
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed. It is zero when Allowed.
+	RetryAfter time.Duration
+}
+
+type Params struct {
+	Store    Store
+	Policies map[string]Policy
+}
+
+// Limiter limits the requests of the clients with token buckets. Authenticated requests are counted
+// against their API key or user and anonymous requests against their IP address. Failed
+// authentications are counted against the IP address under PolicyAuthFailures.
+type Limiter struct {
+	store    Store
+	policies map[string]Policy
+}
+
+func New(params Params) *Limiter {
+	return &Limiter{
+		store:    params.Store,
+		policies: params.Policies,
+	}
+}
+
+// Limit counts the request against the quota of its client under the policy and returns the quota
+// headers. The response is 429 Too Many Requests when the quota is exhausted. Requests under unknown or
+// disabled policies are not limited.
+func (l *Limiter) Limit(r *http.Request, policyName string) (map[string]string, *lhttp.HttpResponse) {
+	policy, ok := l.policies[policyName]
+	if ok == false || policy.IsEnabled() == false {
+		return nil, nil
+	}
+
+	quota := l.store.Take(policyName+":"+clientKey(r), policy, time.Now())
+	headers := quotaHeaders(policy, quota)
+	if quota.Allowed {
+		return headers, nil
+	}
+
+	return headers, tooManyRequests(headers, quota)
+}
+
+// LimitFailures rejects the request with 429 Too Many Requests before it is authenticated when its IP
+// address exhausted the quota of failed authentications. The request itself is not counted.
+func (l *Limiter) LimitFailures(r *http.Request) *lhttp.HttpResponse {
+	policy, ok := l.policies[PolicyAuthFailures]
+	if ok == false || policy.IsEnabled() == false {
+		return nil
+	}
+
+	quota := l.store.Peek(PolicyAuthFailures+":"+ipKey(r), policy, time.Now())
+	if quota.Allowed {
+		return nil
+	}
+
+	return tooManyRequests(quotaHeaders(policy, quota), quota)
+}
+
+// CountFailure counts a failed authentication against the IP address of the request.
+func (l *Limiter) CountFailure(r *http.Request) {
+	policy, ok := l.policies[PolicyAuthFailures]
+	if ok == false || policy.IsEnabled() == false {
+		return
+	}
+
+	l.store.Take(PolicyAuthFailures+":"+ipKey(r), policy, time.Now())
+}
+
+func quotaHeaders(policy Policy, quota Quota) map[string]string {
+	return map[string]string{
+		LimitHeader:     strconv.Itoa(policy.Requests),
+		RemainingHeader: strconv.Itoa(quota.Remaining),
+		ResetHeader:     strconv.Itoa(ceilSeconds(quota.Reset)),
+		PolicyHeader:    fmt.Sprintf("%d;w=%d", policy.Requests, ceilSeconds(policy.Period)),
+	}
+}
+
+func tooManyRequests(headers map[string]string, quota Quota) *lhttp.HttpResponse {
+	retryAfter := ceilSeconds(quota.RetryAfter)
+	headers[lhttp.RetryAfterHeader] = strconv.Itoa(retryAfter)
+	return lhttp.TooManyRequests().
+		FromTrustedMessage(fmt.Sprintf("Too many requests - retry in %d seconds", retryAfter)).
+		WithHeaders(headers)
+}
+
+// clientKey identifies the client of the request
+func clientKey(r *http.Request) string {
+	principal := auth.PrincipalFrom(r)
+	if principal != nil && principal.APIKeyID != "" {
+		return "key:" + principal.APIKeyID
+	} else if principal != nil && principal.UserID != "" {
+		return "user:" + principal.UserID
+	}
+
+	return ipKey(r)
+}
+
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,187 @@
+package ratelimit
+
+import (
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestRequest(principal *auth.Principal) *http.Request {
+	r := httptest.NewRequest("GET", "/api/v1/links", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	if principal != nil {
+		r = common.ContextSet(r, common.ContextPair{Key: logging.UserKey, Value: principal})
+	}
+
+	return r
+}
+
+func TestBucketRefill(t *testing.T) {
+	store := NewMemoryStore()
+	policy := Policy{Requests: 2, Period: 10 * time.Second}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		at        time.Duration
+		allowed   bool
+		remaining int
+	}{
+		{"first", 0, true, 1},
+		{"burst", 0, true, 0},
+		{"exhausted", 0, false, 0},
+		// a token is refilled every 5 seconds
+		{"partially refilled", 4 * time.Second, false, 0},
+		{"refilled", 5 * time.Second, true, 0},
+		{"completely refilled", time.Minute, true, 1},
+	}
+
+	for _, test := range tests {
+		quota := store.Take("key", policy, now.Add(test.at))
+		if quota.Allowed != test.allowed || quota.Remaining != test.remaining {
+			t.Errorf("%s: expected allowed %v with %d remaining, got %+v", test.name, test.allowed, test.remaining, quota)
+		}
+		if quota.Allowed && quota.RetryAfter != 0 {
+			t.Errorf("%s: expected no retry after an allowed request, got %v", test.name, quota.RetryAfter)
+		}
+	}
+
+	if quota := store.Take("key", policy, now.Add(time.Minute)); quota.Allowed == false || quota.Remaining != 0 || quota.Reset != 10*time.Second {
+		t.Errorf("Expected the last token to be taken, got %+v", quota)
+	}
+	if quota := store.Take("key", policy, now.Add(time.Minute+2*time.Second)); quota.Allowed || ceilSeconds(quota.RetryAfter) != 3 {
+		t.Errorf("Expected a retry after 3 seconds, got %+v", quota)
+	}
+
+	// other keys and changed policies have their own buckets
+	if quota := store.Take("other", policy, now.Add(time.Minute)); quota.Allowed == false || quota.Remaining != 1 {
+		t.Errorf("Expected a new bucket for another key, got %+v", quota)
+	}
+	if quota := store.Take("key", Policy{Requests: 5, Period: time.Minute}, now.Add(time.Minute)); quota.Allowed == false || quota.Remaining != 4 {
+		t.Errorf("Expected a new bucket for another policy, got %+v", quota)
+	}
+}
+
+func TestPeekDoesNotTake(t *testing.T) {
+	store := NewMemoryStore()
+	policy := Policy{Requests: 1, Period: time.Minute}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if quota := store.Peek("key", policy, now); quota.Allowed == false || quota.Remaining != 1 {
+			t.Errorf("Expected peeking to keep the token, got %+v", quota)
+		}
+	}
+
+	store.Take("key", policy, now)
+	if quota := store.Peek("key", policy, now); quota.Allowed || quota.RetryAfter != time.Minute {
+		t.Errorf("Expected the quota to be exhausted, got %+v", quota)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		expected  string
+	}{
+		{"API key", &auth.Principal{UserID: "user", APIKeyID: "key", Method: auth.MethodAPIKey}, "key:key"},
+		{"user", &auth.Principal{UserID: "user", Method: auth.MethodJWT}, "user:user"},
+		{"no subject", &auth.Principal{Method: auth.MethodJWT}, "ip:203.0.113.7"},
+		{"anonymous", nil, "ip:203.0.113.7"},
+	}
+
+	for _, test := range tests {
+		if key := clientKey(newTestRequest(test.principal)); key != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, key)
+		}
+	}
+
+	r := newTestRequest(nil)
+	r.RemoteAddr = "203.0.113.7"
+	if key := clientKey(r); key != "ip:203.0.113.7" {
+		t.Errorf("Expected the address without a port, got %s", key)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	limiter := New(Params{
+		Store: NewMemoryStore(),
+		Policies: map[string]Policy{
+			PolicyAPI:      {Requests: 2, Period: time.Minute},
+			PolicyRedirect: {},
+		},
+	})
+	user := &auth.Principal{UserID: "user", Method: auth.MethodJWT}
+
+	headers, resp := limiter.Limit(newTestRequest(user), PolicyAPI)
+	if resp != nil {
+		t.Fatalf("Expected the request to be allowed, got %d", resp.StatusCode())
+	}
+	expected := map[string]string{LimitHeader: "2", RemainingHeader: "1", ResetHeader: "30", PolicyHeader: "2;w=60"}
+	for name, value := range expected {
+		if headers[name] != value {
+			t.Errorf("Expected %s: %s, got %q", name, value, headers[name])
+		}
+	}
+	if _, ok := headers[lhttp.RetryAfterHeader]; ok {
+		t.Errorf("Expected no %s header on an allowed request", lhttp.RetryAfterHeader)
+	}
+
+	// anonymous requests from the IP address of the user have their own quota
+	if _, resp = limiter.Limit(newTestRequest(nil), PolicyAPI); resp != nil {
+		t.Errorf("Expected the anonymous request to be allowed, got %d", resp.StatusCode())
+	}
+
+	limiter.Limit(newTestRequest(user), PolicyAPI)
+	headers, resp = limiter.Limit(newTestRequest(user), PolicyAPI)
+	if resp == nil || resp.StatusCode() != http.StatusTooManyRequests {
+		t.Fatalf("Expected %d, got %+v", http.StatusTooManyRequests, resp)
+	}
+	if headers[lhttp.RetryAfterHeader] != "30" || headers[RemainingHeader] != "0" {
+		t.Errorf("Expected to retry after 30 seconds, got %v", headers)
+	}
+
+	for _, policy := range []string{PolicyRedirect, "unknown"} {
+		if headers, resp = limiter.Limit(newTestRequest(user), policy); headers != nil || resp != nil {
+			t.Errorf("%s: expected the request not to be limited, got %v", policy, headers)
+		}
+	}
+}
+
+func TestLimitFailures(t *testing.T) {
+	limiter := New(Params{
+		Store:    NewMemoryStore(),
+		Policies: map[string]Policy{PolicyAuthFailures: {Requests: 2, Period: time.Minute}},
+	})
+
+	for i := 0; i < 2; i++ {
+		if resp := limiter.LimitFailures(newTestRequest(nil)); resp != nil {
+			t.Fatalf("Expected failure %d to be allowed, got %d", i+1, resp.StatusCode())
+		}
+		limiter.CountFailure(newTestRequest(nil))
+	}
+
+	// the failures count against the IP address, whatever the credentials
+	resp := limiter.LimitFailures(newTestRequest(&auth.Principal{UserID: "user", APIKeyID: "key"}))
+	if resp == nil || resp.StatusCode() != http.StatusTooManyRequests {
+		t.Fatalf("Expected %d, got %+v", http.StatusTooManyRequests, resp)
+	}
+
+	other := newTestRequest(nil)
+	other.RemoteAddr = "198.51.100.1:1234"
+	if resp = limiter.LimitFailures(other); resp != nil {
+		t.Errorf("Expected another IP address not to be limited, got %d", resp.StatusCode())
+	}
+
+	disabled := New(Params{Store: NewMemoryStore()})
+	disabled.CountFailure(newTestRequest(nil))
+	if resp = disabled.LimitFailures(newTestRequest(nil)); resp != nil {
+		t.Errorf("Expected no limit without the policy, got %d", resp.StatusCode())
+	}
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the idle buckets are removed from the memory store
+const sweepInterval = time.Minute
+
+// Store holds the token buckets of the clients.
+type Store interface {
+	// Take refills the bucket of the key according to the policy and takes a token from it if one is
+	// available.
+	Take(key string, policy Policy, now time.Time) Quota
+	// Peek returns the quota of the key as Take would, without taking a token.
+	Peek(key string, policy Policy, now time.Time) Quota
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	policy    Policy
+}
+
+// refill adds the tokens accumulated since the last update, up to the size of the bucket
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updatedAt).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.policy.Requests), b.tokens+elapsed*b.policy.rate())
+		b.updatedAt = now
+	}
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *memoryStore) Take(key string, policy Policy, now time.Time) Quota {
+	return s.quota(key, policy, now, true)
+}
+
+func (s *memoryStore) Peek(key string, policy Policy, now time.Time) Quota {
+	return s.quota(key, policy, now, false)
+}
+
+func (s *memoryStore) quota(key string, policy Policy, now time.Time, take bool) Quota {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if ok == false || b.policy != policy {
+		b = &bucket{tokens: float64(policy.Requests), updatedAt: now, policy: policy}
+		if take == false {
+			// peeking at a new bucket does not store it
+			return b.quota(b.tokens >= 1)
+		}
+		s.buckets[key] = b
+	}
+	b.refill(now)
+
+	allowed := b.tokens >= 1
+	if allowed && take {
+		b.tokens--
+	}
+
+	return b.quota(allowed)
+}
+
+// quota returns the state of the bucket after a request was allowed or rejected
+func (b *bucket) quota(allowed bool) Quota {
+	quota := Quota{Allowed: allowed}
+	if allowed == false {
+		quota.RetryAfter = secondsToDuration((1 - b.tokens) / b.policy.rate())
+	}
+
+	quota.Remaining = int(b.tokens)
+	quota.Reset = secondsToDuration((float64(b.policy.Requests) - b.tokens) / b.policy.rate())
+	return quota
+}
+
+// sweep removes the buckets which refilled completely, they are the same as new buckets
+func (s *memoryStore) sweep(now time.Time) {
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.policy.Requests) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -8,12 +8,22 @@ import (
 
 type RouterParams struct {
 	Logger logging.Logger
+	// Authenticator validates the credentials of the routes registered with RequireAuth.
+	Authenticator Authenticator
+	// Tenants resolves the workspace of the authenticated requests. Workspaces are not resolved when nil.
+	Tenants TenantResolver
+	// Auditor records the mutating requests. Requests are not audited when nil.
+	Auditor Auditor
+	// RateLimiter limits the routes registered with RateLimit. Requests are not limited when nil.
+	RateLimiter RateLimiter
 }
 
 const (
 	PathAPIV1 = "/api/v1"
 	PathAPIV2 = "/api/v2"
 	PathAPIV3 = "/api/v3"
+
+	PathWellKnown = "/.well-known"
 )
 
 type RouteVersions struct {
@@ -26,4 +36,74 @@ type MiddleWareOptions struct {
 	Value interface{}
 }
 
+const (
+	// OptionAuthentication makes the route reject requests which the Authenticator of the router
+	// cannot authenticate. The value is not used.
+	OptionAuthentication OptionType = iota
+	// OptionScope makes the route require an authenticated identity holding the scope given as
+	// the string value. It implies OptionAuthentication.
+	OptionScope
+	// OptionOptionalAuthentication authenticates the requests carrying credentials and lets anonymous
+	// requests through. Requests with invalid credentials are rejected. The value is not used.
+	OptionOptionalAuthentication
+	// OptionRateLimit limits the requests of each client under the policy given as the string value.
+	// The option of a route replaces the option of its router.
+	OptionRateLimit
+)
+
+// RequireAuth is the option protecting a route with the Authenticator of the router.
+func RequireAuth() MiddleWareOptions {
+	return MiddleWareOptions{Key: OptionAuthentication}
+}
+
+// AllowAuth is the option identifying the caller of a public route when it sends credentials.
+func AllowAuth() MiddleWareOptions {
+	return MiddleWareOptions{Key: OptionOptionalAuthentication}
+}
+
+// RequireScope is the option protecting a route with the Authenticator of the router and
+// requiring the authenticated identity to hold the scope.
+func RequireScope(scope string) MiddleWareOptions {
+	return MiddleWareOptions{Key: OptionScope, Value: scope}
+}
+
+// RateLimit is the option limiting the requests of each client to the quota of the policy.
+func RateLimit(policy string) MiddleWareOptions {
+	return MiddleWareOptions{Key: OptionRateLimit, Value: policy}
+}
+
+// Authenticator validates the credentials of a request. On success, it returns the request
+// carrying the authenticated identity in its context.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*http.Request, error)
+	// HasCredentials reports whether the request carries credentials, valid or not.
+	HasCredentials(r *http.Request) bool
+	// HasScope reports whether the identity authenticated on the request holds the scope.
+	HasScope(r *http.Request, scope string) bool
+}
+
+// TenantResolver resolves the workspace of an authenticated request. It returns the request carrying
+// the workspace in its context and, on failure, the error response for the client. The request
+// returned with an error response only serves to log the requested workspace.
+type TenantResolver interface {
+	ResolveTenant(r *http.Request) (*http.Request, *lhttp.HttpResponse)
+}
+
+// Auditor records the requests changing data. Track prepares the request before it is handled, so
+// that the handler can describe its change, and Record is called with the response.
+type Auditor interface {
+	Track(r *http.Request) *http.Request
+	Record(r *http.Request, resp *lhttp.HttpResponse)
+}
+
+// RateLimiter counts a request against the quota of its client under a policy. It returns the quota
+// headers of the response and, when the quota is exhausted, the error response for the client.
+// LimitFailures is checked before a request is authenticated and returns the error response when its
+// IP address failed to authenticate too often, as counted by CountFailure.
+type RateLimiter interface {
+	Limit(r *http.Request, policy string) (map[string]string, *lhttp.HttpResponse)
+	LimitFailures(r *http.Request) *lhttp.HttpResponse
+	CountFailure(r *http.Request)
+}
+
 type RouteHandlerFunc func(r *http.Request) *lhttp.HttpResponse
@@ -3,6 +3,7 @@ package routers
 import (
 	"github.com/gorilla/mux"
 	"github.com/urfave/negroni"
+	"lynkly-backend/internal/common"
 	"lynkly-backend/internal/logging"
 	"lynkly-backend/internal/models/lhttp"
 	"net/http"
@@ -10,35 +11,213 @@ import (
 )
 
 type Router struct {
-	router *mux.Router
-	logger logging.Logger
-	// TODO: [Zdravko Donev] Add jwt middleware
-	//jwt     *jwtmiddleware.JWTMiddleware
+	router        *mux.Router
+	logger        logging.Logger
+	authenticator Authenticator
+	tenants       TenantResolver
+	auditor       Auditor
+	rateLimiter   RateLimiter
+	// options are applied to every route of the router
 	options []MiddleWareOptions
 }
 
 func NewRouter(router *mux.Router, routerParams *RouterParams, options ...MiddleWareOptions) *Router {
 	return &Router{
-		router:  router,
-		logger:  routerParams.Logger,
-		options: options,
+		router:        router,
+		logger:        routerParams.Logger,
+		authenticator: routerParams.Authenticator,
+		tenants:       routerParams.Tenants,
+		auditor:       routerParams.Auditor,
+		rateLimiter:   routerParams.RateLimiter,
+		options:       options,
 	}
 }
 
-func (tr *Router) HandleFunc(method, url string, handler RouteHandlerFunc) {
+// HandleFunc registers the handler for the method and url. The options are applied to this route
+// in addition to the options of the router.
+func (tr *Router) HandleFunc(method, url string, handler RouteHandlerFunc, options ...MiddleWareOptions) {
 	tr.logger.Debug("Router.HandleFunc - Registering handler: ", method, " ", url)
-	tr.handle(method, url, negroni.New(
-		negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-			tr.logger.Debug("Router.HandleFunc: ", r.URL.Path)
+	tr.handle(method, url, tr.middlewares("Router.HandleFunc: ", tr.Wrap(handler), options))
+}
+
+// HandleStream registers a handler which writes to the http.ResponseWriter by itself instead of
+// returning a lhttp.HttpResponse. It is meant for long-lived responses such as Server-Sent Events.
+func (tr *Router) HandleStream(method, url string, handler http.HandlerFunc, options ...MiddleWareOptions) {
+	tr.logger.Debug("Router.HandleStream - Registering handler: ", method, " ", url)
+	tr.handle(method, url, tr.middlewares("Router.HandleStream: ", negroni.Wrap(handler), options))
+}
+
+func (tr *Router) middlewares(debugPrefix string, handler negroni.Handler, options []MiddleWareOptions) *negroni.Negroni {
+	n := negroni.New(negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		tr.logger.Debug(debugPrefix, r.URL.Path)
+		next(w, r)
+	}))
+
+	scopes := tr.scopes(options)
+	if tr.hasOption(OptionAuthentication, options) || len(scopes) > 0 {
+		n.Use(tr.authentication(false))
+	} else if tr.hasOption(OptionOptionalAuthentication, options) {
+		n.Use(tr.authentication(true))
+	}
+	// the requests are limited once authenticated, so that they count against their API key or user
+	if policy := tr.rateLimitPolicy(options); policy != "" && tr.rateLimiter != nil {
+		n.Use(tr.rateLimit(policy))
+	}
+	if len(scopes) > 0 {
+		n.Use(tr.authorization(scopes))
+	}
+
+	n.Use(handler)
+	return n
+}
+
+func (tr *Router) hasOption(key OptionType, routeOptions []MiddleWareOptions) bool {
+	for _, options := range [][]MiddleWareOptions{tr.options, routeOptions} {
+		for _, option := range options {
+			if option.Key == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (tr *Router) scopes(routeOptions []MiddleWareOptions) []string {
+	var scopes []string
+	for _, options := range [][]MiddleWareOptions{tr.options, routeOptions} {
+		for _, option := range options {
+			if option.Key == OptionScope {
+				scopes = append(scopes, option.Value.(string))
+			}
+		}
+	}
+
+	return scopes
+}
+
+// rateLimitPolicy returns the policy of the route, or the policy of the router when the route has none.
+func (tr *Router) rateLimitPolicy(routeOptions []MiddleWareOptions) string {
+	var policy string
+	for _, options := range [][]MiddleWareOptions{tr.options, routeOptions} {
+		for _, option := range options {
+			if option.Key == OptionRateLimit {
+				policy = option.Value.(string)
+			}
+		}
+	}
+
+	return policy
+}
+
+// rateLimit adds the quota headers to the responses and rejects the requests over the quota of the
+// policy with 429 Too Many Requests.
+func (tr *Router) rateLimit(policy string) negroni.Handler {
+	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		headers, resp := tr.rateLimiter.Limit(r, policy)
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+
+		if resp != nil {
+			if err := lhttp.Write(w, r, resp); err != nil {
+				tr.logger.WithRequest(r).Error(err.Error())
+			}
+			tr.logResponse(r, resp)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// authorization rejects the authenticated requests missing any of the scopes with 403 Forbidden.
+func (tr *Router) authorization(scopes []string) negroni.Handler {
+	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		for _, scope := range scopes {
+			if tr.authenticator.HasScope(r, scope) == false {
+				resp := lhttp.Forbidden().FromTrustedMessage("Missing scope - " + scope)
+				if err := lhttp.Write(w, r, resp); err != nil {
+					tr.logger.WithRequest(r).Error(err.Error())
+				}
+				tr.logger.WithRequest(r).Warn("Authorization failed: missing scope ", scope)
+				return
+			}
+		}
+
+		next(w, r)
+	})
+}
+
+// authentication rejects the requests which cannot be authenticated with 401 Unauthorized and
+// passes the authenticated request down the chain otherwise. Clients failing to authenticate too
+// often are rejected with 429 Too Many Requests. The workspace of the authenticated
+// request is resolved as well. When optional, requests without any credentials are passed down the
+// chain as they are.
+func (tr *Router) authentication(optional bool) negroni.Handler {
+	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if optional && (tr.authenticator == nil || tr.authenticator.HasCredentials(r) == false) {
 			next(w, r)
-		}),
-		tr.Wrap(handler)),
-	)
+			return
+		}
+
+		if tr.authenticator == nil {
+			tr.logger.WithRequest(r).Error("Route requires authentication but the router has no authenticator")
+			_ = lhttp.Write(w, r, lhttp.Unauthorized().FromTrustedMessage("Authentication is not available"))
+			return
+		}
+
+		// the failures are limited by IP address before authenticating, so that credentials cannot be
+		// guessed faster than the quota
+		if tr.rateLimiter != nil {
+			if resp := tr.rateLimiter.LimitFailures(r); resp != nil {
+				if err := lhttp.Write(w, r, resp); err != nil {
+					tr.logger.WithRequest(r).Error(err.Error())
+				}
+				tr.logResponse(r, resp)
+				return
+			}
+		}
+
+		authenticatedRequest, err := tr.authenticator.Authenticate(r)
+		if err != nil {
+			if tr.rateLimiter != nil && tr.authenticator.HasCredentials(r) {
+				tr.rateLimiter.CountFailure(r)
+			}
+			resp := lhttp.Unauthorized().
+				FromTrustedMessage("Missing or invalid credentials").
+				WithHeaders(map[string]string{"WWW-Authenticate": "Bearer"})
+			_ = lhttp.Write(w, r, resp)
+			tr.logger.WithRequest(r).Warn("Authentication failed: ", err)
+			return
+		}
+
+		if tr.tenants != nil {
+			tenantRequest, resp := tr.tenants.ResolveTenant(authenticatedRequest)
+			if resp != nil {
+				if tenantRequest != nil {
+					authenticatedRequest = tenantRequest
+				}
+				if err = lhttp.Write(w, authenticatedRequest, resp); err != nil {
+					tr.logger.WithRequest(authenticatedRequest).Error(err.Error())
+				}
+				tr.logResponse(authenticatedRequest, resp)
+				return
+			}
+			authenticatedRequest = tenantRequest
+		}
+
+		next(w, authenticatedRequest)
+	})
 }
 
 func (tr *Router) Wrap(routeHandler RouteHandlerFunc) negroni.Handler {
 	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, _ http.HandlerFunc) {
 		newRequest := r.WithContext(r.Context())
+		audited := tr.auditor != nil && isMutating(r.Method)
+		if audited {
+			newRequest = tr.auditor.Track(newRequest)
+		}
 
 		resp := routeHandler(newRequest)
 
@@ -48,12 +227,20 @@ func (tr *Router) Wrap(routeHandler RouteHandlerFunc) negroni.Handler {
 				Error(err.Error())
 		}
 
+		if audited {
+			tr.auditor.Record(newRequest, resp)
+		}
+
 		if resp.IsSuccessful() == false {
 			tr.logResponse(newRequest, resp)
 		}
 	})
 }
 
+func isMutating(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
+
 func (tr *Router) logResponse(r *http.Request, resp *lhttp.HttpResponse) {
 	if resp.Payload() == nil {
 		return
@@ -78,6 +265,9 @@ func (tr *Router) logResponse(r *http.Request, resp *lhttp.HttpResponse) {
 func (tr *Router) handle(method, url string, handler http.Handler) {
 	tr.router.Handle(url, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tr.logger.Debug("brej", r.URL.Path)
+		requestID := common.NewID()
+		w.Header().Set(lhttp.RequestIDHeader, requestID)
+		r = common.ContextSet(r, common.ContextPair{Key: logging.RequestIDKey, Value: requestID})
 		defer func() {
 			if rec := recover(); rec != nil {
 				tr.logger.Panic("panic in router handler", string(debug.Stack()))
@@ -0,0 +1,86 @@
+package servers
+
+import (
+	"github.com/gorilla/mux"
+	"lynkly-backend/internal/apikeys"
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http"
+)
+
+const maxAPIKeyNameLength = 100
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type createAPIKeyResponse struct {
+	*apikeys.APIKey
+	// Key is the plaintext key. It is only returned on creation.
+	Key string `json:"key"`
+}
+
+func (s *UrlShortenerServer) CreateAPIKeyHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.CreateAPIKeyHandler")
+	var request createAPIKeyRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	if request.Name == "" || len(request.Name) > maxAPIKeyNameLength {
+		return lhttp.BadRequest().FromTrustedMessage("Invalid name - expected up to 100 characters")
+	}
+
+	if len(request.Scopes) == 0 {
+		return lhttp.BadRequest().FromTrustedMessage("At least one scope is required")
+	}
+
+	for _, scope := range request.Scopes {
+		if auth.IsAPIKeyScope(scope) == false {
+			return lhttp.BadRequest().FromTrustedMessage("Invalid scope - " + scope)
+		}
+	}
+
+	principal := auth.PrincipalFrom(r)
+	key, plaintext, err := s.apiKeys.Create(principal.UserID, principal.WorkspaceID, request.Name, request.Scopes)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to create API key: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to create API key")
+	}
+
+	audit.Describe(r, audit.ActionAPIKeyCreate, audit.Target{Type: "api_key", ID: key.ID})
+	audit.RecordChange(r, nil, key)
+
+	return lhttp.Created().WithJSON(createAPIKeyResponse{
+		APIKey: key,
+		Key:    plaintext,
+	})
+}
+
+func (s *UrlShortenerServer) ListAPIKeysHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ListAPIKeysHandler")
+	keys, err := s.apiKeys.Store().ByWorkspace(auth.PrincipalFrom(r).WorkspaceID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load API keys: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load API keys")
+	}
+
+	return lhttp.OK().WithJSON(emptyIfNil(keys))
+}
+
+func (s *UrlShortenerServer) RevokeAPIKeyHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.RevokeAPIKeyHandler")
+	key, err := s.apiKeys.Revoke(auth.PrincipalFrom(r).WorkspaceID, mux.Vars(r)["keyID"])
+	if err == apikeys.ErrNotFound {
+		return lhttp.NotFound().FromTrustedMessage("API key not found")
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to revoke API key: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to revoke API key")
+	}
+
+	audit.Describe(r, audit.ActionAPIKeyRevoke, audit.Target{Type: "api_key", ID: key.ID})
+
+	return lhttp.NoContent()
+}
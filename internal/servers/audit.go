@@ -0,0 +1,135 @@
+package servers
+
+import (
+	jsoniter "github.com/json-iterator/go"
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+	// auditExportBatchSize is the number of entries loaded at once while exporting
+	auditExportBatchSize = 1000
+	contentNDJSON        = "application/x-ndjson"
+)
+
+type auditPageResponse struct {
+	Entries []*audit.Entry `json:"entries"`
+	// NextCursor is passed as the cursor parameter to load the next page. It is empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// ListAuditEntriesHandler returns a page of the audit log of the current workspace, oldest first.
+// The entries are filtered by the optional actorId, action, targetType, targetId, from and to query
+// parameters.
+func (s *UrlShortenerServer) ListAuditEntriesHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ListAuditEntriesHandler")
+	query, resp := parseAuditQuery(r)
+	if resp != nil {
+		return resp
+	}
+
+	query.Limit = defaultAuditPageSize
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditPageSize {
+			return lhttp.BadRequest().FromTrustedMessage("Invalid limit - expected between 1 and 500")
+		}
+		query.Limit = limit
+	}
+
+	entries, err := s.audit.Store().List(query)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load audit entries: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load audit entries")
+	}
+
+	page := auditPageResponse{Entries: emptyIfNil(entries)}
+	if len(entries) == query.Limit {
+		page.NextCursor = strconv.FormatInt(entries[len(entries)-1].Sequence, 10)
+	}
+
+	return lhttp.OK().WithJSON(page)
+}
+
+// ExportAuditEntriesHandler streams the audit log of the current workspace as newline delimited JSON.
+// It accepts the filters of ListAuditEntriesHandler.
+func (s *UrlShortenerServer) ExportAuditEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("UrlShortenerServer.ExportAuditEntriesHandler")
+	query, resp := parseAuditQuery(r)
+	if resp != nil {
+		s.writeResponse(w, r, resp)
+		return
+	}
+
+	query.Limit = auditExportBatchSize
+	entries, err := s.audit.Store().List(query)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load audit entries: ", err)
+		s.writeResponse(w, r, lhttp.InternalServerError().FromTrustedMessage("Failed to export audit entries"))
+		return
+	}
+
+	w.Header().Set(lhttp.ContentTypeHeader, contentNDJSON)
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.ndjson"`)
+	w.WriteHeader(http.StatusOK)
+
+	encoder := jsoniter.NewEncoder(w)
+	for len(entries) > 0 {
+		for _, entry := range entries {
+			if err = encoder.Encode(entry); err != nil {
+				s.logger.WithRequest(r).Warn("Audit export interrupted: ", err)
+				return
+			}
+		}
+
+		if len(entries) < query.Limit {
+			return
+		}
+
+		query.AfterSequence = entries[len(entries)-1].Sequence
+		if entries, err = s.audit.Store().List(query); err != nil {
+			// the status is already sent, so the client only sees a truncated export
+			s.logger.WithRequest(r).Error("Failed to load audit entries: ", err)
+			return
+		}
+	}
+}
+
+func parseAuditQuery(r *http.Request) (audit.Query, *lhttp.HttpResponse) {
+	values := r.URL.Query()
+	workspaceID := auth.PrincipalFrom(r).WorkspaceID
+	// an empty workspace would match the entries of every workspace
+	if workspaceID == "" {
+		return audit.Query{}, lhttp.Forbidden().FromTrustedMessage("The audit log requires a workspace")
+	}
+
+	query := audit.Query{
+		WorkspaceID: workspaceID,
+		ActorID:     values.Get("actorId"),
+		Action:      values.Get("action"),
+		TargetType:  values.Get("targetType"),
+		TargetID:    values.Get("targetId"),
+	}
+
+	var err error
+	if query.From, err = parseTimeParam(r, "from", time.Time{}); err != nil {
+		return query, lhttp.BadRequest().FromTrustedError(err)
+	}
+	if query.To, err = parseTimeParam(r, "to", time.Time{}); err != nil {
+		return query, lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		if query.AfterSequence, err = strconv.ParseInt(cursor, 10, 64); err != nil || query.AfterSequence < 0 {
+			return query, lhttp.BadRequest().FromTrustedMessage("Invalid cursor")
+		}
+	}
+
+	return query, nil
+}
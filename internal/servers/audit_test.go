@@ -0,0 +1,75 @@
+package servers
+
+import (
+	"bufio"
+	jsoniter "github.com/json-iterator/go"
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExportAuditEntries(t *testing.T) {
+	s := &UrlShortenerServer{
+		logger: logging.NewLogger("servers_test"),
+		audit:  audit.New(audit.Params{Logger: logging.NewLogger("servers_test"), Store: audit.NewMemoryStore()}),
+	}
+
+	// the export spans several batches and skips the entries of other workspaces
+	total := auditExportBatchSize*2 + 1
+	for i := 0; i < total; i++ {
+		for _, workspaceID := range []string{"workspace", "other"} {
+			if err := s.audit.Append(&audit.Entry{ActorID: "user", WorkspaceID: workspaceID, Action: audit.ActionLinkUpdate}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/api/v1/audit/export", nil)
+	r = common.ContextSet(r, common.ContextPair{Key: logging.UserKey, Value: &auth.Principal{UserID: "user", WorkspaceID: "workspace"}})
+	w := httptest.NewRecorder()
+	s.ExportAuditEntriesHandler(w, r)
+
+	if w.Code != http.StatusOK || w.Header().Get(lhttp.ContentTypeHeader) != contentNDJSON {
+		t.Fatalf("Expected an NDJSON export, got %d %s", w.Code, w.Header().Get(lhttp.ContentTypeHeader))
+	}
+
+	exported := 0
+	var sequence int64
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var entry audit.Entry
+		if err := jsoniter.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Expected a JSON entry per line, got %q: %v", scanner.Text(), err)
+		}
+		if entry.WorkspaceID != "workspace" || entry.Sequence <= sequence || entry.Hash == "" {
+			t.Errorf("Unexpected entry after sequence %d: %+v", sequence, entry)
+		}
+		sequence = entry.Sequence
+		exported++
+	}
+
+	if exported != total {
+		t.Errorf("Expected %d entries, got %d", total, exported)
+	}
+}
+
+func TestExportAuditEntriesRequiresWorkspace(t *testing.T) {
+	s := &UrlShortenerServer{
+		logger: logging.NewLogger("servers_test"),
+		audit:  audit.New(audit.Params{Logger: logging.NewLogger("servers_test"), Store: audit.NewMemoryStore()}),
+	}
+
+	r := httptest.NewRequest("GET", "/api/v1/audit/export", nil)
+	r = common.ContextSet(r, common.ContextPair{Key: logging.UserKey, Value: &auth.Principal{UserID: "user"}})
+	w := httptest.NewRecorder()
+	s.ExportAuditEntriesHandler(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected %d without a workspace, got %d", http.StatusForbidden, w.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package servers
+
+import (
+	"errors"
+	"lynkly-backend/internal/analytics"
+	"lynkly-backend/internal/challenges"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http"
+)
+
+// ChallengeHandler issues the challenge which anonymous clients solve before creating a link. The
+// solution is sent in the X-Challenge-Response header of the request creating the link.
+func (s *UrlShortenerServer) ChallengeHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ChallengeHandler")
+	if s.challenges == nil {
+		return lhttp.NotFound().FromTrustedMessage("Anonymous link creation is not challenged")
+	}
+
+	challenge, err := s.challenges.Challenge()
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to issue challenge: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to issue challenge")
+	}
+
+	return lhttp.OK().WithJSON(challenge).WithHeaders(map[string]string{"Cache-Control": "no-store"})
+}
+
+// checkChallenge verifies the response of an anonymous client to its challenge.
+func (s *UrlShortenerServer) checkChallenge(r *http.Request) *lhttp.HttpResponse {
+	if s.challenges == nil {
+		return nil
+	}
+
+	err := s.challenges.Verify(r.Context(), r.Header.Get(challenges.ResponseHeader), analytics.ClientIP(r))
+	if err == challenges.ErrMissingResponse || err == challenges.ErrInvalidResponse ||
+		err == challenges.ErrExpired || err == challenges.ErrAlreadyUsed {
+		return lhttp.Forbidden().FromTrustedError(err)
+	} else if errors.Is(err, challenges.ErrUnavailable) {
+		s.logger.WithRequest(r).Warn("Failed to verify challenge: ", err)
+		return lhttp.Unavailable().FromTrustedError(challenges.ErrUnavailable)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to verify challenge: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to verify challenge")
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package servers
+
+import (
+	"encoding/base64"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/conversions"
+	"lynkly-backend/internal/links"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// transparentGIF is a 1x1 transparent GIF returned by the conversion pixel
+var transparentGIF, _ = base64.StdEncoding.DecodeString("R0lGODlhAQABAIAAAAAAAP///yH5BAEAAAAALAAAAAABAAEAAAIBRAA7")
+
+type createConversionRequest struct {
+	ClickID  string  `json:"clickId"`
+	Event    string  `json:"event"`
+	Value    float64 `json:"value"`
+	Currency string  `json:"currency"`
+}
+
+// CreateConversionHandler attributes a conversion to the click identified by the click ID which
+// was appended to the destination URL on redirect.
+func (s *UrlShortenerServer) CreateConversionHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.CreateConversionHandler")
+	var request createConversionRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	conversion, resp := s.recordConversion(r, request)
+	if resp != nil {
+		return resp
+	}
+
+	return lhttp.Created().WithJSON(conversion)
+}
+
+// ConversionPixelHandler records a conversion from the "cid", "event", "value" and "currency" query
+// parameters and responds with a 1x1 transparent GIF, so conversions can be tracked with an image tag.
+func (s *UrlShortenerServer) ConversionPixelHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ConversionPixelHandler")
+	query := r.URL.Query()
+	request := createConversionRequest{
+		ClickID:  query.Get("cid"),
+		Event:    query.Get("event"),
+		Currency: query.Get("currency"),
+	}
+
+	if value := query.Get("value"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return lhttp.BadRequest().FromTrustedMessage("Invalid value parameter")
+		}
+		request.Value = parsed
+	}
+
+	// a repeated pixel load is not an error for the page embedding it
+	if _, resp := s.recordConversion(r, request); resp != nil && resp.StatusCode() != http.StatusConflict {
+		return resp
+	}
+
+	return lhttp.OK().
+		WithBinary(lhttp.ContentImageGIF, transparentGIF).
+		WithHeaders(map[string]string{"Cache-Control": "no-store"})
+}
+
+func (s *UrlShortenerServer) recordConversion(r *http.Request, request createConversionRequest) (*conversions.Conversion, *lhttp.HttpResponse) {
+	if err := conversions.ValidateEvent(request.Event); err != nil {
+		return nil, lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	now := time.Now().UTC()
+	click, err := s.clickIDs.Parse(request.ClickID, now)
+	if err != nil {
+		return nil, lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	link, err := s.links.Get(click.Code)
+	if err == links.ErrNotFound {
+		return nil, lhttp.NotFound().FromTrustedMessage("Short URL of the click no longer exists")
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load link: ", err)
+		return nil, lhttp.InternalServerError().FromTrustedMessage("Failed to record conversion")
+	}
+
+	conversion := &conversions.Conversion{
+		ID:          common.NewID(),
+		ClickID:     click.ID,
+		Code:        link.Code,
+		WorkspaceID: link.WorkspaceID,
+		Event:       request.Event,
+		Value:       request.Value,
+		Currency:    request.Currency,
+		ClickedAt:   click.ClickedAt,
+		CreatedAt:   now,
+	}
+	if err = s.conversions.Add(conversion); err == conversions.ErrDuplicate {
+		return nil, lhttp.Conflict().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to store conversion: ", err)
+		return nil, lhttp.InternalServerError().FromTrustedMessage("Failed to record conversion")
+	}
+
+	return conversion, nil
+}
+
+// appendQueryParam returns the URL with the query parameter added after the existing ones, which are
+// kept as they are. The URL is returned unchanged when it cannot be parsed.
+func appendQueryParam(rawURL, name, value string) string {
+	parsedUrl, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	param := url.QueryEscape(name) + "=" + url.QueryEscape(value)
+	if parsedUrl.RawQuery == "" {
+		parsedUrl.RawQuery = param
+	} else {
+		parsedUrl.RawQuery += "&" + param
+	}
+
+	return parsedUrl.String()
+}
@@ -0,0 +1,116 @@
+package servers
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/domains"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http"
+)
+
+type createDomainRequest struct {
+	Name string `json:"name"`
+}
+
+// domainResponse tells the workspace which TXT record proves that it controls the domain
+type domainResponse struct {
+	*domains.Domain
+	VerificationRecord string `json:"verificationRecord"`
+}
+
+func newDomainResponse(domain *domains.Domain) domainResponse {
+	return domainResponse{
+		Domain:             domain,
+		VerificationRecord: domain.VerificationRecord(),
+	}
+}
+
+// CreateDomainHandler adds a custom domain to the workspace. The domain is served, and gets a
+// certificate, once the verification token is published in its verification record.
+func (s *UrlShortenerServer) CreateDomainHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.CreateDomainHandler")
+
+	var request createDomainRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	domain, err := s.domains.Add(mux.Vars(r)["workspaceID"], request.Name)
+	if err == domains.ErrInvalidDomain || err == domains.ErrOwnDomain || err == domains.ErrTooManyDomains {
+		return lhttp.BadRequest().FromTrustedError(err)
+	} else if err == domains.ErrAlreadyExists {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to add domain: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to add domain")
+	}
+
+	audit.Describe(r, audit.ActionDomainCreate, audit.Target{Type: "domain", ID: domain.Name, WorkspaceID: domain.WorkspaceID})
+
+	return lhttp.Created().WithJSON(newDomainResponse(domain))
+}
+
+func (s *UrlShortenerServer) ListDomainsHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ListDomainsHandler")
+
+	workspaceDomains, err := s.domains.Store().Domains(mux.Vars(r)["workspaceID"])
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load domains: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load domains")
+	}
+
+	response := make([]domainResponse, 0, len(workspaceDomains))
+	for _, domain := range workspaceDomains {
+		response = append(response, newDomainResponse(domain))
+	}
+
+	return lhttp.OK().WithJSON(response)
+}
+
+// VerifyDomainHandler looks up the verification record of the domain.
+func (s *UrlShortenerServer) VerifyDomainHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.VerifyDomainHandler")
+
+	vars := mux.Vars(r)
+	domain, err := s.domains.Verify(r.Context(), vars["workspaceID"], vars["domain"])
+	if err == domains.ErrNotFound {
+		return lhttp.NotFound().FromTrustedMessage("Domain not found")
+	} else if err == domains.ErrRecordNotFound {
+		return lhttp.BadRequest().FromTrustedError(err)
+	} else if errors.Is(err, domains.ErrLookupFailed) {
+		s.logger.WithRequest(r).Warn("Failed to look up the verification record: ", err)
+		return lhttp.Unavailable().FromTrustedError(domains.ErrLookupFailed)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to verify domain: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to verify domain")
+	}
+
+	audit.Describe(r, audit.ActionDomainVerify, audit.Target{Type: "domain", ID: domain.Name, WorkspaceID: domain.WorkspaceID})
+
+	return lhttp.OK().WithJSON(newDomainResponse(domain))
+}
+
+// DeleteDomainHandler removes the domain and its cached certificates.
+func (s *UrlShortenerServer) DeleteDomainHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.DeleteDomainHandler")
+
+	vars := mux.Vars(r)
+	domain, err := s.domains.Remove(vars["workspaceID"], vars["domain"])
+	if err == domains.ErrNotFound {
+		return lhttp.NotFound().FromTrustedMessage("Domain not found")
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to delete domain: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to delete domain")
+	}
+
+	if s.acme != nil {
+		if err = s.acme.Forget(r.Context(), domain.Name); err != nil {
+			s.logger.WithRequest(r).Error("Failed to delete the certificates of the domain: ", err)
+		}
+	}
+
+	audit.Describe(r, audit.ActionDomainDelete, audit.Target{Type: "domain", ID: domain.Name, WorkspaceID: domain.WorkspaceID})
+
+	return lhttp.NoContent()
+}
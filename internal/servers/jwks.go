@@ -0,0 +1,19 @@
+package servers
+
+import (
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http"
+)
+
+// JWKSHandler publishes the public keys verifying the access tokens, so that other services can verify
+// them. The key set is empty when the tokens are signed with an HMAC secret.
+func (s *UrlShortenerServer) JWKSHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.JWKSHandler")
+	keySet := auth.JSONWebKeySet{Keys: []auth.JSONWebKey{}}
+	if s.signingKeys != nil {
+		keySet = s.signingKeys.JWKS()
+	}
+
+	return lhttp.OK().Etag().WithJSON(keySet)
+}
@@ -0,0 +1,256 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/links"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/webhooks"
+	"lynkly-backend/internal/workspaces"
+	"net/http"
+	"time"
+)
+
+var ErrExpiryInPast = errors.New("expiresAt must be in the future")
+
+// errLinkChanged stops a background job from changing a link which changed since the job loaded it.
+var errLinkChanged = errors.New("link changed since it was loaded")
+
+type linkAction int
+
+const (
+	linkRead linkAction = iota
+	linkWrite
+)
+
+type updateLinkRequest struct {
+	URL *string `json:"url"`
+	// ExpiresAt is an RFC 3339 time. An empty string removes the expiry of the link.
+	ExpiresAt *string `json:"expiresAt"`
+}
+
+func (s *UrlShortenerServer) GetLinkHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.GetLinkHandler")
+
+	link, resp := s.loadLink(r, linkRead)
+	if resp != nil {
+		return resp
+	}
+
+	return lhttp.OK().WithJSON(link)
+}
+
+func (s *UrlShortenerServer) UpdateLinkHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.UpdateLinkHandler")
+
+	var request updateLinkRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	link, resp := s.loadLink(r, linkWrite)
+	if resp != nil {
+		return resp
+	}
+
+	destination := link.URL
+	if request.URL != nil {
+		destination, resp = s.checkDestination(r, *request.URL)
+		if resp != nil {
+			return resp
+		}
+	}
+
+	var expiresAt *time.Time
+	if request.ExpiresAt != nil {
+		var err error
+		if expiresAt, err = parseExpiresAt(*request.ExpiresAt); err != nil {
+			return lhttp.BadRequest().FromTrustedError(err)
+		}
+	}
+
+	// only the requested fields are changed, so that e.g. a concurrent disable of the link is kept
+	var before links.Link
+	link, err := s.links.Modify(link.Code, func(link *links.Link) error {
+		before = *link
+		if request.URL != nil {
+			link.URL = destination
+		}
+		if request.ExpiresAt != nil {
+			link.ExpiresAt = expiresAt
+			link.ExpiryNotified = false
+		}
+		link.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+	if err == links.ErrNotFound {
+		return lhttp.NotFound().FromTrustedMessage(fmt.Sprintf("Short URL not found - %s", mux.Vars(r)["code"]))
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to update link: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to update short URL")
+	}
+
+	audit.Describe(r, audit.ActionLinkUpdate, audit.Target{Type: "link", ID: link.Code, WorkspaceID: link.WorkspaceID})
+	audit.RecordChange(r, &before, link)
+	s.dispatchWebhook(r, link.WorkspaceID, webhooks.LinkUpdated, link)
+
+	return lhttp.OK().WithJSON(link)
+}
+
+func (s *UrlShortenerServer) DeleteLinkHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.DeleteLinkHandler")
+
+	link, resp := s.loadLink(r, linkWrite)
+	if resp != nil {
+		return resp
+	}
+
+	if err := s.links.Delete(link.Code); err != nil {
+		s.logger.WithRequest(r).Error("Failed to delete link: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to delete short URL")
+	}
+
+	audit.Describe(r, audit.ActionLinkDelete, audit.Target{Type: "link", ID: link.Code, WorkspaceID: link.WorkspaceID})
+	audit.RecordChange(r, link, nil)
+	s.dispatchWebhook(r, link.WorkspaceID, webhooks.LinkDeleted, link)
+
+	return lhttp.NoContent()
+}
+
+// loadLink returns the link addressed by the "code" route variable, or the error response to be
+// returned when it cannot be loaded or the caller is not allowed to perform the action on it.
+func (s *UrlShortenerServer) loadLink(r *http.Request, action linkAction) (*links.Link, *lhttp.HttpResponse) {
+	code := mux.Vars(r)["code"]
+	notFound := lhttp.NotFound().FromTrustedMessage(fmt.Sprintf("Short URL not found - %s", code))
+
+	link, err := s.links.Get(code)
+	if err == links.ErrNotFound {
+		return nil, notFound
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load link: ", err)
+		return nil, lhttp.InternalServerError().FromTrustedMessage("Failed to load short URL")
+	}
+
+	// links the caller cannot read are reported as missing, so that their existence is not leaked
+	if s.canAccessLink(r, link, linkRead) == false {
+		return nil, notFound
+	}
+
+	if action == linkWrite && s.canAccessLink(r, link, linkWrite) == false {
+		return nil, lhttp.Forbidden().FromTrustedMessage(fmt.Sprintf("Not allowed to change short URL - %s", code))
+	}
+
+	return link, nil
+}
+
+// canAccessLink reports whether the caller may perform the action on the link. Every member of the
+// workspace of the link may read it. Changing it requires the current role to be editor or higher,
+// even for the creator of the link. API keys only access the links of their own workspace.
+func (s *UrlShortenerServer) canAccessLink(r *http.Request, link *links.Link, action linkAction) bool {
+	principal := auth.PrincipalFrom(r)
+	if principal == nil || principal.UserID == "" {
+		return false
+	}
+
+	if principal.Method == auth.MethodAPIKey && principal.WorkspaceID != link.WorkspaceID {
+		return false
+	}
+
+	member, err := s.workspaces.Store().Member(link.WorkspaceID, principal.UserID)
+	if err != nil {
+		if err != workspaces.ErrMemberNotFound {
+			s.logger.WithRequest(r).Error("Failed to load member: ", err)
+		}
+		return false
+	}
+
+	if action == linkRead {
+		return true
+	}
+
+	return member.Role == auth.RoleEditor || member.Role.Outranks(auth.RoleEditor)
+}
+
+// runLinkExpiry announces the links which expired since the last check until the context is cancelled.
+func (s *UrlShortenerServer) runLinkExpiry(ctx context.Context) {
+	ticker := time.NewTicker(s.linksConfig.ExpiryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired, err := s.links.ExpiredUnnotified(now)
+			if err != nil {
+				s.logger.Error("Failed to load expired links: ", err)
+				continue
+			}
+
+			for _, link := range expired {
+				if err = s.webhooks.Dispatch(link.WorkspaceID, webhooks.LinkExpired, link); err != nil {
+					s.logger.Error("Failed to dispatch webhook: ", err)
+					continue
+				}
+
+				// the link is only marked when its expiry was not changed since it was announced
+				announced := link.ExpiresAt
+				_, err = s.links.Modify(link.Code, func(link *links.Link) error {
+					if link.ExpiresAt == nil || link.ExpiresAt.Equal(*announced) == false {
+						return errLinkChanged
+					}
+					link.ExpiryNotified = true
+					return nil
+				})
+				if err != nil && err != links.ErrNotFound && err != errLinkChanged {
+					s.logger.Error("Failed to update link: ", err)
+				}
+			}
+		}
+	}
+}
+
+// checkDestination validates the destination of a link and returns the URL to be stored, which is the
+// final destination when the URL of another shortener was resolved.
+func (s *UrlShortenerServer) checkDestination(r *http.Request, destination string) (string, *lhttp.HttpResponse) {
+	if err := s.destinations.Check(destination); err != nil {
+		return "", lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	resolved, err := s.shorteners.Resolve(r.Context(), destination)
+	if err != nil {
+		return "", lhttp.BadRequest().FromTrustedError(err)
+	}
+	if resolved != destination {
+		s.logger.WithRequest(r).Info("Resolved ", destination, " to ", resolved)
+	}
+
+	if resp := s.screenDestination(r, resolved); resp != nil {
+		return "", resp
+	}
+
+	return resolved, nil
+}
+
+// parseExpiresAt parses an optional RFC 3339 expiry time, returning nil when value is empty.
+func parseExpiresAt(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New("invalid expiresAt - expected an RFC 3339 time")
+	}
+
+	expiresAt = expiresAt.UTC()
+	if expiresAt.After(time.Now()) == false {
+		return nil, ErrExpiryInPast
+	}
+
+	return &expiresAt, nil
+}
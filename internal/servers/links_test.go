@@ -0,0 +1,100 @@
+package servers
+
+import (
+	"github.com/gorilla/mux"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/links"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/workspaces"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestLinkServer(t *testing.T) *UrlShortenerServer {
+	t.Helper()
+
+	s := &UrlShortenerServer{
+		logger:     logging.NewLogger("servers_test"),
+		links:      links.NewMemoryStore(),
+		workspaces: workspaces.New(workspaces.Params{Store: workspaces.NewMemoryStore()}),
+	}
+
+	if _, err := s.workspaces.CreateWithID("workspace", "Workspace", "owner"); err != nil {
+		t.Fatal(err)
+	}
+	for userID, role := range map[string]auth.Role{"editor": auth.RoleEditor, "viewer": auth.RoleViewer, "creator": auth.RoleViewer} {
+		if _, err := s.workspaces.SetRole("workspace", userID, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.workspaces.CreateWithID("other", "Other", "outsider"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the creator of the link was downgraded to a viewer after creating it
+	link := &links.Link{Code: "abc", URL: "https://example.com/", WorkspaceID: "workspace", CreatedBy: "creator", CreatedAt: time.Now()}
+	if err := s.links.Create(link); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func newTestLinkRequest(principal *auth.Principal) *http.Request {
+	r := httptest.NewRequest("GET", "/api/v1/links/abc", nil)
+	r = mux.SetURLVars(r, map[string]string{"code": "abc"})
+	if principal != nil {
+		r = common.ContextSet(r, common.ContextPair{Key: logging.UserKey, Value: principal})
+	}
+
+	return r
+}
+
+func TestLoadLinkOwnership(t *testing.T) {
+	s := newTestLinkServer(t)
+
+	user := func(userID string) *auth.Principal {
+		return &auth.Principal{UserID: userID, Method: auth.MethodJWT}
+	}
+	apiKey := func(userID, workspaceID string) *auth.Principal {
+		return &auth.Principal{UserID: userID, Method: auth.MethodAPIKey, WorkspaceID: workspaceID}
+	}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		read      int
+		write     int
+	}{
+		{"owner", user("owner"), http.StatusOK, http.StatusOK},
+		{"editor", user("editor"), http.StatusOK, http.StatusOK},
+		{"viewer", user("viewer"), http.StatusOK, http.StatusForbidden},
+		{"downgraded creator", user("creator"), http.StatusOK, http.StatusForbidden},
+		{"other workspace", user("outsider"), http.StatusNotFound, http.StatusNotFound},
+		{"anonymous", nil, http.StatusNotFound, http.StatusNotFound},
+		{"API key", apiKey("editor", "workspace"), http.StatusOK, http.StatusOK},
+		{"API key of other workspace", apiKey("editor", "other"), http.StatusNotFound, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		for action, expected := range map[linkAction]int{linkRead: test.read, linkWrite: test.write} {
+			status := http.StatusOK
+			if _, resp := s.loadLink(newTestLinkRequest(test.principal), action); resp != nil {
+				status = resp.StatusCode()
+			}
+
+			if status != expected {
+				t.Errorf("%s: expected %d for action %d, got %d", test.name, expected, action, status)
+			}
+		}
+	}
+
+	// a missing link is reported like a link of another workspace
+	r := mux.SetURLVars(newTestLinkRequest(user("owner")), map[string]string{"code": "xyz"})
+	if _, resp := s.loadLink(r, linkRead); resp == nil || resp.StatusCode() != http.StatusNotFound {
+		t.Errorf("Expected %d for a missing link, got %+v", http.StatusNotFound, resp)
+	}
+}
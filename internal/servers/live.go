@@ -0,0 +1,105 @@
+package servers
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"lynkly-backend/internal/analytics"
+	"lynkly-backend/internal/events"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	lastEventIDHeader      = "Last-Event-ID"
+	contentTextEventStream = "text/event-stream"
+)
+
+// LiveClicksHandler streams the clicks of a short link as Server-Sent Events. Clients may resume
+// a dropped stream by sending the Last-Event-ID header, as long as the missed events are still
+// in the replay buffer of the hub.
+func (s *UrlShortenerServer) LiveClicksHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("UrlShortenerServer.LiveClicksHandler")
+
+	link, resp := s.loadLink(r, linkRead)
+	if resp != nil {
+		s.writeResponse(w, r, resp)
+		return
+	}
+	code := link.Code
+
+	flusher, ok := w.(http.Flusher)
+	if ok == false {
+		s.writeResponse(w, r, lhttp.InternalServerError().FromTrustedMessage("Streaming is not supported"))
+		return
+	}
+
+	var subscription *events.Subscription
+	var backlog []events.Event
+	if header := r.Header.Get(lastEventIDHeader); header != "" {
+		lastEventID, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			s.writeResponse(w, r, lhttp.BadRequest().FromTrustedMessage("Invalid Last-Event-ID header"))
+			return
+		}
+		subscription, backlog = s.hub.SubscribeFrom(analytics.ClickTopic(code), lastEventID)
+	} else {
+		subscription = s.hub.Subscribe(analytics.ClickTopic(code))
+	}
+	defer s.hub.Unsubscribe(subscription)
+
+	w.Header().Set(lhttp.ContentTypeHeader, contentTextEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// disable response buffering in nginx-like reverse proxies
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range backlog {
+		if err := writeServerSentEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.liveStreamConfig.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscription.C:
+			if ok == false {
+				s.logger.WithRequest(r).Warn("Live click stream subscriber dropped for falling behind - ", code)
+				return
+			}
+			if err := writeServerSentEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *UrlShortenerServer) writeResponse(w http.ResponseWriter, r *http.Request, resp *lhttp.HttpResponse) {
+	if err := lhttp.Write(w, r, resp); err != nil {
+		s.logger.WithRequest(r).Error(err.Error())
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, event events.Event) error {
+	data, err := jsoniter.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
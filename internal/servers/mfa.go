@@ -0,0 +1,189 @@
+package servers
+
+import (
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/mfa"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/users"
+	"net/http"
+)
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type twoFactorLoginRequest struct {
+	MFAToken string `json:"mfaToken"`
+	Code     string `json:"code"`
+}
+
+type twoFactorEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	// ProvisioningURI is rendered as a QR code for the authenticator apps
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// twoFactorChallengeResponse is returned instead of the tokens when the user has to enter a code
+type twoFactorChallengeResponse struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+}
+
+// BeginTwoFactorHandler generates the TOTP secret of the user. Two-factor authentication is enabled
+// once the secret is confirmed with a code.
+func (s *UrlShortenerServer) BeginTwoFactorHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.BeginTwoFactorHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	user, err := s.users.Store().Get(auth.PrincipalFrom(r).UserID)
+	if err == users.ErrNotFound {
+		return lhttp.NotFound().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load user: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to enable two-factor authentication")
+	}
+
+	secret, uri, err := s.mfa.Begin(user.ID, user.Email)
+	if err == mfa.ErrAlreadyEnrolled {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to begin two-factor enrollment: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to enable two-factor authentication")
+	}
+
+	return lhttp.OK().WithJSON(twoFactorEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: uri,
+	})
+}
+
+// ConfirmTwoFactorHandler enables two-factor authentication with the first code of the authenticator
+// app and returns the recovery codes. The current session is not upgraded, the user has to log in
+// again to satisfy a workspace requiring two-factor authentication.
+func (s *UrlShortenerServer) ConfirmTwoFactorHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ConfirmTwoFactorHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	var request twoFactorCodeRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	codes, err := s.mfa.Confirm(auth.PrincipalFrom(r).UserID, request.Code)
+	if err == mfa.ErrNotEnrolled || err == mfa.ErrAlreadyEnrolled {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err == mfa.ErrInvalidCode {
+		return lhttp.BadRequest().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to confirm two-factor enrollment: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to enable two-factor authentication")
+	}
+
+	return lhttp.OK().WithJSON(recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodesHandler replaces the recovery codes after verifying a code.
+func (s *UrlShortenerServer) RegenerateRecoveryCodesHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.RegenerateRecoveryCodesHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	var request twoFactorCodeRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	codes, err := s.mfa.RegenerateRecoveryCodes(auth.PrincipalFrom(r).UserID, request.Code)
+	if err == mfa.ErrNotEnrolled {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err == mfa.ErrInvalidCode {
+		return lhttp.Forbidden().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to regenerate recovery codes: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to regenerate the recovery codes")
+	}
+
+	return lhttp.OK().WithJSON(recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactorHandler turns two-factor authentication off after verifying a code.
+func (s *UrlShortenerServer) DisableTwoFactorHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.DisableTwoFactorHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	var request twoFactorCodeRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	err := s.mfa.Disable(auth.PrincipalFrom(r).UserID, request.Code)
+	if err == mfa.ErrNotEnrolled {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err == mfa.ErrInvalidCode {
+		return lhttp.Forbidden().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to disable two-factor authentication: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to disable two-factor authentication")
+	}
+
+	return lhttp.NoContent()
+}
+
+// CompleteTwoFactorLoginHandler exchanges the token of a login waiting for the second factor and a TOTP
+// or recovery code for the tokens of a new session.
+func (s *UrlShortenerServer) CompleteTwoFactorLoginHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.CompleteTwoFactorLoginHandler")
+	var request twoFactorLoginRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	challenge, err := s.mfa.CompleteChallenge(request.MFAToken, request.Code)
+	if err == mfa.ErrUnknownChallenge || err == mfa.ErrInvalidCode {
+		return lhttp.Unauthorized().FromTrustedError(err)
+	} else if err == mfa.ErrNotEnrolled {
+		// two-factor authentication was disabled while the login was pending
+		return lhttp.Unauthorized().FromTrustedError(mfa.ErrUnknownChallenge)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to verify two-factor login: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to log in")
+	}
+
+	return s.startSession(r, challenge.UserID, []string{challenge.AuthMethod, auth.AuthMethodOTP}, "Failed to log in")
+}
+
+// login starts a session for the user authenticated with the method. Users with two-factor
+// authentication get a token to complete the login with a code instead.
+func (s *UrlShortenerServer) login(r *http.Request, userID, authMethod, failMessage string) *lhttp.HttpResponse {
+	enabled, err := s.mfa.Enabled(userID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load two-factor authentication: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage(failMessage)
+	}
+
+	if enabled == false {
+		return s.startSession(r, userID, []string{authMethod}, failMessage)
+	}
+
+	token, err := s.mfa.StartChallenge(userID, authMethod)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to start two-factor login: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage(failMessage)
+	}
+
+	return lhttp.OK().WithJSON(twoFactorChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+	})
+}
@@ -0,0 +1,385 @@
+package servers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"html/template"
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/links"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/moderation"
+	"lynkly-backend/internal/routers"
+	"lynkly-backend/internal/webhooks"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultReportsPageSize = 50
+	maxReportsPageSize     = 500
+	// proceedParam confirms the warning page of a quarantined link
+	proceedParam = "proceed"
+)
+
+var (
+	errLinkDisabled     = errors.New("short URL is disabled - restore it before quarantining it")
+	errLinkNotModerated = errors.New("short URL is neither disabled nor quarantined")
+)
+
+var quarantineWarningTemplate = template.Must(template.New("quarantine").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Warning - this link has been reported</title>
+<style nonce="{{.Nonce}}">
+body { font-family: sans-serif; max-width: 40em; margin: 4em auto; padding: 0 1em; line-height: 1.5; }
+</style>
+</head>
+<body>
+<h1>This link has been reported</h1>
+<p>The short link {{.Code}} was reported for abuse and is under review. It leads to:</p>
+<p><code>{{.URL}}</code></p>
+<p>Only continue if you trust this destination.</p>
+<p><a href="?{{.ProceedParam}}=1" rel="nofollow">Continue to the destination</a></p>
+</body>
+</html>
+`))
+
+type createReportRequest struct {
+	// Link is the short URL or its code
+	Link    string            `json:"link"`
+	Reason  moderation.Reason `json:"reason"`
+	Details string            `json:"details"`
+}
+
+type reportReceipt struct {
+	ID     string            `json:"id"`
+	Status moderation.Status `json:"status"`
+}
+
+type reportsPageResponse struct {
+	Reports []*moderation.Report `json:"reports"`
+	// NextCursor is passed as the cursor parameter to load the next page. It is empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type moderateLinkRequest struct {
+	Action moderation.Action `json:"action"`
+	Note   string            `json:"note"`
+}
+
+type moderateLinkResponse struct {
+	// Link is left out when the link was deleted
+	Link  *links.Link       `json:"link,omitempty"`
+	Event *moderation.Event `json:"event"`
+}
+
+type dismissReportRequest struct {
+	Note string `json:"note"`
+}
+
+type dismissReportResponse struct {
+	Report *moderation.Report `json:"report"`
+	Event  *moderation.Event  `json:"event"`
+}
+
+// CreateReportHandler lets anyone report a short link for abuse. The reporter only learns the ID of
+// the report, not the workspace of the link.
+func (s *UrlShortenerServer) CreateReportHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.CreateReportHandler")
+
+	var request createReportRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	code := strings.TrimPrefix(strings.TrimSpace(request.Link), s.serviceUrl+routers.PathAPIV1+"/")
+	if code == "" {
+		return lhttp.BadRequest().FromTrustedMessage("Missing link - expected a short URL or its code")
+	}
+
+	link, err := s.links.Get(code)
+	if err == links.ErrNotFound {
+		return lhttp.NotFound().FromTrustedMessage(fmt.Sprintf("Short URL not found - %s", code))
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load link: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to report short URL")
+	}
+
+	report, err := s.moderation.Report(link.Code, link.WorkspaceID, request.Reason, request.Details)
+	if err == moderation.ErrInvalidReason || err == moderation.ErrDetailsTooLong {
+		return lhttp.BadRequest().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to store report: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to report short URL")
+	}
+	s.logger.WithRequest(r).Info("Link ", link.Code, " reported for ", report.Reason)
+
+	return lhttp.Created().WithJSON(reportReceipt{ID: report.ID, Status: report.Status})
+}
+
+// ListReportsHandler returns a page of the moderation queue, oldest first. The reports are filtered by
+// the status query parameter, "open" by default, and the optional code parameter.
+func (s *UrlShortenerServer) ListReportsHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ListReportsHandler")
+	if _, resp := s.moderatorID(r); resp != nil {
+		return resp
+	}
+
+	values := r.URL.Query()
+	query := moderation.Query{
+		Status: moderation.Status(values.Get("status")),
+		Code:   values.Get("code"),
+		Limit:  defaultReportsPageSize,
+	}
+	if query.Status == "" {
+		query.Status = moderation.StatusOpen
+	} else if query.Status.IsValid() == false {
+		return lhttp.BadRequest().FromTrustedMessage("Invalid status - expected one of open, resolved or dismissed")
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxReportsPageSize {
+			return lhttp.BadRequest().FromTrustedMessage("Invalid limit - expected between 1 and 500")
+		}
+		query.Limit = limit
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		var err error
+		if query.AfterSequence, err = strconv.ParseInt(cursor, 10, 64); err != nil || query.AfterSequence < 0 {
+			return lhttp.BadRequest().FromTrustedMessage("Invalid cursor")
+		}
+	}
+
+	reports, err := s.moderation.Store().ListReports(query)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load reports: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load reports")
+	}
+
+	page := reportsPageResponse{Reports: emptyIfNil(reports)}
+	if len(reports) == query.Limit {
+		page.NextCursor = strconv.FormatInt(reports[len(reports)-1].Sequence, 10)
+	}
+
+	return lhttp.OK().WithJSON(page)
+}
+
+func (s *UrlShortenerServer) GetReportHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.GetReportHandler")
+	if _, resp := s.moderatorID(r); resp != nil {
+		return resp
+	}
+
+	report, err := s.moderation.Store().Report(mux.Vars(r)["reportID"])
+	if err == moderation.ErrNotFound {
+		return lhttp.NotFound().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load report: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load report")
+	}
+
+	return lhttp.OK().WithJSON(report)
+}
+
+// DismissReportHandler closes an open report without acting on the link.
+func (s *UrlShortenerServer) DismissReportHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.DismissReportHandler")
+	moderatorID, resp := s.moderatorID(r)
+	if resp != nil {
+		return resp
+	}
+
+	var request dismissReportRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	before, err := s.moderation.Store().Report(mux.Vars(r)["reportID"])
+	if err == moderation.ErrNotFound {
+		return lhttp.NotFound().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load report: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to dismiss report")
+	}
+
+	report, event, err := s.moderation.Dismiss(before.ID, moderatorID, request.Note)
+	if err == moderation.ErrNotFound {
+		return lhttp.NotFound().FromTrustedError(err)
+	} else if err == moderation.ErrAlreadyResolved {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err == moderation.ErrNoteTooLong {
+		return lhttp.BadRequest().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to dismiss report: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to dismiss report")
+	}
+
+	audit.Describe(r, audit.ActionReportDismiss, audit.Target{Type: "report", ID: report.ID, WorkspaceID: report.WorkspaceID})
+	audit.RecordChange(r, before, report)
+
+	return lhttp.OK().WithJSON(dismissReportResponse{Report: report, Event: event})
+}
+
+// ModerateLinkHandler disables, quarantines, restores or deletes a link of any workspace and resolves
+// its open reports. The decision is kept in the moderation history of the link.
+func (s *UrlShortenerServer) ModerateLinkHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ModerateLinkHandler")
+	moderatorID, resp := s.moderatorID(r)
+	if resp != nil {
+		return resp
+	}
+
+	var request moderateLinkRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+	if err := moderation.ValidateDecision(request.Action, request.Note); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	code := mux.Vars(r)["code"]
+	link, err := s.links.Get(code)
+	if err == links.ErrNotFound {
+		return lhttp.NotFound().FromTrustedMessage(fmt.Sprintf("Short URL not found - %s", code))
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load link: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to moderate short URL")
+	}
+
+	if link, resp = s.applyModeration(r, link, request.Action); resp != nil {
+		return resp
+	}
+
+	event, err := s.moderation.Decide(code, request.Action, moderatorID, request.Note)
+	if err != nil {
+		// the link has already been changed, only its history is incomplete
+		s.logger.WithRequest(r).Error("Failed to record moderation of link ", code, ": ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to record the moderation decision")
+	}
+
+	return lhttp.OK().WithJSON(moderateLinkResponse{Link: link, Event: event})
+}
+
+// applyModeration changes the current version of the link according to the action, audits the change
+// and notifies the workspace of the link. It returns the changed link, or nil when it was deleted.
+func (s *UrlShortenerServer) applyModeration(r *http.Request, link *links.Link, action moderation.Action) (*links.Link, *lhttp.HttpResponse) {
+	target := audit.Target{Type: "link", ID: link.Code, WorkspaceID: link.WorkspaceID}
+
+	if action == moderation.ActionDelete {
+		if err := s.links.Delete(link.Code); err != nil && err != links.ErrNotFound {
+			s.logger.WithRequest(r).Error("Failed to delete link: ", err)
+			return nil, lhttp.InternalServerError().FromTrustedMessage("Failed to delete short URL")
+		}
+
+		audit.Describe(r, audit.ActionLinkDelete, target)
+		audit.RecordChange(r, link, nil)
+		s.dispatchWebhook(r, link.WorkspaceID, webhooks.LinkDeleted, link)
+		return nil, nil
+	}
+
+	var before links.Link
+	var auditAction string
+	var event webhooks.EventType
+	now := time.Now().UTC()
+	link, err := s.links.Modify(link.Code, func(link *links.Link) error {
+		before = *link
+		if action == moderation.ActionDisable {
+			link.DisabledAt = &now
+			link.DisabledReason = "Disabled by a moderator"
+			link.Threat = ""
+			link.QuarantinedAt = nil
+			link.QuarantineReason = ""
+			auditAction, event = audit.ActionLinkDisable, webhooks.LinkDisabled
+		} else if action == moderation.ActionQuarantine {
+			if link.IsDisabled() {
+				return errLinkDisabled
+			}
+			link.QuarantinedAt = &now
+			link.QuarantineReason = "Quarantined by a moderator"
+			auditAction, event = audit.ActionLinkQuarantine, webhooks.LinkQuarantined
+		} else {
+			if link.IsDisabled() == false && link.IsQuarantined() == false {
+				return errLinkNotModerated
+			}
+			link.DisabledAt = nil
+			link.DisabledReason = ""
+			link.Threat = ""
+			link.QuarantinedAt = nil
+			link.QuarantineReason = ""
+			auditAction, event = audit.ActionLinkRestore, webhooks.LinkRestored
+		}
+
+		link.UpdatedAt = now
+		return nil
+	})
+	if err == links.ErrNotFound {
+		return nil, lhttp.NotFound().FromTrustedMessage(fmt.Sprintf("Short URL not found - %s", target.ID))
+	} else if err == errLinkDisabled || err == errLinkNotModerated {
+		return nil, lhttp.Conflict().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to update link: ", err)
+		return nil, lhttp.InternalServerError().FromTrustedMessage("Failed to moderate short URL")
+	}
+
+	audit.Describe(r, auditAction, target)
+	audit.RecordChange(r, &before, link)
+	s.dispatchWebhook(r, link.WorkspaceID, event, link)
+	return link, nil
+}
+
+// LinkModerationHistoryHandler returns the moderation decisions on a link, oldest first. The history
+// is kept after the link is deleted.
+func (s *UrlShortenerServer) LinkModerationHistoryHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.LinkModerationHistoryHandler")
+	if _, resp := s.moderatorID(r); resp != nil {
+		return resp
+	}
+
+	events, err := s.moderation.Store().History(mux.Vars(r)["code"])
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load moderation history: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load moderation history")
+	}
+
+	return lhttp.OK().WithJSON(emptyIfNil(events))
+}
+
+// moderatorID returns the ID of the moderator making the request. Moderators act across workspaces,
+// so they are not restricted by the role in the current one, but API keys never act as moderators.
+func (s *UrlShortenerServer) moderatorID(r *http.Request) (string, *lhttp.HttpResponse) {
+	principal := auth.PrincipalFrom(r)
+	if principal == nil || principal.ActsAsUser() == false || s.moderation.IsModerator(principal.UserID) == false {
+		return "", lhttp.Forbidden().FromTrustedMessage("Only moderators can review reports")
+	}
+
+	return principal.UserID, nil
+}
+
+// quarantineWarningPage is shown instead of redirecting to the destination of a quarantined link until
+// the visitor confirms it.
+func (s *UrlShortenerServer) quarantineWarningPage(r *http.Request, link *links.Link) *lhttp.HttpResponse {
+	var page bytes.Buffer
+	err := quarantineWarningTemplate.Execute(&page, map[string]string{
+		"Code":         link.Code,
+		"URL":          link.URL,
+		"ProceedParam": proceedParam,
+		"Nonce":        cspNonce(r),
+	})
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to render quarantine warning: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load short URL")
+	}
+
+	return lhttp.OK().
+		WithBinary(lhttp.ContentTextHTML, page.Bytes()).
+		WithHeaders(map[string]string{"Cache-Control": "no-store"})
+}
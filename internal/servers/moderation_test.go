@@ -0,0 +1,266 @@
+package servers
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/links"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/moderation"
+	"lynkly-backend/internal/webhooks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestModerationServer(t *testing.T) *UrlShortenerServer {
+	t.Helper()
+
+	logger := logging.NewLogger("servers_test")
+	s := &UrlShortenerServer{
+		logger:     logger,
+		serviceUrl: "https://lynk.ly",
+		links:      links.NewMemoryStore(),
+		moderation: moderation.New(moderation.Params{Store: moderation.NewMemoryStore(), ModeratorIDs: []string{"moderator"}}),
+		webhooks:   webhooks.New(webhooks.Params{Logger: logger, Store: webhooks.NewMemoryStore()}),
+	}
+
+	for _, code := range []string{"abc", "other"} {
+		if err := s.links.Create(&links.Link{Code: code, URL: "https://example.com/", WorkspaceID: "workspace"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return s
+}
+
+func newTestModerationRequest(method, body string, vars map[string]string, userID string) *http.Request {
+	r := httptest.NewRequest(method, "/api/v1/moderation", strings.NewReader(body))
+	r = mux.SetURLVars(r, vars)
+	if userID != "" {
+		r = common.ContextSet(r, common.ContextPair{Key: logging.UserKey, Value: &auth.Principal{UserID: userID, Method: auth.MethodJWT}})
+	}
+
+	return r
+}
+
+func TestCreateReport(t *testing.T) {
+	s := newTestModerationServer(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"short URL", `{"link": " https://lynk.ly/api/v1/abc ", "reason": "phishing"}`, http.StatusCreated},
+		{"code", `{"link": "abc", "reason": "spam", "details": "sent by email"}`, http.StatusCreated},
+		{"missing link", `{"link": "https://lynk.ly/api/v1/", "reason": "spam"}`, http.StatusBadRequest},
+		{"unknown link", `{"link": "missing", "reason": "spam"}`, http.StatusNotFound},
+		{"invalid reason", `{"link": "abc", "reason": "boring"}`, http.StatusBadRequest},
+		{"invalid body", `{"link":`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		// reports are anonymous
+		resp := s.CreateReportHandler(newTestModerationRequest(http.MethodPost, test.body, nil, ""))
+		if resp.StatusCode() != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, resp.StatusCode())
+		}
+	}
+
+	reports, err := s.moderation.Store().ListReports(moderation.Query{Code: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || reports[0].WorkspaceID != "workspace" || reports[1].Details != "sent by email" {
+		t.Errorf("Expected the two reports of the link, got %v", reports)
+	}
+}
+
+func TestListReports(t *testing.T) {
+	s := newTestModerationServer(t)
+	for _, code := range []string{"abc", "other", "abc"} {
+		if _, err := s.moderation.Report(code, "workspace", moderation.ReasonSpam, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, userID := range []string{"", "user"} {
+		if resp := s.ListReportsHandler(newTestModerationRequest(http.MethodGet, "", nil, userID)); resp.StatusCode() != http.StatusForbidden {
+			t.Errorf("%q: expected %d, got %d", userID, http.StatusForbidden, resp.StatusCode())
+		}
+	}
+	apiKey := newTestModerationRequest(http.MethodGet, "", nil, "")
+	apiKey = common.ContextSet(apiKey, common.ContextPair{Key: logging.UserKey, Value: &auth.Principal{UserID: "moderator", APIKeyID: "key", Method: auth.MethodAPIKey}})
+	if resp := s.ListReportsHandler(apiKey); resp.StatusCode() != http.StatusForbidden {
+		t.Errorf("Expected API keys not to act as moderators, got %d", resp.StatusCode())
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		expected   []int64
+		nextCursor string
+	}{
+		{"open", "", []int64{1, 2, 3}, ""},
+		{"code", "?code=abc", []int64{1, 3}, ""},
+		{"first page", "?limit=2", []int64{1, 2}, "2"},
+		{"last page", "?limit=2&cursor=2", []int64{3}, ""},
+		{"resolved", "?status=resolved", nil, ""},
+	}
+
+	for _, test := range tests {
+		r := newTestModerationRequest(http.MethodGet, "", nil, "moderator")
+		r.URL.RawQuery = strings.TrimPrefix(test.query, "?")
+		resp := s.ListReportsHandler(r)
+		if resp.StatusCode() != http.StatusOK {
+			t.Errorf("%s: expected %d, got %d", test.name, http.StatusOK, resp.StatusCode())
+			continue
+		}
+
+		page := resp.Payload().(reportsPageResponse)
+		var sequences []int64
+		for _, report := range page.Reports {
+			sequences = append(sequences, report.Sequence)
+		}
+		if fmt.Sprint(sequences) != fmt.Sprint(test.expected) || page.NextCursor != test.nextCursor {
+			t.Errorf("%s: expected %v with cursor %q, got %v with %q", test.name, test.expected, test.nextCursor, sequences, page.NextCursor)
+		}
+	}
+
+	for _, query := range []string{"status=closed", "limit=0", "limit=501", "cursor=-1", "cursor=abc"} {
+		r := newTestModerationRequest(http.MethodGet, "", nil, "moderator")
+		r.URL.RawQuery = query
+		if resp := s.ListReportsHandler(r); resp.StatusCode() != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", query, http.StatusBadRequest, resp.StatusCode())
+		}
+	}
+}
+
+func TestDismissReport(t *testing.T) {
+	s := newTestModerationServer(t)
+	report, err := s.moderation.Report("abc", "workspace", moderation.ReasonOther, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{"reportID": report.ID}
+
+	if resp := s.DismissReportHandler(newTestModerationRequest(http.MethodPost, `{}`, vars, "user")); resp.StatusCode() != http.StatusForbidden {
+		t.Errorf("Expected %d for a user, got %d", http.StatusForbidden, resp.StatusCode())
+	}
+	if resp := s.DismissReportHandler(newTestModerationRequest(http.MethodPost, `{"note": "fine"}`, vars, "moderator")); resp.StatusCode() != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode())
+	}
+	if resp := s.DismissReportHandler(newTestModerationRequest(http.MethodPost, `{}`, vars, "moderator")); resp.StatusCode() != http.StatusConflict {
+		t.Errorf("Expected %d when dismissing again, got %d", http.StatusConflict, resp.StatusCode())
+	}
+	missing := map[string]string{"reportID": "missing"}
+	if resp := s.DismissReportHandler(newTestModerationRequest(http.MethodPost, `{}`, missing, "moderator")); resp.StatusCode() != http.StatusNotFound {
+		t.Errorf("Expected %d for a missing report, got %d", http.StatusNotFound, resp.StatusCode())
+	}
+
+	if link, _ := s.links.Get("abc"); link.IsDisabled() || link.IsQuarantined() {
+		t.Errorf("Expected the link not to be changed, got %+v", link)
+	}
+}
+
+func TestModerateLink(t *testing.T) {
+	s := newTestModerationServer(t)
+	report, err := s.moderation.Report("abc", "workspace", moderation.ReasonPhishing, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moderate := func(action moderation.Action, userID string) *lhttp.HttpResponse {
+		body := `{"action": "` + string(action) + `", "note": "` + string(action) + `d"}`
+		return s.ModerateLinkHandler(newTestModerationRequest(http.MethodPost, body, map[string]string{"code": "abc"}, userID))
+	}
+	redirect := func() *lhttp.HttpResponse {
+		return s.RedirectHandler(mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/abc", nil), map[string]string{"shortURL": "abc"}))
+	}
+
+	if resp := moderate(moderation.ActionDisable, "user"); resp.StatusCode() != http.StatusForbidden {
+		t.Errorf("Expected %d for a user, got %d", http.StatusForbidden, resp.StatusCode())
+	}
+	if resp := moderate("ban", "moderator"); resp.StatusCode() != http.StatusBadRequest {
+		t.Errorf("Expected %d for an invalid action, got %d", http.StatusBadRequest, resp.StatusCode())
+	}
+	if resp := moderate(moderation.ActionRestore, "moderator"); resp.StatusCode() != http.StatusConflict {
+		t.Errorf("Expected %d when restoring an active link, got %d", http.StatusConflict, resp.StatusCode())
+	}
+
+	// quarantined links show a warning page instead of redirecting
+	resp := moderate(moderation.ActionQuarantine, "moderator")
+	if resp.StatusCode() != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode())
+	}
+	if link := resp.Payload().(moderateLinkResponse).Link; link == nil || link.IsQuarantined() == false {
+		t.Errorf("Expected the quarantined link in the response, got %+v", link)
+	}
+	resp = redirect()
+	if page, ok := resp.Payload().([]byte); resp.StatusCode() != http.StatusOK || ok == false || strings.Contains(string(page), "This link has been reported") == false {
+		t.Errorf("Expected the warning page, got %d", resp.StatusCode())
+	}
+
+	// disabled links are gone
+	if resp = moderate(moderation.ActionDisable, "moderator"); resp.StatusCode() != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode())
+	}
+	if link, _ := s.links.Get("abc"); link.IsDisabled() == false || link.IsQuarantined() {
+		t.Errorf("Expected the link to be disabled and no longer quarantined, got %+v", link)
+	}
+	if resp = redirect(); resp.StatusCode() != http.StatusGone {
+		t.Errorf("Expected %d for a disabled link, got %d", http.StatusGone, resp.StatusCode())
+	}
+	if resp = moderate(moderation.ActionQuarantine, "moderator"); resp.StatusCode() != http.StatusConflict {
+		t.Errorf("Expected %d when quarantining a disabled link, got %d", http.StatusConflict, resp.StatusCode())
+	}
+
+	if resp = moderate(moderation.ActionRestore, "moderator"); resp.StatusCode() != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode())
+	}
+	if link, _ := s.links.Get("abc"); link.IsDisabled() || link.IsQuarantined() {
+		t.Errorf("Expected the link to be restored, got %+v", link)
+	}
+
+	resp = moderate(moderation.ActionDelete, "moderator")
+	if resp.StatusCode() != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode())
+	}
+	if link := resp.Payload().(moderateLinkResponse).Link; link != nil {
+		t.Errorf("Expected no link in the response of a delete, got %+v", link)
+	}
+	if resp = redirect(); resp.StatusCode() != http.StatusNotFound {
+		t.Errorf("Expected %d for a deleted link, got %d", http.StatusNotFound, resp.StatusCode())
+	}
+	if resp = moderate(moderation.ActionDisable, "moderator"); resp.StatusCode() != http.StatusNotFound {
+		t.Errorf("Expected %d when moderating a deleted link, got %d", http.StatusNotFound, resp.StatusCode())
+	}
+
+	// the report is resolved by the first decision and the history outlives the link
+	if stored, _ := s.moderation.Store().Report(report.ID); stored.Status != moderation.StatusResolved || stored.Resolution != moderation.ActionQuarantine {
+		t.Errorf("Expected the report to be resolved by the quarantine, got %+v", stored)
+	}
+
+	resp = s.LinkModerationHistoryHandler(newTestModerationRequest(http.MethodGet, "", map[string]string{"code": "abc"}, "moderator"))
+	if resp.StatusCode() != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode())
+	}
+	history := resp.Payload().([]*moderation.Event)
+	expected := []moderation.Action{moderation.ActionQuarantine, moderation.ActionDisable, moderation.ActionRestore, moderation.ActionDelete}
+	if len(history) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, history)
+	}
+	for i, event := range history {
+		if event.Action != expected[i] || event.ModeratorID != "moderator" || event.Note != string(expected[i])+"d" {
+			t.Errorf("Expected %s by the moderator, got %+v", expected[i], event)
+		}
+	}
+
+	if resp = s.LinkModerationHistoryHandler(newTestModerationRequest(http.MethodGet, "", map[string]string{"code": "abc"}, "user")); resp.StatusCode() != http.StatusForbidden {
+		t.Errorf("Expected %d for a user, got %d", http.StatusForbidden, resp.StatusCode())
+	}
+}
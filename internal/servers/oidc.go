@@ -0,0 +1,132 @@
+package servers
+
+import (
+	"crypto/subtle"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/oidc"
+	"lynkly-backend/internal/routers"
+	"lynkly-backend/internal/users"
+	"lynkly-backend/internal/workspaces"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	oidcStateCookie = "lynkly_oidc_state"
+	oidcCookiePath  = routers.PathAPIV1 + "/auth/oidc"
+)
+
+// OIDCLoginHandler redirects the user to the identity provider. The state is also set in a cookie, so
+// that the callback only completes logins started by the same browser.
+func (s *UrlShortenerServer) OIDCLoginHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.OIDCLoginHandler")
+	authURL, state, err := s.oidc.Begin(r.Context())
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to start single sign-on: ", err)
+		return lhttp.Unavailable().FromTrustedMessage("Single sign-on is not available")
+	}
+
+	cookie := s.oidcStateCookie(state, int(s.oidc.LoginTTL()/time.Second))
+	return lhttp.Redirect().Found(authURL).WithHeaders(map[string]string{"Set-Cookie": cookie.String()})
+}
+
+// OIDCCallbackHandler completes the login, provisions the user on the first login and issues an access
+// token. The user joins the single sign-on workspace with the role mapped from its groups.
+func (s *UrlShortenerServer) OIDCCallbackHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.OIDCCallbackHandler")
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		s.logger.WithRequest(r).Warn("Identity provider refused the login: ", providerError, " ", query.Get("error_description"))
+		return lhttp.Unauthorized().FromTrustedMessage("Single sign-on was refused by the identity provider")
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return lhttp.BadRequest().FromTrustedMessage("Invalid login state - please start the login again")
+	}
+
+	identity, err := s.oidc.Complete(r.Context(), state, query.Get("code"))
+	if err == oidc.ErrUnknownState {
+		return lhttp.BadRequest().FromTrustedMessage("Invalid login state - please start the login again")
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to complete single sign-on: ", err)
+		return lhttp.Unauthorized().FromTrustedMessage("Single sign-on failed")
+	}
+
+	role, ok := s.roleForGroups(identity.Groups)
+	if ok == false {
+		s.logger.WithRequest(r).Warn("Single sign-on user has no mapped group: ", identity.Subject)
+		return lhttp.Forbidden().FromTrustedMessage("Your account is not allowed to access lynkly")
+	}
+
+	user, err := s.users.Provision(users.ExternalIdentity{
+		Issuer:        identity.Issuer,
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+	})
+	if err == users.ErrInvalidEmail {
+		return lhttp.Forbidden().FromTrustedMessage("The identity provider did not share a valid email")
+	} else if err == users.ErrEmailNotVerified {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to provision user: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Single sign-on failed")
+	}
+
+	if err = s.joinSSOWorkspace(user.ID, role); err != nil {
+		s.logger.WithRequest(r).Error("Failed to add user to the single sign-on workspace: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Single sign-on failed")
+	}
+
+	return s.login(r, user.ID, auth.AuthMethodSSO, "Single sign-on failed").
+		WithHeaders(map[string]string{"Set-Cookie": s.oidcStateCookie("", -1).String()})
+}
+
+// roleForGroups returns the most privileged role mapped from the groups, or the default role when none
+// of the groups is mapped.
+func (s *UrlShortenerServer) roleForGroups(groups []string) (auth.Role, bool) {
+	var result auth.Role
+	for _, group := range groups {
+		role := auth.Role(s.oidcConfig.RoleMapping[group])
+		if role.IsValid() && role.Outranks(result) {
+			result = role
+		}
+	}
+
+	if result == "" {
+		result = auth.Role(s.oidcConfig.DefaultRole)
+	}
+
+	return result, result.IsValid()
+}
+
+// joinSSOWorkspace adds the user to the single sign-on workspace or updates its role, so that changes of
+// the groups take effect on the next login. Owners are managed in the workspace and keep their role.
+func (s *UrlShortenerServer) joinSSOWorkspace(userID string, role auth.Role) error {
+	member, err := s.workspaces.Store().Member(s.oidcConfig.WorkspaceID, userID)
+	if err == nil && member.Role == auth.RoleOwner {
+		return nil
+	} else if err != nil && err != workspaces.ErrMemberNotFound {
+		return err
+	}
+
+	_, err = s.workspaces.SetRole(s.oidcConfig.WorkspaceID, userID, role)
+	return err
+}
+
+func (s *UrlShortenerServer) oidcStateCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     oidcCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(s.oidcConfig.RedirectURL, "https://"),
+		// Lax lets the cookie through on the top-level redirect back from the identity provider
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,51 @@
+package servers
+
+import (
+	"github.com/gorilla/mux"
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/privacy"
+	"net/http"
+)
+
+func (s *UrlShortenerServer) GetPrivacySettingsHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.GetPrivacySettingsHandler")
+
+	settings, err := s.privacy.Settings().Get(mux.Vars(r)["workspaceID"])
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load privacy settings: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load privacy settings")
+	}
+
+	return lhttp.OK().WithJSON(settings)
+}
+
+func (s *UrlShortenerServer) UpdatePrivacySettingsHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.UpdatePrivacySettingsHandler")
+
+	var settings privacy.Settings
+	if err := decodeJSONBody(r, &settings); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	if settings.AnalyticsMode.IsValid() == false {
+		return lhttp.BadRequest().FromTrustedMessage("Invalid analyticsMode - expected detailed or aggregate")
+	}
+
+	settings.WorkspaceID = mux.Vars(r)["workspaceID"]
+	before, err := s.privacy.Settings().Get(settings.WorkspaceID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load privacy settings: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to save privacy settings")
+	}
+
+	if err = s.privacy.Settings().Save(settings); err != nil {
+		s.logger.WithRequest(r).Error("Failed to save privacy settings: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to save privacy settings")
+	}
+
+	audit.Describe(r, audit.ActionPrivacyUpdate, audit.Target{Type: "privacy_settings", ID: settings.WorkspaceID})
+	audit.RecordChange(r, before, &settings)
+
+	return lhttp.OK().WithJSON(settings)
+}
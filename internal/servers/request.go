@@ -0,0 +1,59 @@
+package servers
+
+import (
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"lynkly-backend/internal/auth"
+	"net/http"
+	"time"
+)
+
+const maxJSONBodySize = 1 << 20
+
+var ErrInvalidJSONBody = errors.New("invalid JSON body")
+
+// decodeJSONBody decodes the JSON body of the request into v. The returned error is safe to be
+// displayed to the client.
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	if r.Body == nil {
+		return ErrInvalidJSONBody
+	}
+
+	decoder := jsoniter.NewDecoder(http.MaxBytesReader(nil, r.Body, maxJSONBodySize))
+	if err := decoder.Decode(v); err != nil {
+		return ErrInvalidJSONBody
+	}
+
+	return nil
+}
+
+func parseTimeParam(r *http.Request, name string, defaultValue time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s parameter - expected an RFC 3339 time", name)
+	}
+
+	return t.UTC(), nil
+}
+
+// principalUserID returns the ID of the authenticated user or an empty string for anonymous requests.
+func principalUserID(r *http.Request) string {
+	if principal := auth.PrincipalFrom(r); principal != nil {
+		return principal.UserID
+	}
+	return ""
+}
+
+// emptyIfNil makes nil lists get encoded as [] instead of null in JSON responses.
+func emptyIfNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
@@ -1,13 +1,242 @@
 package servers
 
 import (
+	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/config"
+	"lynkly-backend/internal/domains"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/origins"
+	"lynkly-backend/internal/routers"
 	"net/http"
+	"strings"
 )
 
-func AddCorsOptions(router *mux.Router, corsOptions cors.Options) http.Handler {
-	corsOptions.MaxAge = 604800 // Tell the client to cache the CORS headers for 1 week
+// security headers
+const (
+	requestMethodHeader           = "Access-Control-Request-Method"
+	strictTransportSecurityHeader = "Strict-Transport-Security"
+	contentSecurityPolicyHeader   = "Content-Security-Policy"
+	referrerPolicyHeader          = "Referrer-Policy"
+	frameOptionsHeader            = "X-Frame-Options"
+	permissionsPolicyHeader       = "Permissions-Policy"
+)
+
+const (
+	cspNonceKey = "cspNonce"
+	// noncePlaceholder is replaced by the nonce of the request in the Content-Security-Policy
+	noncePlaceholder = "{nonce}"
+	nonceLength      = 16
+)
+
+var (
+	apiMethods      = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch}
+	redirectMethods = []string{http.MethodGet, http.MethodHead}
+)
+
+// AddSecurityHeaders sets the security headers of the route group on every response, the headers of
+// the redirects on the redirect route and the headers of the management API on every other route. The
+// nonce in the Content-Security-Policy is generated per request, the HTML pages read it with cspNonce.
+// JSON responses are not rendered by browsers, so they are sent without a Content-Security-Policy.
+func (s *UrlShortenerServer) AddSecurityHeaders(router *mux.Router, next http.Handler, headers *config.SecurityHeadersConfig) http.Handler {
+	hsts := ""
+	if headers.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int64(headers.HSTSMaxAge.Seconds()))
+		if headers.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if headers.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := headers.API
+		if isRedirectRoute(router, r) {
+			policy = headers.Redirect
+		}
+
+		nonce := common.RandomHex(nonceLength)
+		header := w.Header()
+		header.Set(lhttp.ContentTypeOptions, lhttp.ContentTypeOptionsNoSniff)
+		setHeader(header, strictTransportSecurityHeader, hsts)
+		setHeader(header, referrerPolicyHeader, policy.ReferrerPolicy)
+		setHeader(header, frameOptionsHeader, policy.FrameOptions)
+		setHeader(header, permissionsPolicyHeader, policy.PermissionsPolicy)
+
+		w = &cspResponseWriter{
+			ResponseWriter: w,
+			policy:         strings.ReplaceAll(policy.ContentSecurityPolicy, noncePlaceholder, nonce),
+		}
+		next.ServeHTTP(w, common.ContextSet(r, common.ContextPair{Key: cspNonceKey, Value: nonce}))
+	})
+}
+
+// cspResponseWriter sets the Content-Security-Policy right before the header is written, once the
+// content type of the response is known.
+type cspResponseWriter struct {
+	http.ResponseWriter
+	policy      string
+	wroteHeader bool
+}
+
+func (w *cspResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader == false {
+		w.wroteHeader = true
+		if strings.HasPrefix(w.Header().Get(lhttp.ContentTypeHeader), "application/json") == false {
+			setHeader(w.Header(), contentSecurityPolicyHeader, w.policy)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *cspResponseWriter) Write(data []byte) (int, error) {
+	if w.wroteHeader == false {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush lets the live click stream flush its events through the writer
+func (w *cspResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *cspResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// cspNonce returns the nonce which the inline styles and scripts of the HTML pages have to carry.
+func cspNonce(r *http.Request) string {
+	nonce, _ := common.ContextGet(r, cspNonceKey).(string)
+	return nonce
+}
+
+// setHeader sets the header unless the value is empty
+func setHeader(header http.Header, name, value string) {
+	if value != "" {
+		header.Set(name, value)
+	}
+}
+
+// AddCorsOptions applies the CORS policy of the redirects to the redirect route and the policy of the
+// management API to every other route. Preflight requests are matched with the method they announce.
+func (s *UrlShortenerServer) AddCorsOptions(router *mux.Router, policies *config.CORSConfig) (http.Handler, error) {
+	for name, policy := range map[string]config.CORSPolicy{"CORS_API": policies.API, "CORS_REDIRECT": policies.Redirect} {
+		// browsers refuse credentials for the "*" origin, reflecting every origin instead would expose them
+		if policy.AllowCredentials && containsString(policy.AllowedOrigins, origins.Wildcard) {
+			return nil, fmt.Errorf("%s_ALLOW_CREDENTIALS cannot be combined with the %q origin", name, origins.Wildcard)
+		}
+	}
+
+	apiPolicy := corsOptions(policies.API, apiMethods)
+	apiPolicy.AllowOriginRequestFunc = func(r *http.Request, origin string) bool {
+		return origins.MatchAny(policies.API.AllowedOrigins, origin) || s.workspaceAllowsOrigin(router, r, origin)
+	}
+	apiHandler := cors.New(apiPolicy).Handler(router)
+
+	redirectPolicy := corsOptions(policies.Redirect, redirectMethods)
+	redirectPolicy.AllowedOrigins = policies.Redirect.AllowedOrigins
+	redirectHandler := cors.New(redirectPolicy).Handler(router)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isRedirectRoute(router, r) {
+			redirectHandler.ServeHTTP(w, r)
+			return
+		}
+		apiHandler.ServeHTTP(w, r)
+	}), nil
+}
+
+func corsOptions(policy config.CORSPolicy, methods []string) cors.Options {
+	return cors.Options{
+		AllowedMethods:   methods,
+		AllowedHeaders:   policy.AllowedHeaders,
+		ExposedHeaders:   policy.ExposedHeaders,
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           int(policy.MaxAge.Seconds()),
+	}
+}
+
+func isRedirectRoute(router *mux.Router, r *http.Request) bool {
+	match := matchRoute(router, r)
+	if match == nil {
+		return false
+	}
+
+	template, err := match.Route.GetPathTemplate()
+	return err == nil && template == routers.PathAPIV1+redirectPath
+}
+
+// matchRoute returns the route of the request, or nil when no route matches. Preflight requests are
+// matched with the method they announce.
+func matchRoute(router *mux.Router, r *http.Request) *mux.RouteMatch {
+	matched := r
+	if method := r.Header.Get(requestMethodHeader); r.Method == http.MethodOptions && method != "" {
+		matched = r.Clone(r.Context())
+		matched.Method = method
+	}
+
+	var match mux.RouteMatch
+	if router.Match(matched, &match) == false || match.Route == nil {
+		return nil
+	}
+
+	return &match
+}
+
+// workspaceAllowsOrigin reports whether the workspace in the path of the request allows the origin or
+// has it as a verified custom domain. The origins of a workspace are not allowed on the routes of other
+// workspaces or on the routes without a workspace in the path, since the preflight requests do not
+// carry the workspace header.
+func (s *UrlShortenerServer) workspaceAllowsOrigin(router *mux.Router, r *http.Request, origin string) bool {
+	match := matchRoute(router, r)
+	if match == nil || match.Vars["workspaceID"] == "" {
+		return false
+	}
+	workspaceID := match.Vars["workspaceID"]
+
+	allowed, err := s.workspaces.AllowsOrigin(workspaceID, origin)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load allowed origins: ", err)
+		return false
+	}
+
+	return allowed || s.isCustomDomainOrigin(r, workspaceID, origin)
+}
+
+// isCustomDomainOrigin reports whether the origin is the https origin of a verified custom domain of
+// the workspace.
+func (s *UrlShortenerServer) isCustomDomainOrigin(r *http.Request, workspaceID, origin string) bool {
+	host, found := strings.CutPrefix(strings.ToLower(origin), "https://")
+	if found == false || host == "" || strings.ContainsAny(host, ":/") {
+		return false
+	}
+
+	domain, err := s.domains.Domain(workspaceID, host)
+	if err == domains.ErrNotFound {
+		return false
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load custom domain: ", err)
+		return false
+	}
+
+	return domain.IsVerified()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
 
-	return cors.New(corsOptions).Handler(router)
+	return false
 }
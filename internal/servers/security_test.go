@@ -0,0 +1,203 @@
+package servers
+
+import (
+	"github.com/gorilla/mux"
+	"lynkly-backend/internal/config"
+	"lynkly-backend/internal/domains"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/routers"
+	"lynkly-backend/internal/workspaces"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestSecurityRouter serves JSON on the API routes and an HTML page showing the CSP nonce on the
+// redirect route
+func newTestSecurityRouter() *mux.Router {
+	api := func(w http.ResponseWriter, r *http.Request) {
+		_ = lhttp.Write(w, r, lhttp.OK().WithJSON(map[string]string{"status": "ok"}))
+	}
+	page := func(w http.ResponseWriter, r *http.Request) {
+		_ = lhttp.Write(w, r, lhttp.OK().WithBinary(lhttp.ContentTextHTML, []byte(cspNonce(r))))
+	}
+
+	router := mux.NewRouter()
+	v1 := router.PathPrefix(routers.PathAPIV1).Subrouter()
+	v1.HandleFunc("/links", api).Methods(http.MethodGet)
+	v1.HandleFunc("/workspaces/{workspaceID}/domains", api).Methods(http.MethodGet)
+	v1.HandleFunc(redirectPath, page).Methods(http.MethodGet)
+
+	return router
+}
+
+func TestAddCorsOptions(t *testing.T) {
+	s := &UrlShortenerServer{
+		logger:     logging.NewLogger("servers_test"),
+		workspaces: workspaces.New(workspaces.Params{Store: workspaces.NewMemoryStore()}),
+		domains:    domains.New(domains.Params{Store: domains.NewMemoryStore()}),
+	}
+	for _, workspaceID := range []string{"workspace", "other"} {
+		if _, err := s.workspaces.CreateWithID(workspaceID, workspaceID, "owner"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.workspaces.SetAllowedOrigins("workspace", []string{"https://*.workspace.example"}); err != nil {
+		t.Fatal(err)
+	}
+	verifiedAt := time.Now()
+	for _, domain := range []*domains.Domain{
+		{Name: "links.workspace.test", WorkspaceID: "workspace", VerifiedAt: &verifiedAt},
+		{Name: "unverified.workspace.test", WorkspaceID: "workspace"},
+	} {
+		if err := s.domains.Store().SaveDomain(domain); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler, err := s.AddCorsOptions(newTestSecurityRouter(), &config.CORSConfig{
+		API:      config.CORSPolicy{AllowedOrigins: []string{"https://app.lynk.ly"}, AllowedHeaders: []string{"Authorization"}},
+		Redirect: config.CORSPolicy{AllowedOrigins: []string{"*"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		origin  string
+		allowed string
+	}{
+		{"configured origin", http.MethodGet, "/api/v1/links", "https://app.lynk.ly", "https://app.lynk.ly"},
+		{"configured origin preflight", http.MethodOptions, "/api/v1/links", "https://app.lynk.ly", "https://app.lynk.ly"},
+		{"unknown origin", http.MethodGet, "/api/v1/links", "https://evil.example", ""},
+		{"workspace origin", http.MethodGet, "/api/v1/workspaces/workspace/domains", "https://app.workspace.example", "https://app.workspace.example"},
+		{"workspace origin preflight", http.MethodOptions, "/api/v1/workspaces/workspace/domains", "https://app.workspace.example", "https://app.workspace.example"},
+		{"custom domain", http.MethodGet, "/api/v1/workspaces/workspace/domains", "https://links.workspace.test", "https://links.workspace.test"},
+		{"custom domain over http", http.MethodGet, "/api/v1/workspaces/workspace/domains", "http://links.workspace.test", ""},
+		{"custom domain with port", http.MethodGet, "/api/v1/workspaces/workspace/domains", "https://links.workspace.test:8443", ""},
+		{"unverified custom domain", http.MethodGet, "/api/v1/workspaces/workspace/domains", "https://unverified.workspace.test", ""},
+		// the origins of a workspace are not trusted outside of its routes
+		{"workspace origin on other workspace", http.MethodGet, "/api/v1/workspaces/other/domains", "https://app.workspace.example", ""},
+		{"custom domain on other workspace", http.MethodOptions, "/api/v1/workspaces/other/domains", "https://links.workspace.test", ""},
+		{"workspace origin without workspace", http.MethodGet, "/api/v1/links", "https://app.workspace.example", ""},
+		{"custom domain without workspace", http.MethodOptions, "/api/v1/links", "https://links.workspace.test", ""},
+		{"unknown workspace", http.MethodGet, "/api/v1/workspaces/missing/domains", "https://app.workspace.example", ""},
+		{"redirect", http.MethodGet, "/api/v1/abc", "https://evil.example", "*"},
+		{"redirect preflight", http.MethodOptions, "/api/v1/abc", "https://evil.example", "*"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, nil)
+		r.Header.Set("Origin", test.origin)
+		if test.method == http.MethodOptions {
+			r.Header.Set(requestMethodHeader, http.MethodGet)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if allowed := w.Header().Get("Access-Control-Allow-Origin"); allowed != test.allowed {
+			t.Errorf("%s: expected the allowed origin %q, got %q", test.name, test.allowed, allowed)
+		}
+	}
+}
+
+func TestAddCorsOptionsRejectsCredentialsForEveryOrigin(t *testing.T) {
+	s := &UrlShortenerServer{logger: logging.NewLogger("servers_test")}
+
+	_, err := s.AddCorsOptions(mux.NewRouter(), &config.CORSConfig{
+		Redirect: config.CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+	})
+	if err == nil {
+		t.Error("Expected credentials to be rejected for every origin")
+	}
+}
+
+func TestAddSecurityHeaders(t *testing.T) {
+	s := &UrlShortenerServer{logger: logging.NewLogger("servers_test")}
+	router := newTestSecurityRouter()
+	handler := s.AddSecurityHeaders(router, router, &config.SecurityHeadersConfig{
+		HSTSMaxAge:            time.Hour,
+		HSTSIncludeSubdomains: true,
+		API: config.SecurityHeadersPolicy{
+			ContentSecurityPolicy: "default-src 'none'",
+			ReferrerPolicy:        "no-referrer",
+			FrameOptions:          "DENY",
+		},
+		Redirect: config.SecurityHeadersPolicy{
+			ContentSecurityPolicy: "style-src 'nonce-{nonce}'",
+			ReferrerPolicy:        "strict-origin-when-cross-origin",
+			PermissionsPolicy:     "camera=()",
+		},
+	})
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if method == http.MethodOptions {
+			r.Header.Set(requestMethodHeader, http.MethodGet)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected map[string]string
+	}{
+		{"API", http.MethodGet, "/api/v1/links", map[string]string{
+			referrerPolicyHeader:    "no-referrer",
+			frameOptionsHeader:      "DENY",
+			permissionsPolicyHeader: "",
+		}},
+		{"redirect", http.MethodGet, "/api/v1/abc", map[string]string{
+			referrerPolicyHeader:    "strict-origin-when-cross-origin",
+			frameOptionsHeader:      "",
+			permissionsPolicyHeader: "camera=()",
+		}},
+		{"redirect preflight", http.MethodOptions, "/api/v1/abc", map[string]string{
+			referrerPolicyHeader:    "strict-origin-when-cross-origin",
+			permissionsPolicyHeader: "camera=()",
+		}},
+		{"unknown route", http.MethodGet, "/api/v1/links/abc/unknown", map[string]string{
+			referrerPolicyHeader:        "no-referrer",
+			contentSecurityPolicyHeader: "default-src 'none'",
+		}},
+	}
+
+	for _, test := range tests {
+		header := serve(test.method, test.path).Header()
+		test.expected[lhttp.ContentTypeOptions] = lhttp.ContentTypeOptionsNoSniff
+		test.expected[strictTransportSecurityHeader] = "max-age=3600; includeSubDomains"
+		for name, value := range test.expected {
+			if header.Get(name) != value {
+				t.Errorf("%s: expected %s: %q, got %q", test.name, name, value, header.Get(name))
+			}
+		}
+	}
+
+	// JSON is not rendered by browsers
+	if csp := serve(http.MethodGet, "/api/v1/links").Header().Get(contentSecurityPolicyHeader); csp != "" {
+		t.Errorf("Expected no %s on a JSON response, got %q", contentSecurityPolicyHeader, csp)
+	}
+
+	nonces := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		w := serve(http.MethodGet, "/api/v1/abc")
+		nonce := w.Body.String()
+		if len(nonce) != 2*nonceLength || nonces[nonce] {
+			t.Fatalf("Expected a new nonce for every page, got %q after %v", nonce, nonces)
+		}
+		nonces[nonce] = true
+
+		if csp := w.Header().Get(contentSecurityPolicyHeader); csp != "style-src 'nonce-"+nonce+"'" {
+			t.Errorf("Expected the nonce of the page in the %s, got %q", contentSecurityPolicyHeader, csp)
+		}
+	}
+}
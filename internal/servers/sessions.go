@@ -0,0 +1,161 @@
+package servers
+
+import (
+	"github.com/gorilla/mux"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/sessions"
+	"net/http"
+	"time"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type sessionResponse struct {
+	*sessions.Session
+	// Current marks the session of the request
+	Current bool `json:"current"`
+}
+
+type revokedSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access token and refresh token.
+func (s *UrlShortenerServer) RefreshTokenHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.RefreshTokenHandler")
+	var request refreshRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	session, refreshToken, err := s.sessions.Refresh(request.RefreshToken)
+	if err == sessions.ErrRefreshTokenReused {
+		s.logger.WithRequest(r).Warn("Refresh token reused, revoked its session")
+		return lhttp.Unauthorized().FromTrustedError(err)
+	} else if err == sessions.ErrInvalidRefreshToken {
+		return lhttp.Unauthorized().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to refresh session: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to refresh the session")
+	}
+
+	return s.tokenResponse(r, session, refreshToken, "Failed to refresh the session")
+}
+
+// LogoutHandler revokes the session of the request together with its access token.
+func (s *UrlShortenerServer) LogoutHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.LogoutHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	principal := auth.PrincipalFrom(r)
+	if principal.SessionID != "" {
+		err := s.sessions.Revoke(principal.UserID, principal.SessionID)
+		if err != nil && err != sessions.ErrNotFound {
+			s.logger.WithRequest(r).Error("Failed to revoke session: ", err)
+			return lhttp.InternalServerError().FromTrustedMessage("Failed to log out")
+		}
+	}
+
+	if principal.TokenID != "" {
+		if err := s.sessions.RevokeAccessToken(principal.TokenID); err != nil {
+			s.logger.WithRequest(r).Error("Failed to revoke access token: ", err)
+			return lhttp.InternalServerError().FromTrustedMessage("Failed to log out")
+		}
+	}
+
+	return lhttp.NoContent()
+}
+
+func (s *UrlShortenerServer) ListSessionsHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ListSessionsHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	principal := auth.PrincipalFrom(r)
+	active, err := s.sessions.Active(principal.UserID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to list sessions: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to list sessions")
+	}
+
+	result := make([]sessionResponse, 0, len(active))
+	for _, session := range active {
+		result = append(result, sessionResponse{
+			Session: session,
+			Current: session.ID == principal.SessionID,
+		})
+	}
+
+	return lhttp.OK().WithJSON(result)
+}
+
+func (s *UrlShortenerServer) RevokeSessionHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.RevokeSessionHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	err := s.sessions.Revoke(auth.PrincipalFrom(r).UserID, mux.Vars(r)["sessionID"])
+	if err == sessions.ErrNotFound {
+		return lhttp.NotFound().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to revoke session: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to revoke the session")
+	}
+
+	return lhttp.NoContent()
+}
+
+// RevokeAllSessionsHandler logs the user out everywhere, including the session of the request.
+func (s *UrlShortenerServer) RevokeAllSessionsHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.RevokeAllSessionsHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	revoked, err := s.sessions.RevokeAll(auth.PrincipalFrom(r).UserID, "")
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to revoke sessions: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to revoke the sessions")
+	}
+
+	return lhttp.OK().WithJSON(revokedSessionsResponse{Revoked: revoked})
+}
+
+// startSession logs the user in with a new session and returns its tokens.
+func (s *UrlShortenerServer) startSession(r *http.Request, userID string, authMethods []string, failMessage string) *lhttp.HttpResponse {
+	session, refreshToken, err := s.sessions.Start(userID, r.UserAgent(), authMethods)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to start session: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage(failMessage)
+	}
+
+	return s.tokenResponse(r, session, refreshToken, failMessage)
+}
+
+func (s *UrlShortenerServer) tokenResponse(r *http.Request, session *sessions.Session, refreshToken, failMessage string) *lhttp.HttpResponse {
+	token, err := s.tokenIssuer.Issue(auth.IssueParams{
+		Subject:     session.UserID,
+		SessionID:   session.ID,
+		AuthMethods: session.AuthMethods,
+	})
+	if err == auth.ErrNoSigningKey {
+		return lhttp.Unavailable().FromTrustedMessage("Logging in is not available")
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to issue access token: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage(failMessage)
+	}
+
+	return lhttp.OK().WithJSON(accessTokenResponse{
+		AccessToken:  token.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.tokenIssuer.TTL() / time.Second),
+		RefreshToken: refreshToken,
+	})
+}
@@ -1,17 +1,57 @@
 package servers
 
 import (
+	"lynkly-backend/internal/apikeys"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/config"
 	"lynkly-backend/internal/logging"
 	"lynkly-backend/internal/routers"
+	"lynkly-backend/internal/sessions"
+	"lynkly-backend/internal/users"
+	"lynkly-backend/internal/workspaces"
 )
 
 type State struct {
 	Routers routers.RouteVersions
-	// TODO: [Zdravko Donev] Add JWT middleware
-	//Middleware *jwtmiddleware.JWTMiddleware
+	// WellKnown serves the documents under /.well-known
+	WellKnown *routers.Router
 }
 
 type ServerParams struct {
 	Logger     logging.Logger
 	ServiceUrl string
+	// Authenticator protects the management API. Redirects and link creation stay public.
+	Authenticator routers.Authenticator
+	APIKeys       *apikeys.Keys
+	Users         *users.Users
+	TokenIssuer   *auth.TokenIssuer
+	// SigningKeys is nil when the tokens are signed with an HMAC secret
+	SigningKeys *auth.KeyRing
+	Sessions    *sessions.Sessions
+	MFA         *config.MFAConfig
+	CORS        *config.CORSConfig
+	// SecurityHeaders are sent with every response
+	SecurityHeaders *config.SecurityHeadersConfig
+	// TLS is disabled when no certificate is configured
+	TLS *config.TLSConfig
+	// ACME obtains the certificates of the verified custom domains
+	ACME          *config.ACMEConfig
+	CustomDomains *config.CustomDomainsConfig
+	RateLimit     *config.RateLimitConfig
+	LiveStream    *config.LiveStreamConfig
+	Analytics     *config.AnalyticsConfig
+	Privacy       *config.PrivacyConfig
+	Links         *config.LinksConfig
+	// Destinations decides which URLs may be shortened
+	Destinations *config.DestinationsConfig
+	// Shorteners keeps links from pointing back at the service or at other URL shorteners
+	Shorteners  *config.ShortenersConfig
+	Threats     *config.ThreatsConfig
+	Moderation  *config.ModerationConfig
+	Webhooks    *config.WebhooksConfig
+	Conversions *config.ConversionsConfig
+	// Challenges gate the anonymous link creation
+	Challenges *config.ChallengesConfig
+	OIDC       *config.OIDCConfig
+	Workspaces *workspaces.Workspaces
 }
@@ -0,0 +1,59 @@
+package servers
+
+import (
+	"lynkly-backend/internal/analytics"
+	"lynkly-backend/internal/conversions"
+	"lynkly-backend/internal/models/lhttp"
+	"net/http"
+	"time"
+)
+
+const defaultStatsRange = 7 * 24 * time.Hour
+
+type statsResponse struct {
+	*analytics.Stats
+	Conversions []conversions.Summary `json:"conversions"`
+}
+
+// StatsHandler returns the clicks of a short link in the range given by the optional "from" and "to"
+// RFC 3339 query parameters, by default the last 7 days, together with the conversions attributed
+// to the link in that range.
+func (s *UrlShortenerServer) StatsHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.StatsHandler")
+
+	link, resp := s.loadLink(r, linkRead)
+	if resp != nil {
+		return resp
+	}
+
+	to, err := parseTimeParam(r, "to", time.Now().UTC())
+	if err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	from, err := parseTimeParam(r, "from", to.Add(-defaultStatsRange))
+	if err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	if from.Before(to) == false {
+		return lhttp.BadRequest().FromTrustedMessage("Parameter from must be before to")
+	}
+
+	stats, err := s.analytics.Stats(link.Code, from, to)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load stats: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load stats")
+	}
+
+	linkConversions, err := s.conversions.Conversions(link.Code, stats.From, stats.To)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load conversions: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load stats")
+	}
+
+	return lhttp.OK().WithJSON(statsResponse{
+		Stats:       stats,
+		Conversions: conversions.Summarize(linkConversions),
+	})
+}
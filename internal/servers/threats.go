@@ -0,0 +1,138 @@
+package servers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/links"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/webhooks"
+	"net/http"
+	"time"
+)
+
+var threatWarningTemplate = template.Must(template.New("warning").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Warning - this link has been disabled</title>
+<style nonce="{{.Nonce}}">
+body { font-family: sans-serif; max-width: 40em; margin: 4em auto; padding: 0 1em; line-height: 1.5; }
+</style>
+</head>
+<body>
+<h1>This link has been disabled</h1>
+<p>The destination of the short link {{.Code}} was found on the {{.Threat}} threat list and may try to
+steal your information or harm your device. The link no longer redirects to it.</p>
+</body>
+</html>
+`))
+
+// screenDestination rejects the destinations found on a threat list.
+func (s *UrlShortenerServer) screenDestination(r *http.Request, destination string) *lhttp.HttpResponse {
+	match := s.threats.Check(destination)
+	if match == nil {
+		return nil
+	}
+
+	s.logger.WithRequest(r).Warn("Rejected destination on the ", match.List, " threat list: ", match.Expression)
+	return lhttp.BadRequest().FromTrustedMessage(fmt.Sprintf("The destination is listed as %s and cannot be shortened", match.List))
+}
+
+// threatWarningPage is shown instead of redirecting to a malicious destination.
+func (s *UrlShortenerServer) threatWarningPage(r *http.Request, link *links.Link) *lhttp.HttpResponse {
+	var page bytes.Buffer
+	err := threatWarningTemplate.Execute(&page, map[string]string{
+		"Code":   link.Code,
+		"Threat": link.Threat,
+		"Nonce":  cspNonce(r),
+	})
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to render threat warning: ", err)
+		return lhttp.StatusGone().FromTrustedMessage(fmt.Sprintf("Short URL has been disabled - %s", link.Code))
+	}
+
+	return lhttp.OK().
+		WithBinary(lhttp.ContentTextHTML, page.Bytes()).
+		WithHeaders(map[string]string{"Cache-Control": "no-store"})
+}
+
+// runThreatScreening screens the destinations of the existing links again until the context is
+// cancelled, so that links are disabled once their destination is added to a threat list.
+func (s *UrlShortenerServer) runThreatScreening(ctx context.Context) {
+	ticker := time.NewTicker(s.threatsConfig.RescanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			all, err := s.links.List()
+			if err != nil {
+				s.logger.Error("Failed to load links: ", err)
+				continue
+			}
+
+			for _, link := range all {
+				if link.IsDisabled() {
+					continue
+				}
+
+				if match := s.threats.Check(link.URL); match != nil {
+					s.disableThreat(link.Code, link.URL, match.List)
+				}
+			}
+		}
+	}
+}
+
+// disableThreat disables the link unless it was disabled or pointed to another destination since its
+// destination was screened.
+func (s *UrlShortenerServer) disableThreat(code, screenedURL, list string) {
+	var before links.Link
+	now := time.Now().UTC()
+	link, err := s.links.Modify(code, func(link *links.Link) error {
+		if link.IsDisabled() || link.URL != screenedURL {
+			return errLinkChanged
+		}
+
+		before = *link
+		link.DisabledAt = &now
+		link.DisabledReason = fmt.Sprintf("The destination is listed as %s", list)
+		link.Threat = list
+		return nil
+	})
+	if err == links.ErrNotFound || err == errLinkChanged {
+		return
+	} else if err != nil {
+		s.logger.Error("Failed to disable link: ", err)
+		return
+	}
+	s.logger.Warn("Disabled link ", link.Code, " on the ", list, " threat list")
+
+	changes, err := audit.Diff(&before, link)
+	if err != nil {
+		s.logger.Error("Failed to compare link: ", err)
+	}
+	err = s.audit.Append(&audit.Entry{
+		ID:          common.NewID(),
+		ActorID:     audit.SystemActor,
+		WorkspaceID: link.WorkspaceID,
+		Action:      audit.ActionLinkDisable,
+		TargetType:  "link",
+		TargetID:    link.Code,
+		Changes:     changes,
+	})
+	if err != nil {
+		s.logger.Error("Failed to append audit entry: ", err)
+	}
+
+	if err = s.webhooks.Dispatch(link.WorkspaceID, webhooks.LinkDisabled, link); err != nil {
+		s.logger.Error("Failed to dispatch webhook: ", err)
+	}
+}
@@ -0,0 +1,69 @@
+package servers
+
+import (
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/links"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/webhooks"
+	"testing"
+	"time"
+)
+
+func TestDisableThreat(t *testing.T) {
+	logger := logging.NewLogger("servers_test")
+	s := &UrlShortenerServer{
+		logger:   logger,
+		links:    links.NewMemoryStore(),
+		audit:    audit.New(audit.Params{Logger: logger, Store: audit.NewMemoryStore()}),
+		webhooks: webhooks.New(webhooks.Params{Logger: logger, Store: webhooks.NewMemoryStore()}),
+	}
+
+	moderatedAt := time.Now().UTC().Add(-time.Minute)
+	for _, link := range []*links.Link{
+		{Code: "listed", URL: "https://phishing.example/", WorkspaceID: "workspace"},
+		// the destination was changed after the link was screened
+		{Code: "changed", URL: "https://example.com/", WorkspaceID: "workspace"},
+		// a moderator disabled the link after it was screened
+		{Code: "moderated", URL: "https://phishing.example/", WorkspaceID: "workspace", DisabledAt: &moderatedAt, DisabledReason: "Spam"},
+	} {
+		if err := s.links.Create(link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, code := range []string{"listed", "changed", "moderated", "deleted"} {
+		s.disableThreat(code, "https://phishing.example/", "phishing")
+	}
+
+	listed, err := s.links.Get("listed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listed.IsDisabled() == false || listed.Threat != "phishing" {
+		t.Errorf("Expected the listed link to be disabled, got %+v", listed)
+	}
+
+	changed, err := s.links.Get("changed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.IsDisabled() || changed.URL != "https://example.com/" {
+		t.Errorf("Expected the changed link to be kept, got %+v", changed)
+	}
+
+	moderated, err := s.links.Get("moderated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moderated.DisabledReason != "Spam" || moderated.DisabledAt.Equal(moderatedAt) == false || moderated.Threat != "" {
+		t.Errorf("Expected the moderation of the link to be kept, got %+v", moderated)
+	}
+
+	entries, err := s.audit.Store().List(audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].TargetID != "listed" || entries[0].ActorID != audit.SystemActor {
+		t.Errorf("Expected the disable of the listed link to be audited only, got %v", entries)
+	}
+}
@@ -0,0 +1,43 @@
+package servers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// runHTTPSRedirect serves the plain HTTP listener, which only redirects to the same URL over HTTPS and
+// answers the HTTP-01 challenges of ACME.
+func (s *UrlShortenerServer) runHTTPSRedirect() {
+	s.logger.Info("Redirecting plain HTTP to HTTPS", "address: "+s.tlsConfig.HTTPRedirectAddr)
+	handler := http.Handler(http.HandlerFunc(s.redirectToHTTPS))
+	if s.acme != nil {
+		handler = s.acme.HTTPHandler(handler)
+	}
+
+	err := http.ListenAndServe(s.tlsConfig.HTTPRedirectAddr, handler)
+	if err != nil {
+		s.logger.Error("Failed to serve the HTTP to HTTPS redirects: ", err)
+	}
+}
+
+func (s *UrlShortenerServer) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.Trim(host, "[]")
+	if host == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	// the HTTPS port is left out when it is the default one
+	if _, port, err := net.SplitHostPort(s.hostPort); err == nil && port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	// 308 keeps the method and the body of the request
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+}
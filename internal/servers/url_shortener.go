@@ -1,66 +1,443 @@
 package servers
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
-	"github.com/rs/cors"
+	"lynkly-backend/internal/analytics"
+	"lynkly-backend/internal/apikeys"
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/certs"
+	"lynkly-backend/internal/challenges"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/config"
+	"lynkly-backend/internal/conversions"
+	"lynkly-backend/internal/destinations"
+	"lynkly-backend/internal/domains"
+	"lynkly-backend/internal/events"
+	"lynkly-backend/internal/links"
 	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/mfa"
 	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/moderation"
+	"lynkly-backend/internal/oidc"
+	"lynkly-backend/internal/privacy"
+	"lynkly-backend/internal/ratelimit"
 	"lynkly-backend/internal/routers"
+	"lynkly-backend/internal/sessions"
+	"lynkly-backend/internal/shorteners"
+	"lynkly-backend/internal/threats"
+	"lynkly-backend/internal/users"
+	"lynkly-backend/internal/webhooks"
+	"lynkly-backend/internal/workspaces"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"time"
 )
 
-// TODO: [Zdravko Donev] Remove this after we get the DB
-var shortURLs map[string]string
+const (
+	// maxCodeAttempts is the number of codes tried before giving up on creating a link
+	maxCodeAttempts = 5
+	// redirectPath is the public redirect route, which has its own CORS policy
+	redirectPath = "/{shortURL}"
+)
 
 type UrlShortenerServer struct {
-	hostPort   string
-	handler    http.Handler
-	logger     logging.Logger
-	serviceUrl string
+	hostPort string
+	handler  http.Handler
+	// serverTLS is nil when the server is served over plain HTTP
+	serverTLS *tls.Config
+	// certificates is nil when no certificate is configured for the service
+	certificates *certs.Certificates
+	// acme is nil when the certificates of the custom domains are not obtained from ACME
+	acme             *certs.ACME
+	tlsConfig        *config.TLSConfig
+	logger           logging.Logger
+	serviceUrl       string
+	liveStreamConfig *config.LiveStreamConfig
+	hub              *events.Hub
+	analytics        *analytics.Analytics
+	privacy          *privacy.Policy
+	links            links.Store
+	linksConfig      *config.LinksConfig
+	destinations     *destinations.Policy
+	shorteners       *shorteners.Resolver
+	threats          *threats.Screener
+	// challenges is nil when anonymous clients create links without a challenge
+	challenges       challenges.Verifier
+	threatsConfig    *config.ThreatsConfig
+	moderation       *moderation.Moderation
+	webhooks         *webhooks.Dispatcher
+	conversions      conversions.Store
+	conversionConfig *config.ConversionsConfig
+	clickIDs         *conversions.ClickIDSigner
+	apiKeys          *apikeys.Keys
+	users            *users.Users
+	tokenIssuer      *auth.TokenIssuer
+	signingKeys      *auth.KeyRing
+	sessions         *sessions.Sessions
+	mfa              *mfa.MFA
+	// oidc is nil when single sign-on is not configured
+	oidc       *oidc.Provider
+	oidcConfig *config.OIDCConfig
+	workspaces *workspaces.Workspaces
+	domains    *domains.Domains
+	audit      *audit.Log
 }
 
 func NewUrlShortenerServer(port string, serverParams ServerParams) *UrlShortenerServer {
-	shortURLs = make(map[string]string)
+	clickIDSecret := serverParams.Conversions.Secret
+	if clickIDSecret == "" {
+		serverParams.Logger.Warn("CONVERSIONS_SECRET is not set, click IDs will not be valid after a restart")
+		clickIDSecret = common.RandomHex(32)
+	}
+
+	urlShortenerServer := &UrlShortenerServer{
+		hostPort:         port,
+		tlsConfig:        serverParams.TLS,
+		logger:           serverParams.Logger,
+		serviceUrl:       serverParams.ServiceUrl,
+		liveStreamConfig: serverParams.LiveStream,
+		hub: events.NewHub(events.HubParams{
+			ReplayBufferSize:     serverParams.LiveStream.ReplayBufferSize,
+			SubscriberBufferSize: serverParams.LiveStream.SubscriberBufferSize,
+			ReplayWindow:         serverParams.LiveStream.ReplayWindow,
+		}),
+		analytics: analytics.New(analytics.Params{
+			Logger:         serverParams.Logger,
+			Store:          analytics.NewMemoryStore(),
+			RollupInterval: serverParams.Analytics.RollupInterval,
+			Retention: map[analytics.Granularity]time.Duration{
+				analytics.Raw:    serverParams.Analytics.RawRetention,
+				analytics.Hourly: serverParams.Analytics.HourlyRetention,
+				analytics.Daily:  serverParams.Analytics.DailyRetention,
+			},
+		}),
+		privacy: privacy.New(privacy.Params{
+			IPMode:       privacy.IPMode(serverParams.Privacy.IPMode),
+			SaltRotation: serverParams.Privacy.SaltRotation,
+			Settings:     privacy.NewMemorySettingsStore(),
+		}),
+		links:         links.NewMemoryStore(),
+		linksConfig:   serverParams.Links,
+		threatsConfig: serverParams.Threats,
+		destinations: destinations.New(destinations.Params{
+			AllowedSchemes:       serverParams.Destinations.AllowedSchemes,
+			AllowedDomains:       serverParams.Destinations.AllowedDomains,
+			DeniedDomains:        serverParams.Destinations.DeniedDomains,
+			MaxLength:            serverParams.Destinations.MaxURLLength,
+			AllowPrivateNetworks: serverParams.Destinations.AllowPrivateNetworks,
+		}),
+		moderation: moderation.New(moderation.Params{
+			Store:        moderation.NewMemoryStore(),
+			ModeratorIDs: serverParams.Moderation.ModeratorIDs,
+		}),
+		webhooks: webhooks.New(webhooks.Params{
+			Logger:               serverParams.Logger,
+			Store:                webhooks.NewMemoryStore(),
+			MaxAttempts:          serverParams.Webhooks.MaxAttempts,
+			InitialBackoff:       serverParams.Webhooks.InitialBackoff,
+			MaxBackoff:           serverParams.Webhooks.MaxBackoff,
+			Workers:              serverParams.Webhooks.Workers,
+			Retention:            serverParams.Webhooks.DeliveryRetention,
+			AllowPrivateNetworks: serverParams.Webhooks.AllowPrivateNetworks,
+		}),
+		conversions:      conversions.NewMemoryStore(),
+		conversionConfig: serverParams.Conversions,
+		clickIDs:         conversions.NewClickIDSigner([]byte(clickIDSecret), serverParams.Conversions.AttributionWindow),
+		apiKeys:          serverParams.APIKeys,
+		users:            serverParams.Users,
+		tokenIssuer:      serverParams.TokenIssuer,
+		signingKeys:      serverParams.SigningKeys,
+		sessions:         serverParams.Sessions,
+		mfa: mfa.New(mfa.Params{
+			Store:        mfa.NewMemoryStore(),
+			Challenges:   mfa.NewMemoryChallengeStore(),
+			Issuer:       serverParams.MFA.Issuer,
+			ChallengeTTL: serverParams.MFA.LoginTTL,
+		}),
+		oidcConfig: serverParams.OIDC,
+		workspaces: serverParams.Workspaces,
+		audit: audit.New(audit.Params{
+			Logger: serverParams.Logger,
+			Store:  audit.NewMemoryStore(),
+		}),
+	}
+
+	if serverParams.OIDC.IssuerURL != "" {
+		urlShortenerServer.oidc = oidc.New(oidc.Params{
+			IssuerURL:    serverParams.OIDC.IssuerURL,
+			ClientID:     serverParams.OIDC.ClientID,
+			ClientSecret: serverParams.OIDC.ClientSecret,
+			RedirectURL:  serverParams.OIDC.RedirectURL,
+			Scopes:       serverParams.OIDC.Scopes,
+			GroupsClaim:  serverParams.OIDC.GroupsClaim,
+			LoginTTL:     serverParams.OIDC.LoginTTL,
+			Logins:       oidc.NewMemoryLoginStore(),
+		})
+		for group, role := range serverParams.OIDC.RoleMapping {
+			if auth.Role(role).IsValid() == false {
+				serverParams.Logger.Warn("OIDC_ROLE_MAPPING maps group ", group, " to the unknown role ", role)
+			}
+		}
+
+		// the users of the identity provider share a workspace, which has no owner until one is assigned
+		_, err := serverParams.Workspaces.Store().Workspace(serverParams.OIDC.WorkspaceID)
+		if err == workspaces.ErrNotFound {
+			_, err = serverParams.Workspaces.CreateWithID(serverParams.OIDC.WorkspaceID, serverParams.OIDC.WorkspaceName, "")
+		}
+		if err != nil {
+			serverParams.Logger.Panic("Error encountered on creating the single sign-on workspace", "error", err)
+		}
+	}
+
+	// links to the service URL would redirect back to the service
+	ownDomains := serverParams.Shorteners.OwnDomains
+	if serviceURL, err := url.Parse(serverParams.ServiceUrl); err == nil && serviceURL.Hostname() != "" {
+		ownDomains = append([]string{serviceURL.Hostname()}, ownDomains...)
+	}
+	urlShortenerServer.shorteners = shorteners.New(shorteners.Params{
+		OwnDomains: ownDomains,
+		Domains:    serverParams.Shorteners.Domains,
+		Resolve:    serverParams.Shorteners.ResolveChains,
+		MaxHops:    serverParams.Shorteners.MaxHops,
+		Check:      urlShortenerServer.destinations.Check,
+		HTTPClient: &http.Client{Timeout: serverParams.Shorteners.ResolveTimeout},
+	})
+
+	screener, err := threats.New(threats.Params{
+		Logger:          serverParams.Logger,
+		DomainLists:     serverParams.Threats.DomainLists,
+		HashPrefixLists: serverParams.Threats.HashPrefixLists,
+		ReloadInterval:  serverParams.Threats.ReloadInterval,
+	})
+	if err != nil {
+		serverParams.Logger.Panic("Error encountered on loading the threat lists", "error", err)
+	}
+	urlShortenerServer.threats = screener
+
+	if serverParams.Challenges.Mode == challenges.TypeProofOfWork {
+		challengeSecret := serverParams.Challenges.Secret
+		if challengeSecret == "" {
+			serverParams.Logger.Warn("CHALLENGE_SECRET is not set, challenges will not be valid after a restart")
+			challengeSecret = common.RandomHex(32)
+		}
+		urlShortenerServer.challenges = challenges.NewProofOfWork(challenges.ProofOfWorkParams{
+			Secret:     []byte(challengeSecret),
+			Difficulty: serverParams.Challenges.PoWDifficulty,
+			TTL:        serverParams.Challenges.PoWTTL,
+			Spent:      challenges.NewMemorySpentStore(),
+		})
+	} else if serverParams.Challenges.Mode == challenges.TypeCaptcha {
+		if serverParams.Challenges.CaptchaSecret == "" {
+			serverParams.Logger.Panic("Error encountered on configuring the challenges", "error", errors.New("CHALLENGE_CAPTCHA_SECRET is not set"))
+		}
+		urlShortenerServer.challenges = challenges.NewCaptcha(challenges.CaptchaParams{
+			VerifyURL:  serverParams.Challenges.CaptchaVerifyURL,
+			Secret:     serverParams.Challenges.CaptchaSecret,
+			SiteKey:    serverParams.Challenges.CaptchaSiteKey,
+			HTTPClient: &http.Client{Timeout: serverParams.Challenges.CaptchaTimeout},
+		})
+	} else if serverParams.Challenges.Mode != "" {
+		serverParams.Logger.Panic("Error encountered on configuring the challenges", "error",
+			fmt.Errorf("unknown CHALLENGE_MODE %s - expected pow or captcha", serverParams.Challenges.Mode))
+	}
+
+	urlShortenerServer.domains = domains.New(domains.Params{
+		Store:      domains.NewMemoryStore(),
+		Resolver:   domains.NewResolver(serverParams.CustomDomains.DNSServer),
+		OwnDomains: ownDomains,
+	})
+
+	if serverParams.TLS.CertFile != "" {
+		urlShortenerServer.certificates, err = certs.New(certs.Params{
+			Logger:         serverParams.Logger,
+			CertFile:       serverParams.TLS.CertFile,
+			KeyFile:        serverParams.TLS.KeyFile,
+			ReloadInterval: serverParams.TLS.ReloadInterval,
+		})
+		if err != nil {
+			serverParams.Logger.Panic("Error encountered on loading the TLS certificate", "error", err)
+		}
+	}
+	if serverParams.ACME.Enabled {
+		urlShortenerServer.acme, err = certs.NewACME(certs.ACMEParams{
+			Logger:       serverParams.Logger,
+			DirectoryURL: serverParams.ACME.DirectoryURL,
+			Email:        serverParams.ACME.Email,
+			CARootsFile:  serverParams.ACME.CARootsFile,
+			RenewBefore:  serverParams.ACME.RenewBefore,
+			HostPolicy:   urlShortenerServer.domains.HostPolicy,
+			Store:        certs.NewMemoryStore(),
+		})
+		if err != nil {
+			serverParams.Logger.Panic("Error encountered on configuring ACME", "error", err)
+		}
+	}
+	if urlShortenerServer.certificates != nil || urlShortenerServer.acme != nil {
+		urlShortenerServer.serverTLS, err = certs.NewServerConfig(certs.ServerParams{
+			MinVersion:   serverParams.TLS.MinVersion,
+			CipherSuites: serverParams.TLS.CipherSuites,
+			Certificates: urlShortenerServer.certificates,
+			ACME:         urlShortenerServer.acme,
+		})
+		if err != nil {
+			serverParams.Logger.Panic("Error encountered on configuring TLS", "error", err)
+		}
+	}
+
 	muxRouter := mux.NewRouter().StrictSlash(false)
 	state := &State{
 		Routers: routers.RouteVersions{
 			V1: routers.NewRouter(muxRouter.PathPrefix(routers.PathAPIV1).Subrouter(), &routers.RouterParams{
-				Logger: serverParams.Logger,
-			}),
+				Logger:        serverParams.Logger,
+				Authenticator: serverParams.Authenticator,
+				Tenants:       urlShortenerServer,
+				Auditor:       urlShortenerServer.audit,
+				RateLimiter: ratelimit.New(ratelimit.Params{
+					Store: ratelimit.NewMemoryStore(),
+					Policies: map[string]ratelimit.Policy{
+						ratelimit.PolicyAPI: {
+							Requests: serverParams.RateLimit.APIRequests,
+							Period:   serverParams.RateLimit.APIPeriod,
+						},
+						ratelimit.PolicyRedirect: {
+							Requests: serverParams.RateLimit.RedirectRequests,
+							Period:   serverParams.RateLimit.RedirectPeriod,
+						},
+						ratelimit.PolicyAuthFailures: {
+							Requests: serverParams.RateLimit.AuthFailures,
+							Period:   serverParams.RateLimit.AuthFailurePeriod,
+						},
+					},
+				}),
+			}, routers.RateLimit(ratelimit.PolicyAPI)),
 		},
+		WellKnown: routers.NewRouter(muxRouter.PathPrefix(routers.PathWellKnown).Subrouter(), &routers.RouterParams{
+			Logger: serverParams.Logger,
+		}),
 	}
 
-	corsHandler := AddCorsOptions(muxRouter, cors.Options{
-		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch, http.MethodOptions},
-		AllowedHeaders: []string{"*"},
-	})
+	urlShortenerServer.registerApiHandlers(state)
 
-	urlShortenerServer := &UrlShortenerServer{
-		hostPort:   port,
-		handler:    corsHandler,
-		logger:     serverParams.Logger,
-		serviceUrl: serverParams.ServiceUrl,
+	handler, err := urlShortenerServer.AddCorsOptions(muxRouter, serverParams.CORS)
+	if err != nil {
+		serverParams.Logger.Panic("Error encountered on configuring CORS", "error", err)
 	}
-
-	urlShortenerServer.registerApiHandlers(state)
+	urlShortenerServer.handler = urlShortenerServer.AddSecurityHeaders(muxRouter, handler, serverParams.SecurityHeaders)
 
 	return urlShortenerServer
 }
 
 func (s *UrlShortenerServer) Run() error {
 	s.logger.Info("Starting url shortener API", "port: "+s.hostPort)
-	return http.ListenAndServe(s.hostPort, s.handler)
+	go s.analytics.RunRollups(context.Background())
+	go s.webhooks.Run(context.Background())
+	go s.runLinkExpiry(context.Background())
+	if s.signingKeys != nil {
+		go s.signingKeys.Run(context.Background())
+	}
+	if s.threats.IsEnabled() {
+		go s.threats.Run(context.Background())
+		go s.runThreatScreening(context.Background())
+	}
+	if s.serverTLS == nil {
+		return http.ListenAndServe(s.hostPort, s.handler)
+	}
+
+	if s.certificates != nil {
+		go s.certificates.Run(context.Background())
+	}
+	if s.tlsConfig.HTTPRedirectAddr != "" {
+		go s.runHTTPSRedirect()
+	}
+	server := &http.Server{
+		Addr:      s.hostPort,
+		Handler:   s.handler,
+		TLSConfig: s.serverTLS,
+	}
+	// the certificates are served by the TLS config, which reloads and renews them
+	return server.ListenAndServeTLS("", "")
 }
 
 func (s *UrlShortenerServer) registerApiHandlers(state *State) {
-	state.Routers.V1.HandleFunc(http.MethodGet, "/{shortURL}", s.RedirectHandler)
-	state.Routers.V1.HandleFunc(http.MethodPost, "/shorten", s.ShortenHandler)
+	state.WellKnown.HandleFunc(http.MethodGet, "/jwks.json", s.JWKSHandler)
+
+	v1 := state.Routers.V1
+	v1.HandleFunc(http.MethodPost, "/shorten", s.ShortenHandler, routers.AllowAuth())
+	v1.HandleFunc(http.MethodGet, "/shorten/challenge", s.ChallengeHandler)
+	v1.HandleFunc(http.MethodPost, "/conversions", s.CreateConversionHandler)
+	v1.HandleFunc(http.MethodGet, "/conversions/pixel.gif", s.ConversionPixelHandler)
+	v1.HandleFunc(http.MethodPost, "/reports", s.CreateReportHandler)
+	v1.HandleFunc(http.MethodPost, "/users", s.SignupHandler)
+	v1.HandleFunc(http.MethodPost, "/auth/login", s.LoginHandler)
+	v1.HandleFunc(http.MethodPost, "/auth/login/2fa", s.CompleteTwoFactorLoginHandler)
+	v1.HandleFunc(http.MethodPost, "/auth/refresh", s.RefreshTokenHandler)
+	v1.HandleFunc(http.MethodPost, "/auth/logout", s.LogoutHandler, routers.RequireAuth())
+	if s.oidc != nil {
+		v1.HandleFunc(http.MethodGet, "/auth/oidc/login", s.OIDCLoginHandler)
+		v1.HandleFunc(http.MethodGet, "/auth/oidc/callback", s.OIDCCallbackHandler)
+	}
+
+	// management API
+	v1.HandleFunc(http.MethodGet, "/links/{code}", s.GetLinkHandler, routers.RequireScope(auth.ScopeLinksRead))
+	v1.HandleFunc(http.MethodPut, "/links/{code}", s.UpdateLinkHandler, routers.RequireScope(auth.ScopeLinksWrite))
+	v1.HandleFunc(http.MethodDelete, "/links/{code}", s.DeleteLinkHandler, routers.RequireScope(auth.ScopeLinksWrite))
+	v1.HandleStream(http.MethodGet, "/links/{code}/live", s.LiveClicksHandler, routers.RequireScope(auth.ScopeStatsRead))
+	v1.HandleFunc(http.MethodGet, "/links/{code}/stats", s.StatsHandler, routers.RequireScope(auth.ScopeStatsRead))
+	v1.HandleFunc(http.MethodGet, "/users/me", s.GetCurrentUserHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodPut, "/users/me/password", s.ChangePasswordHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodDelete, "/users/me", s.DeleteCurrentUserHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodGet, "/users/me/sessions", s.ListSessionsHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodDelete, "/users/me/sessions", s.RevokeAllSessionsHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodDelete, "/users/me/sessions/{sessionID}", s.RevokeSessionHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodPost, "/users/me/2fa", s.BeginTwoFactorHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodPost, "/users/me/2fa/confirm", s.ConfirmTwoFactorHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodPost, "/users/me/2fa/recovery-codes", s.RegenerateRecoveryCodesHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodDelete, "/users/me/2fa", s.DisableTwoFactorHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodGet, "/moderation/reports", s.ListReportsHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodGet, "/moderation/reports/{reportID}", s.GetReportHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodPost, "/moderation/reports/{reportID}/dismiss", s.DismissReportHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodPost, "/moderation/links/{code}", s.ModerateLinkHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodGet, "/moderation/links/{code}/history", s.LinkModerationHistoryHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodPost, "/invitations/accept", s.AcceptInvitationHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodGet, "/audit", s.ListAuditEntriesHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleStream(http.MethodGet, "/audit/export", s.ExportAuditEntriesHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodPost, "/api-keys", s.CreateAPIKeyHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodGet, "/api-keys", s.ListAPIKeysHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodDelete, "/api-keys/{keyID}", s.RevokeAPIKeyHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodPost, "/workspaces", s.CreateWorkspaceHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodGet, "/workspaces", s.ListWorkspacesHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodGet, "/workspaces/{workspaceID}", s.GetWorkspaceHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodGet, "/workspaces/{workspaceID}/members", s.ListMembersHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodPut, "/workspaces/{workspaceID}/members/{userID}", s.UpdateMemberHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodDelete, "/workspaces/{workspaceID}/members/{userID}", s.RemoveMemberHandler, routers.RequireAuth())
+	v1.HandleFunc(http.MethodPost, "/workspaces/{workspaceID}/invitations", s.CreateInvitationHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodGet, "/workspaces/{workspaceID}/invitations", s.ListInvitationsHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodDelete, "/workspaces/{workspaceID}/invitations/{invitationID}", s.DeleteInvitationHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodGet, "/workspaces/{workspaceID}/privacy", s.GetPrivacySettingsHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodPut, "/workspaces/{workspaceID}/policy", s.UpdateWorkspacePolicyHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodPut, "/workspaces/{workspaceID}/cors", s.UpdateWorkspaceCORSHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodPost, "/workspaces/{workspaceID}/domains", s.CreateDomainHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodGet, "/workspaces/{workspaceID}/domains", s.ListDomainsHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodPost, "/workspaces/{workspaceID}/domains/{domain}/verify", s.VerifyDomainHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodDelete, "/workspaces/{workspaceID}/domains/{domain}", s.DeleteDomainHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodPut, "/workspaces/{workspaceID}/privacy", s.UpdatePrivacySettingsHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodPost, "/workspaces/{workspaceID}/webhooks", s.CreateWebhookHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodGet, "/workspaces/{workspaceID}/webhooks", s.ListWebhooksHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodGet, "/workspaces/{workspaceID}/webhooks/dead-letters", s.ListDeadLettersHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodDelete, "/workspaces/{workspaceID}/webhooks/{webhookID}", s.DeleteWebhookHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodGet, "/workspaces/{workspaceID}/webhooks/{webhookID}/deliveries", s.ListWebhookDeliveriesHandler, routers.RequireScope(auth.ScopeAccount))
+	v1.HandleFunc(http.MethodPost, "/workspaces/{workspaceID}/webhooks/{webhookID}/deliveries/{deliveryID}/retry", s.RetryWebhookDeliveryHandler, routers.RequireScope(auth.ScopeAccount))
+
+	// the redirect matches any single path segment, so it has to be registered last
+	v1.HandleFunc(http.MethodGet, redirectPath, s.RedirectHandler, routers.RateLimit(ratelimit.PolicyRedirect))
 }
 
 func (s *UrlShortenerServer) RedirectHandler(r *http.Request) *lhttp.HttpResponse {
@@ -72,16 +449,60 @@ func (s *UrlShortenerServer) RedirectHandler(r *http.Request) *lhttp.HttpRespons
 	}
 	s.logger.Debug("Short URL found in request: ", shortURL)
 
-	for key, value := range shortURLs {
-		s.logger.Debug("shortURLs: ", key, " - ", value)
-	}
-	longURL, ok := shortURLs[shortURL]
-	if !ok {
+	link, err := s.links.Get(shortURL)
+	if err == links.ErrNotFound {
 		return lhttp.NotFound().FromTrustedMessage(fmt.Sprintf("Short URL not found - %s", shortURL))
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load link: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load short URL")
+	}
+	s.logger.Debug("Long URL found for short URL: ", link.URL)
+
+	if link.IsExpired(time.Now()) {
+		return lhttp.StatusGone().FromTrustedMessage(fmt.Sprintf("Short URL has expired - %s", shortURL))
+	}
+
+	if link.IsDisabled() {
+		if link.Threat != "" {
+			return s.threatWarningPage(r, link)
+		}
+		return lhttp.StatusGone().FromTrustedMessage(fmt.Sprintf("Short URL has been disabled - %s", shortURL))
+	}
+
+	if link.IsQuarantined() && r.URL.Query().Get(proceedParam) != "1" {
+		return s.quarantineWarningPage(r, link)
+	}
+
+	destination := link.URL
+	clickID := ""
+	// visitors who opted out of tracking do not get a click ID which would follow them to the destination
+	if s.conversionConfig.AppendClickID && privacy.OptedOut(r) == false {
+		clickID = s.clickIDs.Issue(link.Code, time.Now())
+		destination = appendQueryParam(link.URL, s.conversionConfig.ClickIDParam, clickID)
 	}
-	s.logger.Debug("Long URL found for short URL: ", longURL)
 
-	return lhttp.Redirect().Temporary(longURL)
+	s.recordClick(r, link, clickID)
+
+	return lhttp.Redirect().Temporary(destination)
+}
+
+// recordClick stores the click and publishes it to the live click stream and the webhooks. The full
+// client IP never leaves this function, it is anonymized or dropped by the privacy policy first.
+func (s *UrlShortenerServer) recordClick(r *http.Request, link *links.Link, clickID string) {
+	click := analytics.NewClick(link.Code, r)
+	click.ClickID = clickID
+
+	click, err := s.privacy.Apply(click, link.WorkspaceID, r)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to apply privacy policy to click: ", err)
+		return
+	}
+
+	if err = s.analytics.Record(click); err != nil {
+		s.logger.WithRequest(r).Error("Failed to record click: ", err)
+	}
+	s.hub.Publish(analytics.ClickTopic(link.Code), analytics.ClickEventType, click)
+	s.dispatchWebhook(r, link.WorkspaceID, webhooks.LinkClicked, click)
 }
 
 func (s *UrlShortenerServer) ShortenHandler(r *http.Request) *lhttp.HttpResponse {
@@ -91,17 +512,56 @@ func (s *UrlShortenerServer) ShortenHandler(r *http.Request) *lhttp.HttpResponse
 	if longURL == "" {
 		return lhttp.BadRequest().FromTrustedMessage("Missing URL parameter")
 	}
-	// Check if longURL is a valid URL
-	if parsedUrl, err := url.Parse(longURL); err != nil {
-		return lhttp.BadRequest().FromTrustedMessage("Invalid URL - " + longURL + " - " + err.Error())
-	} else if parsedUrl.Scheme == "" || parsedUrl.Host == "" {
-		return lhttp.BadRequest().FromTrustedMessage("Invalid URL - " + longURL + " - " + "URL is missing scheme or host")
+	// anonymous clients prove that they are not bots before the destination is checked
+	principal := auth.PrincipalFrom(r)
+	if principal == nil {
+		if resp := s.checkChallenge(r); resp != nil {
+			return resp
+		}
+	}
+	longURL, resp := s.checkDestination(r, longURL)
+	if resp != nil {
+		return resp
+	}
+
+	expiresAt, err := parseExpiresAt(r.FormValue("expiresAt"))
+	if err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	// authenticated users create the link in their workspace, anonymous links belong to no workspace
+	workspaceID := links.DefaultWorkspaceID
+	if principal != nil {
+		if principal.HasScope(auth.ScopeLinksWrite) == false || principal.WorkspaceID == "" {
+			return lhttp.Forbidden().FromTrustedMessage("Not allowed to create short URLs in the workspace")
+		}
+		workspaceID = principal.WorkspaceID
+	}
+
+	now := time.Now().UTC()
+	link := &links.Link{
+		URL:         longURL,
+		WorkspaceID: workspaceID,
+		CreatedBy:   principalUserID(r),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ExpiresAt:   expiresAt,
+	}
+	for attempt := 0; attempt < maxCodeAttempts; attempt++ {
+		link.Code = s.GenerateShortURL()
+		if err = s.links.Create(link); err != links.ErrCodeExists {
+			break
+		}
+	}
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to store link: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to create short URL")
 	}
+	shortURL := s.serviceUrl + routers.PathAPIV1 + "/" + link.Code
 
-	shortCode := s.GenerateShortURL()
-	// TODO: Store the shortURL and longURL in a database
-	shortURLs[shortCode] = longURL
-	shortURL := s.serviceUrl + routers.PathAPIV1 + "/" + shortCode
+	audit.Describe(r, audit.ActionLinkCreate, audit.Target{Type: "link", ID: link.Code})
+	audit.RecordChange(r, nil, link)
+	s.dispatchWebhook(r, link.WorkspaceID, webhooks.LinkCreated, link)
 
 	s.logger.Info("Shortened URL: " + shortURL)
 	return lhttp.OK().WithJSON(map[string]string{
@@ -112,7 +572,8 @@ func (s *UrlShortenerServer) ShortenHandler(r *http.Request) *lhttp.HttpResponse
 func (s *UrlShortenerServer) GenerateShortURL() string {
 	// Generate a short URL logic here (you can use hashing algorithms or any other method)
 	// For simplicity, we'll just take the first 6 characters of the URL
-	shortCode := base64.StdEncoding.EncodeToString(Int63ToByteArray(rand.Uint64()))
+	// URL safe alphabet without padding, "/" and "+" would break the routes addressing links by code
+	shortCode := base64.RawURLEncoding.EncodeToString(Int63ToByteArray(rand.Uint64()))
 	s.logger.Debug("Generated short code: ", shortCode)
 
 	return shortCode
@@ -0,0 +1,266 @@
+package servers
+
+import (
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/mfa"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/users"
+	"lynkly-backend/internal/workspaces"
+	"net/http"
+)
+
+const personalWorkspaceName = "Personal"
+
+type credentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password"`
+	// Code is a TOTP or recovery code, required when two-factor authentication is enabled
+	Code string `json:"code"`
+}
+
+type accessTokenResponse struct {
+	AccessToken string `json:"accessToken"`
+	TokenType   string `json:"tokenType"`
+	// ExpiresIn is the lifetime of the token in seconds
+	ExpiresIn    int64  `json:"expiresIn"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+func (s *UrlShortenerServer) SignupHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.SignupHandler")
+	var request credentialsRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	user, err := s.users.Register(request.Email, request.Password)
+	if err == users.ErrInvalidEmail || err == users.ErrInvalidPassword {
+		return lhttp.BadRequest().FromTrustedError(err)
+	} else if err == users.ErrEmailTaken {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to register user: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to register user")
+	}
+
+	if _, err = s.workspaces.Create(personalWorkspaceName, user.ID); err != nil {
+		s.logger.WithRequest(r).Error("Failed to create personal workspace: ", err)
+	}
+
+	return lhttp.Created().WithJSON(user)
+}
+
+func (s *UrlShortenerServer) LoginHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.LoginHandler")
+	var request credentialsRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	user, err := s.users.Authenticate(request.Email, request.Password)
+	if err == users.ErrInvalidCredentials {
+		return lhttp.Unauthorized().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to authenticate user: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to log in")
+	}
+
+	return s.login(r, user.ID, auth.AuthMethodPassword, "Failed to log in")
+}
+
+func (s *UrlShortenerServer) GetCurrentUserHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.GetCurrentUserHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	user, err := s.users.Store().Get(auth.PrincipalFrom(r).UserID)
+	if err == users.ErrNotFound {
+		return lhttp.NotFound().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load user: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load user")
+	}
+
+	return lhttp.OK().WithJSON(user)
+}
+
+func (s *UrlShortenerServer) ChangePasswordHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ChangePasswordHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	var request changePasswordRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	principal := auth.PrincipalFrom(r)
+	err := s.users.ChangePassword(principal.UserID, request.CurrentPassword, request.NewPassword)
+	if err == users.ErrNotFound {
+		return lhttp.NotFound().FromTrustedError(err)
+	} else if err == users.ErrInvalidCredentials {
+		return lhttp.Forbidden().FromTrustedMessage("Current password is incorrect")
+	} else if err == users.ErrInvalidPassword {
+		return lhttp.BadRequest().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to change password: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to change password")
+	}
+
+	// a changed password logs out the other sessions, which may have been taken over
+	if _, err = s.sessions.RevokeAll(principal.UserID, principal.SessionID); err != nil {
+		s.logger.WithRequest(r).Error("Failed to revoke sessions after changing password: ", err)
+	}
+
+	return lhttp.NoContent()
+}
+
+// DeleteCurrentUserHandler deletes the account of the user, removes it from its workspaces and
+// revokes its sessions, two-factor authentication and API keys. The user confirms the deletion with
+// the password of the account and a two-factor code when it is enabled. The workspaces the user is
+// the only member of are deleted with their links, and the last owner of a workspace with other
+// members has to hand the workspace over first.
+func (s *UrlShortenerServer) DeleteCurrentUserHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.DeleteCurrentUserHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	var request deleteAccountRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	user, err := s.users.Store().Get(auth.PrincipalFrom(r).UserID)
+	if err == users.ErrNotFound {
+		return lhttp.NotFound().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load user: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to delete user")
+	}
+
+	userID := user.ID
+	memberships, err := s.workspaces.Store().Memberships(userID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load memberships: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to delete user")
+	}
+
+	// every workspace is checked before anything is removed, so that the account is not left half deleted
+	var ownWorkspaces []string
+	for _, member := range memberships {
+		members, err := s.workspaces.Store().Members(member.WorkspaceID)
+		if err != nil {
+			s.logger.WithRequest(r).Error("Failed to load members: ", err)
+			return lhttp.InternalServerError().FromTrustedMessage("Failed to delete user")
+		}
+		if len(members) == 1 {
+			// nobody else can manage the workspace, so it is deleted with the account
+			ownWorkspaces = append(ownWorkspaces, member.WorkspaceID)
+			continue
+		}
+
+		err = s.workspaces.CheckRemoveMember(member.WorkspaceID, userID)
+		if err == workspaces.ErrLastOwner {
+			return lhttp.Conflict().FromTrustedMessage("Transfer the ownership of workspace " + member.WorkspaceID + " first")
+		} else if err != nil && err != workspaces.ErrMemberNotFound {
+			s.logger.WithRequest(r).Error("Failed to check membership: ", err)
+			return lhttp.InternalServerError().FromTrustedMessage("Failed to delete user")
+		}
+	}
+
+	if resp := s.confirmIdentity(r, user, request.Password, request.Code); resp != nil {
+		return resp
+	}
+
+	for _, workspaceID := range ownWorkspaces {
+		if err = s.deleteWorkspace(r, workspaceID); err != nil {
+			s.logger.WithRequest(r).Error("Failed to delete workspace of deleted user: ", err)
+			return lhttp.InternalServerError().FromTrustedMessage("Failed to delete user")
+		}
+	}
+
+	for _, member := range memberships {
+		if err = s.workspaces.Store().DeleteMember(member.WorkspaceID, userID); err != nil && err != workspaces.ErrMemberNotFound {
+			s.logger.WithRequest(r).Error("Failed to remove user from workspace: ", err)
+			return lhttp.InternalServerError().FromTrustedMessage("Failed to delete user")
+		}
+	}
+
+	err = s.users.Store().Delete(userID)
+	if err == users.ErrNotFound {
+		return lhttp.NotFound().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to delete user: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to delete user")
+	}
+
+	if _, err = s.sessions.RevokeAll(userID, ""); err != nil {
+		s.logger.WithRequest(r).Error("Failed to revoke sessions of deleted user: ", err)
+	}
+
+	if err = s.mfa.Store().Delete(userID); err != nil && err != mfa.ErrNotEnrolled {
+		s.logger.WithRequest(r).Error("Failed to remove two-factor authentication of deleted user: ", err)
+	}
+
+	keys, err := s.apiKeys.Store().ByOwner(userID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load API keys of deleted user: ", err)
+		return lhttp.NoContent()
+	}
+	for _, key := range keys {
+		if _, err = s.apiKeys.Revoke(key.WorkspaceID, key.ID); err != nil {
+			s.logger.WithRequest(r).Error("Failed to revoke API key of deleted user: ", err)
+		}
+	}
+
+	return lhttp.NoContent()
+}
+
+// confirmIdentity verifies the password of the user again and a two-factor code when two-factor
+// authentication is enabled. Accounts of single sign-on have no password, they have to enable
+// two-factor authentication to confirm their identity.
+func (s *UrlShortenerServer) confirmIdentity(r *http.Request, user *users.User, password, code string) *lhttp.HttpResponse {
+	twoFactor, err := s.mfa.Enabled(user.ID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to check two-factor authentication: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to confirm the identity")
+	}
+
+	if user.PasswordHash == "" && twoFactor == false {
+		return lhttp.Forbidden().FromTrustedMessage("Enable two-factor authentication to confirm the identity of an account without a password")
+	}
+
+	if user.PasswordHash != "" {
+		err = s.users.Reauthenticate(user.ID, password)
+		if err == users.ErrInvalidCredentials {
+			return lhttp.Forbidden().FromTrustedMessage("Password is incorrect")
+		} else if err != nil {
+			s.logger.WithRequest(r).Error("Failed to verify password: ", err)
+			return lhttp.InternalServerError().FromTrustedMessage("Failed to confirm the identity")
+		}
+	}
+
+	if twoFactor {
+		err = s.mfa.Verify(user.ID, code)
+		if err == mfa.ErrInvalidCode {
+			return lhttp.Forbidden().FromTrustedError(err)
+		} else if err != nil {
+			s.logger.WithRequest(r).Error("Failed to verify two-factor code: ", err)
+			return lhttp.InternalServerError().FromTrustedMessage("Failed to confirm the identity")
+		}
+	}
+
+	return nil
+}
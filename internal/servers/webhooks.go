@@ -0,0 +1,177 @@
+package servers
+
+import (
+	"github.com/gorilla/mux"
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/webhooks"
+	"net/http"
+	"time"
+)
+
+type createWebhookRequest struct {
+	URL    string               `json:"url"`
+	Events []webhooks.EventType `json:"events"`
+	// Secret signs the deliveries. A random secret is generated when it is empty.
+	Secret string `json:"secret"`
+}
+
+// CreateWebhookHandler subscribes an endpoint to the events of the workspace. The secret is only
+// returned in this response.
+func (s *UrlShortenerServer) CreateWebhookHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.CreateWebhookHandler")
+
+	var request createWebhookRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	if err := s.webhooks.CheckURL(request.URL); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	for _, event := range request.Events {
+		if event.IsValid() == false {
+			return lhttp.BadRequest().FromTrustedMessage("Invalid webhook event - " + string(event))
+		}
+	}
+
+	if request.Secret == "" {
+		request.Secret = common.RandomHex(32)
+	}
+
+	subscription := &webhooks.Subscription{
+		ID:          common.NewID(),
+		WorkspaceID: mux.Vars(r)["workspaceID"],
+		URL:         request.URL,
+		Events:      emptyIfNil(request.Events),
+		Secret:      request.Secret,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := s.webhooks.Store().CreateSubscription(subscription); err != nil {
+		s.logger.WithRequest(r).Error("Failed to create webhook: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to create webhook")
+	}
+
+	// the secret is left out of the audit log
+	recorded := *subscription
+	recorded.Secret = ""
+	audit.Describe(r, audit.ActionWebhookCreate, audit.Target{Type: "webhook", ID: subscription.ID})
+	audit.RecordChange(r, nil, &recorded)
+
+	return lhttp.Created().WithJSON(subscription)
+}
+
+func (s *UrlShortenerServer) ListWebhooksHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ListWebhooksHandler")
+
+	subscriptions, err := s.webhooks.Store().Subscriptions(mux.Vars(r)["workspaceID"])
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load webhooks: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load webhooks")
+	}
+
+	for _, subscription := range subscriptions {
+		subscription.Secret = ""
+	}
+
+	return lhttp.OK().WithJSON(emptyIfNil(subscriptions))
+}
+
+func (s *UrlShortenerServer) DeleteWebhookHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.DeleteWebhookHandler")
+
+	subscription, resp := s.loadWebhook(r)
+	if resp != nil {
+		return resp
+	}
+
+	if err := s.webhooks.Store().DeleteSubscription(subscription.ID); err != nil {
+		s.logger.WithRequest(r).Error("Failed to delete webhook: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to delete webhook")
+	}
+
+	audit.Describe(r, audit.ActionWebhookDelete, audit.Target{Type: "webhook", ID: subscription.ID})
+
+	return lhttp.NoContent()
+}
+
+// ListWebhookDeliveriesHandler returns the delivery log of a webhook, newest first.
+func (s *UrlShortenerServer) ListWebhookDeliveriesHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ListWebhookDeliveriesHandler")
+
+	subscription, resp := s.loadWebhook(r)
+	if resp != nil {
+		return resp
+	}
+
+	deliveries, err := s.webhooks.Store().Deliveries(subscription.ID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load webhook deliveries: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load webhook deliveries")
+	}
+
+	return lhttp.OK().WithJSON(emptyIfNil(deliveries))
+}
+
+// ListDeadLettersHandler returns the deliveries of the workspace which exhausted their attempts.
+func (s *UrlShortenerServer) ListDeadLettersHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ListDeadLettersHandler")
+
+	deliveries, err := s.webhooks.Store().DeadDeliveries(mux.Vars(r)["workspaceID"])
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load webhook deliveries: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load webhook deliveries")
+	}
+
+	return lhttp.OK().WithJSON(emptyIfNil(deliveries))
+}
+
+// RetryWebhookDeliveryHandler queues a dead delivery again.
+func (s *UrlShortenerServer) RetryWebhookDeliveryHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.RetryWebhookDeliveryHandler")
+
+	subscription, resp := s.loadWebhook(r)
+	if resp != nil {
+		return resp
+	}
+
+	delivery, err := s.webhooks.Store().Delivery(mux.Vars(r)["deliveryID"])
+	if err == webhooks.ErrNotFound || (err == nil && delivery.SubscriptionID != subscription.ID) {
+		return lhttp.NotFound().FromTrustedMessage("Webhook delivery not found")
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load webhook delivery: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load webhook delivery")
+	}
+
+	if err = s.webhooks.Retry(delivery); err == webhooks.ErrNotDead {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to retry webhook delivery: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to retry webhook delivery")
+	}
+
+	return lhttp.Accepted().WithJSON(delivery)
+}
+
+// loadWebhook returns the webhook addressed by the "workspaceID" and "webhookID" route variables,
+// or the error response to be returned when it cannot be loaded.
+func (s *UrlShortenerServer) loadWebhook(r *http.Request) (*webhooks.Subscription, *lhttp.HttpResponse) {
+	vars := mux.Vars(r)
+	subscription, err := s.webhooks.Store().Subscription(vars["webhookID"])
+	if err == webhooks.ErrNotFound || (err == nil && subscription.WorkspaceID != vars["workspaceID"]) {
+		return nil, lhttp.NotFound().FromTrustedMessage("Webhook not found")
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load webhook: ", err)
+		return nil, lhttp.InternalServerError().FromTrustedMessage("Failed to load webhook")
+	}
+
+	return subscription, nil
+}
+
+func (s *UrlShortenerServer) dispatchWebhook(r *http.Request, workspaceID string, eventType webhooks.EventType, data interface{}) {
+	if err := s.webhooks.Dispatch(workspaceID, eventType, data); err != nil {
+		s.logger.WithRequest(r).Error("Failed to dispatch webhook: ", err)
+	}
+}
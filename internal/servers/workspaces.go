@@ -0,0 +1,535 @@
+package servers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"lynkly-backend/internal/audit"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/domains"
+	"lynkly-backend/internal/links"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/models/lhttp"
+	"lynkly-backend/internal/origins"
+	"lynkly-backend/internal/users"
+	"lynkly-backend/internal/webhooks"
+	"lynkly-backend/internal/workspaces"
+	"net/http"
+)
+
+// WorkspaceHeader selects the workspace of the requests which do not address one in their path.
+const WorkspaceHeader = "X-Workspace-ID"
+
+type createWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+type workspaceResponse struct {
+	*workspaces.Workspace
+	Role auth.Role `json:"role"`
+}
+
+type workspacePolicyRequest struct {
+	RequireTwoFactor *bool `json:"requireTwoFactor"`
+}
+
+type workspaceCORSRequest struct {
+	AllowedOrigins []string `json:"allowedOrigins"`
+}
+
+type updateMemberRequest struct {
+	Role auth.Role `json:"role"`
+}
+
+type createInvitationRequest struct {
+	Email string    `json:"email"`
+	Role  auth.Role `json:"role"`
+}
+
+type createInvitationResponse struct {
+	*workspaces.Invitation
+	// Token has to be passed to the invited user. It is only returned on creation.
+	Token string `json:"token"`
+}
+
+type acceptInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+// ResolveTenant resolves the workspace of an authenticated request and the role of the user in it.
+// The workspace is taken from the "workspaceID" route variable, the API key, the X-Workspace-ID
+// header or the default workspace of the user, in this order. Workspaces the user is not a member of
+// are reported as missing. Owners and admins of a workspace requiring two-factor authentication get no
+// role in it unless they logged in with a second factor.
+func (s *UrlShortenerServer) ResolveTenant(r *http.Request) (*http.Request, *lhttp.HttpResponse) {
+	principal := auth.PrincipalFrom(r)
+	if principal == nil || principal.UserID == "" {
+		return nil, lhttp.Forbidden().FromTrustedMessage("Credentials without a subject cannot access workspaces")
+	}
+
+	resolved := *principal
+	resolved.WorkspaceResolved = true
+
+	workspaceID := mux.Vars(r)["workspaceID"]
+	if principal.Method == auth.MethodAPIKey {
+		// API keys are bound to the workspace they were created in
+		if workspaceID != "" && workspaceID != principal.WorkspaceID {
+			return nil, lhttp.NotFound().FromTrustedMessage(fmt.Sprintf("Workspace not found - %s", workspaceID))
+		}
+		workspaceID = principal.WorkspaceID
+	} else if workspaceID == "" {
+		workspaceID = r.Header.Get(WorkspaceHeader)
+	}
+
+	// the requested workspace is logged as the account of the request even when it is not resolved
+	resolved.WorkspaceID = workspaceID
+	r = common.ContextSet(r, common.ContextPair{Key: logging.UserKey, Value: &resolved})
+
+	var member *workspaces.Member
+	var err error
+	if workspaceID == "" {
+		member, err = s.workspaces.DefaultWorkspace(principal.UserID)
+		if err == workspaces.ErrNotFound {
+			// users without a workspace can still manage themselves, e.g. create a workspace
+			return r, nil
+		}
+	} else {
+		member, err = s.workspaces.Store().Member(workspaceID, principal.UserID)
+	}
+
+	if err == workspaces.ErrMemberNotFound {
+		return r, lhttp.NotFound().FromTrustedMessage(fmt.Sprintf("Workspace not found - %s", workspaceID))
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to resolve workspace: ", err)
+		return r, lhttp.InternalServerError().FromTrustedMessage("Failed to resolve workspace")
+	}
+
+	resolved.WorkspaceID = member.WorkspaceID
+	resolved.Role = member.Role
+	if member.Role.Outranks(auth.RoleEditor) && principal.Method == auth.MethodJWT && principal.HasSecondFactor() == false {
+		workspace, err := s.workspaces.Store().Workspace(member.WorkspaceID)
+		if err != nil {
+			s.logger.WithRequest(r).Error("Failed to load workspace: ", err)
+			return r, lhttp.InternalServerError().FromTrustedMessage("Failed to resolve workspace")
+		}
+
+		// the user is not rejected, so that it can still enable two-factor authentication
+		if workspace.RequireTwoFactor {
+			resolved.Role = ""
+		}
+	}
+
+	return r, nil
+}
+
+func (s *UrlShortenerServer) CreateWorkspaceHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.CreateWorkspaceHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	var request createWorkspaceRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	workspace, err := s.workspaces.Create(request.Name, auth.PrincipalFrom(r).UserID)
+	if err == workspaces.ErrInvalidName {
+		return lhttp.BadRequest().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to create workspace: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to create workspace")
+	}
+
+	audit.Describe(r, audit.ActionWorkspaceCreate, audit.Target{Type: "workspace", ID: workspace.ID, WorkspaceID: workspace.ID})
+	audit.RecordChange(r, nil, workspace)
+
+	return lhttp.Created().WithJSON(workspaceResponse{
+		Workspace: workspace,
+		Role:      auth.RoleOwner,
+	})
+}
+
+// ListWorkspacesHandler lists the workspaces of the user, the default workspace first.
+func (s *UrlShortenerServer) ListWorkspacesHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ListWorkspacesHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	memberships, err := s.workspaces.Store().Memberships(auth.PrincipalFrom(r).UserID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load memberships: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load workspaces")
+	}
+
+	result := make([]workspaceResponse, 0, len(memberships))
+	for _, member := range memberships {
+		workspace, err := s.workspaces.Store().Workspace(member.WorkspaceID)
+		if err != nil {
+			s.logger.WithRequest(r).Error("Failed to load workspace: ", err)
+			return lhttp.InternalServerError().FromTrustedMessage("Failed to load workspaces")
+		}
+
+		result = append(result, workspaceResponse{Workspace: workspace, Role: member.Role})
+	}
+
+	return lhttp.OK().WithJSON(result)
+}
+
+func (s *UrlShortenerServer) GetWorkspaceHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.GetWorkspaceHandler")
+	principal := auth.PrincipalFrom(r)
+
+	workspace, err := s.workspaces.Store().Workspace(principal.WorkspaceID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load workspace: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load workspace")
+	}
+
+	return lhttp.OK().WithJSON(workspaceResponse{
+		Workspace: workspace,
+		Role:      principal.Role,
+	})
+}
+
+// UpdateWorkspacePolicyHandler changes the security policy of the workspace. Only owners may change it
+// and only from a session with a second factor, so that they cannot lock themselves out.
+func (s *UrlShortenerServer) UpdateWorkspacePolicyHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.UpdateWorkspacePolicyHandler")
+	var request workspacePolicyRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	if request.RequireTwoFactor == nil {
+		return lhttp.BadRequest().FromTrustedMessage("Missing requireTwoFactor")
+	}
+
+	principal := auth.PrincipalFrom(r)
+	if principal.Role != auth.RoleOwner {
+		return lhttp.Forbidden().FromTrustedMessage("Only owners can change the workspace policy")
+	}
+
+	if *request.RequireTwoFactor && principal.HasSecondFactor() == false {
+		return lhttp.Conflict().FromTrustedMessage("Log in with two-factor authentication before requiring it")
+	}
+
+	before, err := s.workspaces.Store().Workspace(principal.WorkspaceID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load workspace: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to update the workspace policy")
+	}
+
+	workspace := *before
+	workspace.RequireTwoFactor = *request.RequireTwoFactor
+	if err = s.workspaces.Store().UpdateWorkspace(&workspace); err != nil {
+		s.logger.WithRequest(r).Error("Failed to update workspace: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to update the workspace policy")
+	}
+
+	audit.Describe(r, audit.ActionWorkspacePolicyUpdate, audit.Target{Type: "workspace", ID: workspace.ID, WorkspaceID: workspace.ID})
+	audit.RecordChange(r, before, &workspace)
+
+	return lhttp.OK().WithJSON(workspaceResponse{
+		Workspace: &workspace,
+		Role:      principal.Role,
+	})
+}
+
+// UpdateWorkspaceCORSHandler replaces the origins which may call the routes of the workspace in the
+// management API from the browser. Its verified custom domains are allowed without being listed.
+func (s *UrlShortenerServer) UpdateWorkspaceCORSHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.UpdateWorkspaceCORSHandler")
+	var request workspaceCORSRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	principal := auth.PrincipalFrom(r)
+	before, err := s.workspaces.Store().Workspace(principal.WorkspaceID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load workspace: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to update the allowed origins")
+	}
+
+	workspace, err := s.workspaces.SetAllowedOrigins(principal.WorkspaceID, request.AllowedOrigins)
+	if errors.Is(err, origins.ErrInvalidPattern) || err == workspaces.ErrTooManyOrigins {
+		return lhttp.BadRequest().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to update workspace: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to update the allowed origins")
+	}
+
+	audit.Describe(r, audit.ActionWorkspaceCORSUpdate, audit.Target{Type: "workspace", ID: workspace.ID, WorkspaceID: workspace.ID})
+	audit.RecordChange(r, before, workspace)
+
+	return lhttp.OK().WithJSON(workspaceResponse{
+		Workspace: workspace,
+		Role:      principal.Role,
+	})
+}
+
+func (s *UrlShortenerServer) ListMembersHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ListMembersHandler")
+	members, err := s.workspaces.Store().Members(auth.PrincipalFrom(r).WorkspaceID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load members: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load members")
+	}
+
+	return lhttp.OK().WithJSON(emptyIfNil(members))
+}
+
+// UpdateMemberHandler changes the role of a member. Only owners may grant the owner role or change
+// the role of another owner.
+func (s *UrlShortenerServer) UpdateMemberHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.UpdateMemberHandler")
+	var request updateMemberRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	if request.Role.IsValid() == false {
+		return lhttp.BadRequest().FromTrustedError(workspaces.ErrInvalidRole)
+	}
+
+	principal := auth.PrincipalFrom(r)
+	member, resp := s.loadMember(r)
+	if resp != nil {
+		return resp
+	}
+
+	if (request.Role == auth.RoleOwner || member.Role == auth.RoleOwner) && principal.Role != auth.RoleOwner {
+		return lhttp.Forbidden().FromTrustedMessage("Only owners can change the owner role")
+	}
+
+	before := *member
+	member, err := s.workspaces.SetRole(member.WorkspaceID, member.UserID, request.Role)
+	if err == workspaces.ErrLastOwner {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to update member: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to update member")
+	}
+
+	audit.Describe(r, audit.ActionMemberUpdate, audit.Target{Type: "member", ID: member.UserID})
+	audit.RecordChange(r, &before, member)
+
+	return lhttp.OK().WithJSON(member)
+}
+
+// RemoveMemberHandler removes a member from the workspace. Every member may leave the workspace,
+// removing other members requires the account scope and removing an owner requires being an owner.
+func (s *UrlShortenerServer) RemoveMemberHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.RemoveMemberHandler")
+	principal := auth.PrincipalFrom(r)
+	member, resp := s.loadMember(r)
+	if resp != nil {
+		return resp
+	}
+
+	if member.UserID != principal.UserID {
+		if principal.HasScope(auth.ScopeAccount) == false {
+			return lhttp.Forbidden().FromTrustedMessage("Missing scope - " + auth.ScopeAccount)
+		}
+		if member.Role == auth.RoleOwner && principal.Role != auth.RoleOwner {
+			return lhttp.Forbidden().FromTrustedMessage("Only owners can remove owners")
+		}
+	}
+
+	err := s.workspaces.RemoveMember(member.WorkspaceID, member.UserID)
+	if err == workspaces.ErrLastOwner {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to remove member: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to remove member")
+	}
+
+	audit.Describe(r, audit.ActionMemberRemove, audit.Target{Type: "member", ID: member.UserID})
+	audit.RecordChange(r, member, nil)
+
+	return lhttp.NoContent()
+}
+
+// CreateInvitationHandler invites a user by email. The response holds the invitation token which the
+// invited user accepts the invitation with.
+func (s *UrlShortenerServer) CreateInvitationHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.CreateInvitationHandler")
+	var request createInvitationRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	email, err := users.NormalizeEmail(request.Email)
+	if err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	if request.Role.IsValid() == false {
+		return lhttp.BadRequest().FromTrustedError(workspaces.ErrInvalidRole)
+	}
+
+	principal := auth.PrincipalFrom(r)
+	if request.Role == auth.RoleOwner && principal.Role != auth.RoleOwner {
+		return lhttp.Forbidden().FromTrustedMessage("Only owners can invite owners")
+	}
+
+	invitation, token, err := s.workspaces.Invite(principal.WorkspaceID, email, request.Role, principal.UserID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to create invitation: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to create invitation")
+	}
+
+	audit.Describe(r, audit.ActionInvitationCreate, audit.Target{Type: "invitation", ID: invitation.ID})
+	audit.RecordChange(r, nil, invitation)
+
+	return lhttp.Created().WithJSON(createInvitationResponse{
+		Invitation: invitation,
+		Token:      token,
+	})
+}
+
+func (s *UrlShortenerServer) ListInvitationsHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.ListInvitationsHandler")
+	invitations, err := s.workspaces.Store().Invitations(auth.PrincipalFrom(r).WorkspaceID)
+	if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load invitations: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to load invitations")
+	}
+
+	return lhttp.OK().WithJSON(emptyIfNil(invitations))
+}
+
+func (s *UrlShortenerServer) DeleteInvitationHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.DeleteInvitationHandler")
+	invitation, err := s.workspaces.Store().Invitation(mux.Vars(r)["invitationID"])
+	if err == workspaces.ErrInvitationNotFound || (err == nil && invitation.WorkspaceID != auth.PrincipalFrom(r).WorkspaceID) {
+		return lhttp.NotFound().FromTrustedError(workspaces.ErrInvitationNotFound)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load invitation: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to delete invitation")
+	}
+
+	if err = s.workspaces.Store().DeleteInvitation(invitation.ID); err != nil && err != workspaces.ErrInvitationNotFound {
+		s.logger.WithRequest(r).Error("Failed to delete invitation: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to delete invitation")
+	}
+
+	audit.Describe(r, audit.ActionInvitationDelete, audit.Target{Type: "invitation", ID: invitation.ID})
+	audit.RecordChange(r, invitation, nil)
+
+	return lhttp.NoContent()
+}
+
+// AcceptInvitationHandler adds the user to the workspace of the invitation. The invitation has to be
+// addressed to the email of the user.
+func (s *UrlShortenerServer) AcceptInvitationHandler(r *http.Request) *lhttp.HttpResponse {
+	s.logger.Debug("UrlShortenerServer.AcceptInvitationHandler")
+	if resp := requireUser(r); resp != nil {
+		return resp
+	}
+
+	var request acceptInvitationRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		return lhttp.BadRequest().FromTrustedError(err)
+	}
+
+	user, err := s.users.Store().Get(auth.PrincipalFrom(r).UserID)
+	if err == users.ErrNotFound {
+		return lhttp.NotFound().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load user: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to accept invitation")
+	}
+
+	member, err := s.workspaces.Accept(request.Token, user.ID, user.Email)
+	if err == workspaces.ErrInvitationNotFound {
+		return lhttp.NotFound().FromTrustedError(err)
+	} else if err == workspaces.ErrInvitationExpired {
+		return lhttp.StatusGone().FromTrustedError(err)
+	} else if err == workspaces.ErrInvitationEmail {
+		return lhttp.Forbidden().FromTrustedError(err)
+	} else if err == workspaces.ErrAlreadyMember {
+		return lhttp.Conflict().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to accept invitation: ", err)
+		return lhttp.InternalServerError().FromTrustedMessage("Failed to accept invitation")
+	}
+
+	audit.Describe(r, audit.ActionInvitationAccept, audit.Target{Type: "member", ID: member.UserID, WorkspaceID: member.WorkspaceID})
+	audit.RecordChange(r, nil, member)
+
+	return lhttp.OK().WithJSON(member)
+}
+
+// deleteWorkspace deletes the workspace with its links, custom domains and webhooks.
+func (s *UrlShortenerServer) deleteWorkspace(r *http.Request, workspaceID string) error {
+	workspaceLinks, err := s.links.List()
+	if err != nil {
+		return err
+	}
+	for _, link := range workspaceLinks {
+		if link.WorkspaceID != workspaceID {
+			continue
+		}
+		if err = s.links.Delete(link.Code); err != nil && err != links.ErrNotFound {
+			return err
+		}
+	}
+
+	workspaceDomains, err := s.domains.Store().Domains(workspaceID)
+	if err != nil {
+		return err
+	}
+	for _, domain := range workspaceDomains {
+		if _, err = s.domains.Remove(workspaceID, domain.Name); err != nil && err != domains.ErrNotFound {
+			return err
+		}
+		if s.acme != nil {
+			if err = s.acme.Forget(r.Context(), domain.Name); err != nil {
+				s.logger.WithRequest(r).Error("Failed to delete the certificates of the domain: ", err)
+			}
+		}
+	}
+
+	subscriptions, err := s.webhooks.Store().Subscriptions(workspaceID)
+	if err != nil {
+		return err
+	}
+	for _, subscription := range subscriptions {
+		if err = s.webhooks.Store().DeleteSubscription(subscription.ID); err != nil && err != webhooks.ErrNotFound {
+			return err
+		}
+	}
+
+	if err = s.workspaces.Store().DeleteWorkspace(workspaceID); err != nil && err != workspaces.ErrNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// loadMember returns the member of the current workspace addressed by the "userID" route variable,
+// or the error response to be returned when it cannot be loaded.
+func (s *UrlShortenerServer) loadMember(r *http.Request) (*workspaces.Member, *lhttp.HttpResponse) {
+	member, err := s.workspaces.Store().Member(auth.PrincipalFrom(r).WorkspaceID, mux.Vars(r)["userID"])
+	if err == workspaces.ErrMemberNotFound {
+		return nil, lhttp.NotFound().FromTrustedError(err)
+	} else if err != nil {
+		s.logger.WithRequest(r).Error("Failed to load member: ", err)
+		return nil, lhttp.InternalServerError().FromTrustedMessage("Failed to load member")
+	}
+
+	return member, nil
+}
+
+// requireUser rejects the requests which are not made by the user itself, e.g. with an API key.
+func requireUser(r *http.Request) *lhttp.HttpResponse {
+	if principal := auth.PrincipalFrom(r); principal == nil || principal.ActsAsUser() == false {
+		return lhttp.Forbidden().FromTrustedMessage("Only available to users")
+	}
+
+	return nil
+}
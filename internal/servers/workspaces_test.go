@@ -0,0 +1,178 @@
+package servers
+
+import (
+	"github.com/gorilla/mux"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/workspaces"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestTenantServer(t *testing.T) *UrlShortenerServer {
+	t.Helper()
+
+	s := &UrlShortenerServer{
+		logger:     logging.NewLogger("servers_test"),
+		workspaces: workspaces.New(workspaces.Params{Store: workspaces.NewMemoryStore()}),
+	}
+
+	// the default workspace of a user is the first one it joined
+	for _, id := range []string{"default", "route", "key", "header"} {
+		if _, err := s.workspaces.CreateWithID(id, id, "user"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.workspaces.CreateWithID("other", "other", "outsider"); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func newTestTenantRequest(principal *auth.Principal, routeWorkspaceID, headerWorkspaceID string) *http.Request {
+	r := httptest.NewRequest("GET", "/api/v1/links", nil)
+	if routeWorkspaceID != "" {
+		r = mux.SetURLVars(r, map[string]string{"workspaceID": routeWorkspaceID})
+	}
+	if headerWorkspaceID != "" {
+		r.Header.Set(WorkspaceHeader, headerWorkspaceID)
+	}
+
+	return common.ContextSet(r, common.ContextPair{Key: logging.UserKey, Value: principal})
+}
+
+func TestResolveTenantPrecedence(t *testing.T) {
+	s := newTestTenantServer(t)
+	user := &auth.Principal{UserID: "user", Method: auth.MethodJWT}
+	apiKey := &auth.Principal{UserID: "user", Method: auth.MethodAPIKey, WorkspaceID: "key"}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		route     string
+		header    string
+		expected  string
+		status    int
+	}{
+		{"route before header", user, "route", "header", "route", http.StatusOK},
+		{"header", user, "", "header", "header", http.StatusOK},
+		{"default", user, "", "", "default", http.StatusOK},
+		{"API key before header", apiKey, "", "header", "key", http.StatusOK},
+		{"API key and its route", apiKey, "key", "", "key", http.StatusOK},
+		{"API key and another route", apiKey, "route", "", "", http.StatusNotFound},
+		{"not a member", user, "other", "", "other", http.StatusNotFound},
+		{"not a member by header", user, "", "other", "other", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		r, resp := s.ResolveTenant(newTestTenantRequest(test.principal, test.route, test.header))
+
+		status := http.StatusOK
+		if resp != nil {
+			status = resp.StatusCode()
+		}
+		if status != test.status {
+			t.Errorf("%s: expected %d, got %d", test.name, test.status, status)
+			continue
+		}
+		if r == nil {
+			continue
+		}
+
+		// the requested workspace is logged even when it is not resolved
+		resolved := auth.PrincipalFrom(r)
+		if resolved.WorkspaceID != test.expected || resolved.WorkspaceResolved == false {
+			t.Errorf("%s: expected workspace %s, got %+v", test.name, test.expected, resolved)
+		}
+		if status == http.StatusOK && resolved.Role != auth.RoleOwner {
+			t.Errorf("%s: expected the owner role, got %s", test.name, resolved.Role)
+		} else if status != http.StatusOK && resolved.HasScope(auth.ScopeLinksRead) {
+			t.Errorf("%s: expected no scopes in a workspace which is not resolved", test.name)
+		}
+	}
+}
+
+func TestResolveTenantWithoutWorkspace(t *testing.T) {
+	s := newTestTenantServer(t)
+
+	r, resp := s.ResolveTenant(newTestTenantRequest(&auth.Principal{UserID: "new", Method: auth.MethodJWT}, "", ""))
+	if resp != nil {
+		t.Fatalf("Expected users without a workspace to be resolved, got %d", resp.StatusCode())
+	}
+
+	resolved := auth.PrincipalFrom(r)
+	if resolved.WorkspaceID != "" || resolved.HasScope(auth.ScopeLinksRead) || resolved.ActsAsUser() == false {
+		t.Errorf("Expected a principal acting only as the user, got %+v", resolved)
+	}
+
+	if _, resp = s.ResolveTenant(newTestTenantRequest(&auth.Principal{Method: auth.MethodJWT}, "", "")); resp == nil || resp.StatusCode() != http.StatusForbidden {
+		t.Errorf("Expected %d for credentials without a subject, got %+v", http.StatusForbidden, resp)
+	}
+}
+
+func TestResolveTenantRoles(t *testing.T) {
+	s := newTestTenantServer(t)
+	for userID, role := range map[string]auth.Role{"admin": auth.RoleAdmin, "editor": auth.RoleEditor, "viewer": auth.RoleViewer} {
+		if _, err := s.workspaces.SetRole("default", userID, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	workspace, err := s.workspaces.Store().Workspace("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace.RequireTwoFactor = true
+	if err = s.workspaces.Store().UpdateWorkspace(workspace); err != nil {
+		t.Fatal(err)
+	}
+
+	withOTP := []string{auth.AuthMethodPassword, auth.AuthMethodOTP}
+	tests := []struct {
+		name        string
+		principal   *auth.Principal
+		role        auth.Role
+		allowed     []string
+		notAccepted []string
+	}{
+		{"owner with two factors", &auth.Principal{UserID: "user", Method: auth.MethodJWT, AuthMethods: withOTP},
+			auth.RoleOwner, []string{auth.ScopeLinksWrite, auth.ScopeAccount}, nil},
+		{"owner without two factors", &auth.Principal{UserID: "user", Method: auth.MethodJWT},
+			"", nil, []string{auth.ScopeLinksRead, auth.ScopeAccount}},
+		{"admin without two factors", &auth.Principal{UserID: "admin", Method: auth.MethodJWT},
+			"", nil, []string{auth.ScopeLinksRead}},
+		{"editor without two factors", &auth.Principal{UserID: "editor", Method: auth.MethodJWT},
+			auth.RoleEditor, []string{auth.ScopeLinksWrite, auth.ScopeStatsRead}, []string{auth.ScopeAccount}},
+		{"viewer", &auth.Principal{UserID: "viewer", Method: auth.MethodJWT},
+			auth.RoleViewer, []string{auth.ScopeLinksRead}, []string{auth.ScopeLinksWrite, auth.ScopeAccount}},
+		{"API key of an owner", &auth.Principal{UserID: "user", Method: auth.MethodAPIKey, WorkspaceID: "default", Scopes: []string{auth.ScopeLinksRead}},
+			auth.RoleOwner, []string{auth.ScopeLinksRead}, []string{auth.ScopeLinksWrite}},
+		{"API key of a viewer", &auth.Principal{UserID: "viewer", Method: auth.MethodAPIKey, WorkspaceID: "default", Scopes: []string{auth.ScopeLinksWrite}},
+			auth.RoleViewer, nil, []string{auth.ScopeLinksWrite}},
+	}
+
+	for _, test := range tests {
+		r, resp := s.ResolveTenant(newTestTenantRequest(test.principal, "default", ""))
+		if resp != nil {
+			t.Errorf("%s: expected the workspace to be resolved, got %d", test.name, resp.StatusCode())
+			continue
+		}
+
+		resolved := auth.PrincipalFrom(r)
+		if resolved.Role != test.role {
+			t.Errorf("%s: expected role %q, got %q", test.name, test.role, resolved.Role)
+		}
+		for _, scope := range test.allowed {
+			if resolved.HasScope(scope) == false {
+				t.Errorf("%s: expected scope %s", test.name, scope)
+			}
+		}
+		for _, scope := range test.notAccepted {
+			if resolved.HasScope(scope) {
+				t.Errorf("%s: expected no scope %s", test.name, scope)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package sessions
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL = 30 * time.Second
+	// maxCacheEntries bounds the memory of the cache, it is cleared when it grows beyond
+	maxCacheEntries = 100000
+)
+
+// RevocationStore holds the revoked IDs of access tokens and sessions until they expire.
+type RevocationStore interface {
+	Revoke(id string, expiresAt time.Time) error
+	IsRevoked(id string, now time.Time) (bool, error)
+}
+
+type RevocationListParams struct {
+	Store RevocationStore
+	// CacheTTL is how long a lookup is cached. Revocations made by other instances of the service take
+	// up to that long to be seen.
+	CacheTTL time.Duration
+}
+
+type cacheEntry struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+// RevocationList is checked by the authentication of every request, so lookups are cached in memory.
+type RevocationList struct {
+	store    RevocationStore
+	cacheTTL time.Duration
+
+	mu    sync.RWMutex
+	cache map[string]cacheEntry
+}
+
+func NewRevocationList(params RevocationListParams) *RevocationList {
+	if params.CacheTTL <= 0 {
+		params.CacheTTL = defaultCacheTTL
+	}
+
+	return &RevocationList{
+		store:    params.Store,
+		cacheTTL: params.CacheTTL,
+		cache:    make(map[string]cacheEntry),
+	}
+}
+
+// Revoke adds the ID to the list until it expires.
+func (l *RevocationList) Revoke(id string, expiresAt time.Time) error {
+	if err := l.store.Revoke(id, expiresAt); err != nil {
+		return err
+	}
+
+	l.remember(id, cacheEntry{revoked: true, expiresAt: expiresAt})
+	return nil
+}
+
+func (l *RevocationList) IsRevoked(id string) (bool, error) {
+	now := time.Now()
+	l.mu.RLock()
+	entry, ok := l.cache[id]
+	l.mu.RUnlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := l.store.IsRevoked(id, now)
+	if err != nil {
+		return false, err
+	}
+
+	l.remember(id, cacheEntry{revoked: revoked, expiresAt: now.Add(l.cacheTTL)})
+	return revoked, nil
+}
+
+func (l *RevocationList) remember(id string, entry cacheEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.cache) >= maxCacheEntries {
+		l.cache = make(map[string]cacheEntry)
+	}
+	l.cache[id] = entry
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func NewMemoryRevocationStore() RevocationStore {
+	return &memoryRevocationStore{revoked: make(map[string]time.Time)}
+}
+
+func (s *memoryRevocationStore) Revoke(id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// expired revocations are removed here instead of by a background job
+	now := time.Now()
+	for revokedID, revokedUntil := range s.revoked {
+		if now.After(revokedUntil) {
+			delete(s.revoked, revokedID)
+		}
+	}
+
+	s.revoked[id] = expiresAt
+	return nil
+}
+
+func (s *memoryRevocationStore) IsRevoked(id string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revokedUntil, ok := s.revoked[id]
+	return ok && now.Before(revokedUntil), nil
+}
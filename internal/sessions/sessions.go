@@ -0,0 +1,252 @@
+package sessions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"lynkly-backend/internal/common"
+	"time"
+)
+
+const (
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	defaultAccessTokenTTL  = 15 * time.Minute
+	refreshTokenLength     = 32
+	// revocationMargin keeps revocations a little longer than the access tokens live to cover the
+	// clock skew tolerated when validating them
+	revocationMargin = 5 * time.Minute
+)
+
+var (
+	ErrNotFound            = errors.New("session not found")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when a refresh token is presented after it was rotated. The
+	// token has most likely leaked, so the whole session is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token has already been used - the session has been revoked")
+)
+
+// Session is a login of a user. The session is kept alive by rotating refresh tokens and ends when
+// it expires or is revoked.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"userId"`
+	UserAgent  string     `json:"userAgent,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	// AuthMethods are the methods the user logged in with. They are carried into every access token
+	// of the session.
+	AuthMethods []string `json:"authMethods"`
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RefreshToken is a single-use token of a session. Only the SHA-256 hash of the token is stored. Used
+// tokens are kept, so that their reuse can be detected.
+type RefreshToken struct {
+	TokenHash string
+	SessionID string
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+type Params struct {
+	Store       Store
+	Revocations *RevocationList
+	// RefreshTokenTTL is the lifetime of a session. Refreshing does not extend it.
+	RefreshTokenTTL time.Duration
+	// AccessTokenTTL is the lifetime of the access tokens, for which revocations have to be kept.
+	AccessTokenTTL time.Duration
+}
+
+// Sessions starts, refreshes and revokes the sessions of the users.
+type Sessions struct {
+	store           Store
+	revocations     *RevocationList
+	refreshTokenTTL time.Duration
+	accessTokenTTL  time.Duration
+}
+
+func New(params Params) *Sessions {
+	if params.RefreshTokenTTL <= 0 {
+		params.RefreshTokenTTL = defaultRefreshTokenTTL
+	}
+	if params.AccessTokenTTL <= 0 {
+		params.AccessTokenTTL = defaultAccessTokenTTL
+	}
+
+	return &Sessions{
+		store:           params.Store,
+		revocations:     params.Revocations,
+		refreshTokenTTL: params.RefreshTokenTTL,
+		accessTokenTTL:  params.AccessTokenTTL,
+	}
+}
+
+func (s *Sessions) Store() Store {
+	return s.store
+}
+
+// Start creates a session for the user authenticated with the methods and returns it together with
+// its first refresh token.
+func (s *Sessions) Start(userID, userAgent string, authMethods []string) (*Session, string, error) {
+	now := time.Now().UTC()
+	session := &Session{
+		ID:          common.NewID(),
+		UserID:      userID,
+		UserAgent:   userAgent,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(s.refreshTokenTTL),
+		AuthMethods: authMethods,
+	}
+	if err := s.store.CreateSession(session); err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.newRefreshToken(session.ID, now)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return session, token, nil
+}
+
+// Refresh exchanges the refresh token for a new one. Each refresh token can be used once and
+// presenting a used one revokes the session.
+func (s *Sessions) Refresh(token string) (*Session, string, error) {
+	now := time.Now().UTC()
+	newToken := common.RandomHex(refreshTokenLength)
+	refreshToken, session, err := s.store.RotateRefreshToken(hashToken(token), hashToken(newToken), now)
+	if err == ErrNotFound {
+		return nil, "", ErrInvalidRefreshToken
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	if refreshToken.UsedAt != nil {
+		if session.IsActive(now) {
+			if err = s.revoke(session.ID, now); err != nil {
+				return nil, "", err
+			}
+		}
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	if session.IsActive(now) == false {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	return session, newToken, nil
+}
+
+// Active returns the active sessions of the user, oldest first.
+func (s *Sessions) Active(userID string) ([]*Session, error) {
+	sessions, err := s.store.SessionsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	result := make([]*Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.IsActive(now) {
+			result = append(result, session)
+		}
+	}
+
+	return result, nil
+}
+
+// Revoke ends the session of the user. The access tokens issued for it are rejected from now on.
+func (s *Sessions) Revoke(userID, sessionID string) error {
+	session, err := s.store.Session(sessionID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if session.UserID != userID || session.IsActive(now) == false {
+		return ErrNotFound
+	}
+
+	return s.revoke(session.ID, now)
+}
+
+// RevokeAll ends the sessions of the user except the kept one, which may be empty, and returns the
+// number of revoked sessions.
+func (s *Sessions) RevokeAll(userID, keepSessionID string) (int, error) {
+	sessions, err := s.Active(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == keepSessionID {
+			continue
+		}
+
+		if err = s.revoke(session.ID, now); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+
+	return revoked, nil
+}
+
+// RevokeAccessToken rejects the single access token with the ID until it expires.
+func (s *Sessions) RevokeAccessToken(tokenID string) error {
+	return s.revocations.Revoke(tokenID, time.Now().UTC().Add(s.accessTokenTTL+revocationMargin))
+}
+
+// IsRevoked reports whether any of the IDs, i.e. the ID of an access token or of its session, has been
+// revoked. Empty IDs are ignored.
+func (s *Sessions) IsRevoked(ids ...string) (bool, error) {
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+
+		revoked, err := s.revocations.IsRevoked(id)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	return false, nil
+}
+
+func (s *Sessions) revoke(sessionID string, now time.Time) error {
+	if _, err := s.store.RevokeSession(sessionID, now); err != nil {
+		return err
+	}
+
+	// the access tokens of the session outlive it, so the session stays on the revocation list until
+	// the last of them expires
+	return s.revocations.Revoke(sessionID, now.Add(s.accessTokenTTL+revocationMargin))
+}
+
+func (s *Sessions) newRefreshToken(sessionID string, now time.Time) (string, error) {
+	token := common.RandomHex(refreshTokenLength)
+	err := s.store.SaveRefreshToken(&RefreshToken{
+		TokenHash: hashToken(token),
+		SessionID: sessionID,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
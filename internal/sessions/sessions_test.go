@@ -0,0 +1,229 @@
+package sessions
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestSessions() *Sessions {
+	return New(Params{
+		Store:       NewMemoryStore(),
+		Revocations: NewRevocationList(RevocationListParams{Store: NewMemoryRevocationStore()}),
+	})
+}
+
+func TestRefreshRotation(t *testing.T) {
+	s := newTestSessions()
+
+	session, first, err := s.Start("user", "Mozilla/5.0", []string{"pwd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, second, err := s.Refresh(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.ID != session.ID || second == first || refreshed.LastUsedAt.Before(session.LastUsedAt) {
+		t.Errorf("Expected the session to be refreshed with a new token, got %+v", refreshed)
+	}
+	if refreshed.ExpiresAt.Equal(session.ExpiresAt) == false {
+		t.Errorf("Expected refreshing not to extend the session, got %v", refreshed.ExpiresAt)
+	}
+
+	_, third, err := s.Refresh(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = s.Refresh("unknown"); err != ErrInvalidRefreshToken {
+		t.Errorf("Expected ErrInvalidRefreshToken for an unknown token, got %v", err)
+	}
+
+	// the reuse of a rotated token revokes the whole session, including its latest token
+	if _, _, err = s.Refresh(first); err != ErrRefreshTokenReused {
+		t.Errorf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, _, err = s.Refresh(third); err != ErrInvalidRefreshToken {
+		t.Errorf("Expected ErrInvalidRefreshToken after the reuse, got %v", err)
+	}
+
+	stored, err := s.Store().Session(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RevokedAt == nil {
+		t.Error("Expected the session to be revoked")
+	}
+	if revoked, err := s.IsRevoked("", session.ID); err != nil || revoked == false {
+		t.Errorf("Expected the access tokens of the session to be revoked, got %v, %v", revoked, err)
+	}
+}
+
+func TestConcurrentRefresh(t *testing.T) {
+	s := newTestSessions()
+
+	session, token, err := s.Start("user", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan error, 10)
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := s.Refresh(token)
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	refreshed := 0
+	for err := range results {
+		if err == nil {
+			refreshed++
+		} else if err != ErrRefreshTokenReused {
+			t.Errorf("Expected ErrRefreshTokenReused, got %v", err)
+		}
+	}
+	if refreshed != 1 {
+		t.Errorf("Expected a single refresh to succeed, got %d", refreshed)
+	}
+
+	stored, err := s.Store().Session(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RevokedAt == nil {
+		t.Error("Expected the session to be revoked after the token was reused")
+	}
+}
+
+func TestRefreshRevokedSession(t *testing.T) {
+	s := newTestSessions()
+
+	session, token, err := s.Start("user", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Revoke("other", session.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for the session of another user, got %v", err)
+	}
+	if err = s.Revoke("user", session.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = s.Refresh(token); err != ErrInvalidRefreshToken {
+		t.Errorf("Expected ErrInvalidRefreshToken, got %v", err)
+	}
+
+	stored, err := s.Store().Session(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RevokedAt == nil || stored.LastUsedAt.Equal(session.LastUsedAt) == false {
+		t.Errorf("Expected the revoked session to stay unchanged, got %+v", stored)
+	}
+}
+
+func TestRevokeAll(t *testing.T) {
+	s := newTestSessions()
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		session, _, err := s.Start("user", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, session.ID)
+	}
+
+	if revoked, err := s.RevokeAll("user", ids[0]); err != nil || revoked != 2 {
+		t.Errorf("Expected 2 revoked sessions, got %d, %v", revoked, err)
+	}
+
+	active, err := s.Active("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].ID != ids[0] {
+		t.Errorf("Expected only the kept session to be active, got %v", active)
+	}
+}
+
+func TestRevocationStoreExpiry(t *testing.T) {
+	store := NewMemoryRevocationStore().(*memoryRevocationStore)
+	now := time.Now()
+
+	if err := store.Revoke("expired", now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Revoke("token", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id      string
+		at      time.Time
+		revoked bool
+	}{
+		{"token", now, true},
+		{"token", now.Add(time.Minute - time.Nanosecond), true},
+		{"token", now.Add(time.Minute), false},
+		{"expired", now, false},
+		{"unknown", now, false},
+	}
+	for _, test := range tests {
+		if revoked, err := store.IsRevoked(test.id, test.at); err != nil || revoked != test.revoked {
+			t.Errorf("%s at %v: expected %v, got %v, %v", test.id, test.at, test.revoked, revoked, err)
+		}
+	}
+
+	// expired revocations are removed by the next revocation
+	if err := store.Revoke("other", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.revoked["expired"]; ok || len(store.revoked) != 2 {
+		t.Errorf("Expected the expired revocation to be removed, got %v", store.revoked)
+	}
+}
+
+func TestRevocationListCache(t *testing.T) {
+	store := NewMemoryRevocationStore()
+	list := NewRevocationList(RevocationListParams{Store: store, CacheTTL: time.Minute})
+
+	if err := list.Revoke("token", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := list.IsRevoked("token"); err != nil || revoked == false {
+		t.Errorf("Expected the token to be revoked, got %v, %v", revoked, err)
+	}
+
+	// the revocations of other instances are seen once the cached lookup expired
+	if revoked, err := list.IsRevoked("other"); err != nil || revoked {
+		t.Errorf("Expected the token not to be revoked, got %v, %v", revoked, err)
+	}
+	if err := store.Revoke("other", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := list.IsRevoked("other"); revoked {
+		t.Error("Expected the lookup to be cached")
+	}
+	list.cache["other"] = cacheEntry{expiresAt: time.Now().Add(-time.Second)}
+	if revoked, err := list.IsRevoked("other"); err != nil || revoked == false {
+		t.Errorf("Expected the revocation to be seen after the cache expired, got %v, %v", revoked, err)
+	}
+
+	// revocations end when the access tokens they cover expire
+	if err := list.Revoke("expired", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := list.IsRevoked("expired"); err != nil || revoked {
+		t.Errorf("Expected the expired revocation to be ignored, got %v, %v", revoked, err)
+	}
+}
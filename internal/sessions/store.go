@@ -0,0 +1,138 @@
+package sessions
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	CreateSession(session *Session) error
+	// Session returns the session with the ID or ErrNotFound.
+	Session(id string) (*Session, error)
+	// SessionsByUser returns the sessions of the user, oldest first.
+	SessionsByUser(userID string) ([]*Session, error)
+	// RevokeSession marks the session as revoked unless it already is and returns it. It returns
+	// ErrNotFound for unknown sessions.
+	RevokeSession(id string, now time.Time) (*Session, error)
+
+	SaveRefreshToken(token *RefreshToken) error
+	// RotateRefreshToken exchanges the token with the hash for the next one in a single step: it marks
+	// the token as used, records the use of its session and saves the next token for the session. It
+	// returns the token as it was before and the current session, so that of two concurrent refreshes
+	// with the same token only one sees it unused. Nothing is changed when the token was already used
+	// or the session is not active. It returns ErrNotFound for unknown tokens and sessions.
+	RotateRefreshToken(tokenHash, nextTokenHash string, now time.Time) (*RefreshToken, *Session, error)
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu            sync.Mutex
+	sessions      map[string]Session
+	refreshTokens map[string]RefreshToken
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		sessions:      make(map[string]Session),
+		refreshTokens: make(map[string]RefreshToken),
+	}
+}
+
+func (s *memoryStore) CreateSession(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.ID] = *session
+	return nil
+}
+
+func (s *memoryStore) Session(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	return &session, nil
+}
+
+func (s *memoryStore) SessionsByUser(userID string) ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*Session
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			session := session
+			result = append(result, &session)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+func (s *memoryStore) RevokeSession(id string, now time.Time) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	if session.RevokedAt == nil {
+		session.RevokedAt = &now
+		s.sessions[id] = session
+	}
+
+	return &session, nil
+}
+
+func (s *memoryStore) SaveRefreshToken(token *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshTokens[token.TokenHash] = *token
+	return nil
+}
+
+func (s *memoryStore) RotateRefreshToken(tokenHash, nextTokenHash string, now time.Time) (*RefreshToken, *Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[tokenHash]
+	if ok == false {
+		return nil, nil, ErrNotFound
+	}
+
+	session, ok := s.sessions[token.SessionID]
+	if ok == false {
+		return nil, nil, ErrNotFound
+	}
+
+	if token.UsedAt != nil || session.IsActive(now) == false {
+		return &token, &session, nil
+	}
+
+	used := token
+	used.UsedAt = &now
+	s.refreshTokens[tokenHash] = used
+
+	session.LastUsedAt = now
+	s.sessions[session.ID] = session
+
+	s.refreshTokens[nextTokenHash] = RefreshToken{
+		TokenHash: nextTokenHash,
+		SessionID: session.ID,
+		CreatedAt: now,
+	}
+
+	return &token, &session, nil
+}
@@ -0,0 +1,193 @@
+package shorteners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxHops = 5
+	defaultTimeout = 5 * time.Second
+	// maxDrainSize is read from the bodies of the redirects so that the connections can be reused
+	maxDrainSize = 4 << 10
+)
+
+var (
+	ErrShortener   = errors.New("links to other URL shorteners are not allowed - shorten the final destination instead")
+	ErrTooManyHops = errors.New("the short URL redirects too many times")
+)
+
+type Params struct {
+	// OwnDomains are the domains this service is reachable on. Links to them would redirect back to it.
+	OwnDomains []string
+	// Domains are the known URL shorteners. A domain matches itself only, "*.example.com" matches the
+	// subdomains of example.com.
+	Domains []string
+	// Resolve follows the redirects of the known shorteners to the final destination instead of
+	// rejecting them. Only the hosts on the shortener list are requested.
+	Resolve bool
+	// MaxHops is the number of redirects followed, 5 by default
+	MaxHops int
+	// Check validates every hop before it is requested or returned, e.g. with the destination policy
+	Check func(rawURL string) error
+	// HTTPClient sends the requests to the shorteners. It defaults to a client with a 5 seconds timeout.
+	// Its redirect policy is replaced, as the redirects are followed one hop at a time.
+	HTTPClient *http.Client
+}
+
+// Resolver keeps the short links from pointing back at this service or hiding their destination
+// behind other URL shorteners.
+type Resolver struct {
+	ownDomains []string
+	domains    []string
+	resolve    bool
+	maxHops    int
+	check      func(rawURL string) error
+	client     *http.Client
+}
+
+func New(params Params) *Resolver {
+	if params.MaxHops <= 0 {
+		params.MaxHops = defaultMaxHops
+	}
+	if params.Check == nil {
+		params.Check = func(string) error { return nil }
+	}
+
+	client := &http.Client{Timeout: defaultTimeout}
+	if params.HTTPClient != nil {
+		copied := *params.HTTPClient
+		client = &copied
+	}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &Resolver{
+		ownDomains: normalizeDomains(params.OwnDomains),
+		domains:    normalizeDomains(params.Domains),
+		resolve:    params.Resolve,
+		maxHops:    params.MaxHops,
+		check:      params.Check,
+		client:     client,
+	}
+}
+
+// Resolve returns the destination to be stored for the URL. URLs of other shorteners are resolved to
+// the first URL outside of them when resolving is enabled and rejected otherwise. URLs on the own
+// domains are always rejected. The errors are meant for the client.
+func (r *Resolver) Resolve(ctx context.Context, rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if err = r.checkOwnDomain(parsed); err != nil {
+		return "", err
+	}
+	if r.isShortener(parsed) == false {
+		return rawURL, nil
+	}
+	if r.resolve == false {
+		return "", ErrShortener
+	}
+
+	seen := map[string]bool{parsed.String(): true}
+	for hop := 0; hop < r.maxHops; hop++ {
+		next, err := r.follow(ctx, parsed)
+		if err != nil {
+			return "", err
+		}
+		if seen[next.String()] {
+			return "", fmt.Errorf("the short URL %s redirects in a loop", rawURL)
+		}
+		seen[next.String()] = true
+
+		if err = r.check(next.String()); err != nil {
+			return "", fmt.Errorf("the short URL %s redirects to a destination which is not allowed: %w", rawURL, err)
+		}
+		if err = r.checkOwnDomain(next); err != nil {
+			return "", err
+		}
+		if r.isShortener(next) == false {
+			return next.String(), nil
+		}
+		parsed = next
+	}
+
+	return "", ErrTooManyHops
+}
+
+// follow requests the URL and returns the location it redirects to
+func (r *Resolver) follow(ctx context.Context, current *url.URL) (*url.URL, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, current.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the short URL %s", current)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxDrainSize))
+
+	location := response.Header.Get("Location")
+	if response.StatusCode < 300 || response.StatusCode > 399 || location == "" {
+		return nil, fmt.Errorf("failed to resolve the short URL %s - it does not redirect", current)
+	}
+
+	next, err := current.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the short URL %s - it redirects to an invalid URL", current)
+	}
+
+	return next, nil
+}
+
+func (r *Resolver) checkOwnDomain(u *url.URL) error {
+	if host := hostOf(u); matchesAny(host, r.ownDomains) {
+		return fmt.Errorf("links to %s are not allowed - they would redirect back to this service", host)
+	}
+
+	return nil
+}
+
+func (r *Resolver) isShortener(u *url.URL) bool {
+	return matchesAny(hostOf(u), r.domains)
+}
+
+func hostOf(u *url.URL) string {
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+func normalizeDomains(domains []string) []string {
+	result := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			result = append(result, domain)
+		}
+	}
+
+	return result
+}
+
+func matchesAny(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+
+	return false
+}
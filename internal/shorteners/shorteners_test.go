@@ -0,0 +1,151 @@
+package shorteners
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// standIn is a stand-in for the known URL shorteners. Every host is routed to it and it redirects by
+// the host and the path of the request.
+type standIn struct {
+	mu        sync.Mutex
+	redirects map[string]string
+	requested []string
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.Host + r.URL.Path
+	s.requested = append(s.requested, key)
+
+	location, ok := s.redirects[key]
+	if ok == false {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+func (s *standIn) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requested...)
+}
+
+func newTestResolver(t *testing.T, redirects map[string]string, params Params) (*Resolver, *standIn) {
+	t.Helper()
+
+	shorteners := &standIn{redirects: redirects}
+	server := httptest.NewServer(shorteners)
+	t.Cleanup(server.Close)
+
+	address := server.Listener.Addr().String()
+	var dialer net.Dialer
+	params.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+		},
+	}
+	if params.Domains == nil {
+		params.Domains = []string{"sho.rt", "*.tiny.test"}
+	}
+
+	return New(params), shorteners
+}
+
+func TestResolve(t *testing.T) {
+	redirects := map[string]string{
+		"sho.rt/chain":      "http://go.tiny.test/next",
+		"go.tiny.test/next": "https://example.com/final?a=1",
+		"sho.rt/relative":   "/direct",
+		"sho.rt/direct":     "https://example.com/final?a=1",
+		"sho.rt/loop":       "http://go.tiny.test/loop",
+		"go.tiny.test/loop": "http://sho.rt/loop",
+		"sho.rt/home":       "https://lynkly.test/abc",
+		"sho.rt/private":    "http://10.0.0.1/admin",
+		"sho.rt/long":       "http://sho.rt/longer",
+		"sho.rt/longer":     "http://sho.rt/longest",
+		"sho.rt/longest":    "https://example.com/",
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		destination string
+		err         string
+	}{
+		{"not a shortener", "https://example.com/page", "https://example.com/page", ""},
+		{"chain", "http://sho.rt/chain", "https://example.com/final?a=1", ""},
+		{"relative redirect", "http://sho.rt/relative", "https://example.com/final?a=1", ""},
+		{"loop", "http://sho.rt/loop", "", "redirects in a loop"},
+		{"own domain", "https://www.lynkly.test/abc", "", "redirect back to this service"},
+		{"own domain behind a shortener", "http://sho.rt/home", "", "redirect back to this service"},
+		{"destination not allowed", "http://sho.rt/private", "", "not allowed: private"},
+		{"does not redirect", "http://sho.rt/missing", "", "does not redirect"},
+		{"too many hops", "http://sho.rt/long", "", ErrTooManyHops.Error()},
+	}
+
+	resolver, _ := newTestResolver(t, redirects, Params{
+		OwnDomains: []string{"lynkly.test", "*.lynkly.test"},
+		Resolve:    true,
+		MaxHops:    2,
+		Check: func(rawURL string) error {
+			if strings.Contains(rawURL, "10.0.0.1") {
+				return errors.New("private")
+			}
+			return nil
+		},
+	})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			destination, err := resolver.Resolve(context.Background(), test.url)
+			if test.err == "" && (err != nil || destination != test.destination) {
+				t.Errorf("Expected %s, got %q, %v", test.destination, destination, err)
+			} else if test.err != "" && (err == nil || strings.Contains(err.Error(), test.err) == false) {
+				t.Errorf("Expected an error containing %q, got %q, %v", test.err, destination, err)
+			}
+		})
+	}
+}
+
+func TestResolveDisabled(t *testing.T) {
+	resolver, shorteners := newTestResolver(t, map[string]string{
+		"sho.rt/abc": "https://example.com/",
+	}, Params{})
+
+	if _, err := resolver.Resolve(context.Background(), "http://sho.rt/abc"); err != ErrShortener {
+		t.Errorf("Expected ErrShortener, got %v", err)
+	}
+
+	if requests := shorteners.requests(); len(requests) != 0 {
+		t.Errorf("Expected no requests to the shortener, got %v", requests)
+	}
+}
+
+func TestResolveRequestsOnlyShorteners(t *testing.T) {
+	resolver, shorteners := newTestResolver(t, map[string]string{
+		"sho.rt/abc": "https://example.com/page",
+	}, Params{Resolve: true})
+
+	if _, err := resolver.Resolve(context.Background(), "http://sho.rt/abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if requests := shorteners.requests(); len(requests) != 1 || requests[0] != "sho.rt/abc" {
+		t.Errorf("Expected the shortener to be requested once, got %v", requests)
+	}
+}
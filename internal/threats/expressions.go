@@ -0,0 +1,215 @@
+package threats
+
+import (
+	"net"
+	"strings"
+)
+
+const (
+	maxHostSuffixes = 5
+	maxPathPrefixes = 4
+)
+
+// expressions returns the host suffix and path prefix expressions of the URL looked up in the hash
+// prefix lists, following the canonicalization of the Safe Browsing API in a simplified form.
+func expressions(rawURL string) []string {
+	host, path, query, ok := canonicalize(rawURL)
+	if ok == false {
+		return nil
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, hostSuffix := range hostSuffixes(host) {
+		for _, pathPrefix := range pathPrefixes(path, query) {
+			expression := hostSuffix + pathPrefix
+			if seen[expression] == false {
+				seen[expression] = true
+				result = append(result, expression)
+			}
+		}
+	}
+
+	return result
+}
+
+// canonicalize returns the lower case host and the fully unescaped and re-escaped path and query of
+// the URL, with the dot segments resolved. The URL is split by hand rather than parsed, since the
+// malformed escapes and escaped hosts of malicious URLs are canonicalized instead of rejected.
+func canonicalize(rawURL string) (string, string, string, bool) {
+	rawURL = strings.NewReplacer("\t", "", "\r", "", "\n", "").Replace(strings.TrimSpace(rawURL))
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+
+	i := strings.Index(rawURL, "://")
+	if i <= 0 {
+		return "", "", "", false
+	}
+	rest := rawURL[i+len("://"):]
+
+	authority, path, query := rest, "", ""
+	if i = strings.IndexAny(rest, "/?"); i >= 0 {
+		authority, path = rest[:i], rest[i:]
+	}
+	if i = strings.IndexByte(path, '?'); i >= 0 {
+		path, query = path[:i], path[i+1:]
+	}
+
+	host := strings.ToLower(unescapeAll(hostname(authority)))
+	host = strings.Trim(host, ".")
+	for strings.Contains(host, "..") {
+		host = strings.ReplaceAll(host, "..", ".")
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+
+	path = resolvePath(unescapeAll(path))
+	if query != "" {
+		query = escape(unescapeAll(query))
+	}
+
+	return escape(host), escape(path), query, host != ""
+}
+
+// hostname returns the host of the authority without the user info, the port and the brackets of
+// IPv6 addresses
+func hostname(authority string) string {
+	if i := strings.LastIndexByte(authority, '@'); i >= 0 {
+		authority = authority[i+1:]
+	}
+
+	if strings.HasPrefix(authority, "[") {
+		if i := strings.IndexByte(authority, ']'); i >= 0 {
+			return authority[1:i]
+		}
+	}
+	if i := strings.LastIndexByte(authority, ':'); i >= 0 {
+		return authority[:i]
+	}
+
+	return authority
+}
+
+// unescapeAll percent-unescapes the value until it does not change anymore. Percent signs which do not
+// start a valid escape are kept.
+func unescapeAll(value string) string {
+	for i := 0; i < 10; i++ {
+		unescaped := unescape(value)
+		if unescaped == value {
+			return value
+		}
+		value = unescaped
+	}
+
+	return value
+}
+
+func unescape(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+2 < len(value) && isHex(value[i+1]) && isHex(value[i+2]) {
+			builder.WriteByte(unhex(value[i+1])<<4 | unhex(value[i+2]))
+			i += 2
+		} else {
+			builder.WriteByte(value[i])
+		}
+	}
+
+	return builder.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+
+	return c - 'A' + 10
+}
+
+// resolvePath resolves the "." and ".." segments and collapses repeated slashes, keeping a trailing slash
+func resolvePath(path string) string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "." {
+			continue
+		} else if segment == ".." {
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	resolved := "/" + strings.Join(segments, "/")
+	if len(segments) > 0 && (strings.HasSuffix(path, "/") || strings.HasSuffix(path, "/.") || strings.HasSuffix(path, "/..")) {
+		resolved += "/"
+	}
+
+	return resolved
+}
+
+// escape percent-escapes the control characters, spaces, non-ASCII bytes, "#" and "%"
+func escape(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c <= 0x20 || c >= 0x7f || c == '#' || c == '%' {
+			builder.WriteByte('%')
+			builder.WriteByte("0123456789ABCDEF"[c>>4])
+			builder.WriteByte("0123456789ABCDEF"[c&0x0f])
+		} else {
+			builder.WriteByte(c)
+		}
+	}
+
+	return builder.String()
+}
+
+// hostSuffixes returns the host and the suffixes formed from its last five components, excluding the
+// top-level domain. IP addresses are looked up as they are.
+func hostSuffixes(host string) []string {
+	if net.ParseIP(host) != nil {
+		return []string{host}
+	}
+
+	result := []string{host}
+	components := strings.Split(host, ".")
+	start := len(components) - maxHostSuffixes
+	if start < 1 {
+		start = 1
+	}
+	for i := start; i < len(components)-1; i++ {
+		result = append(result, strings.Join(components[i:], "."))
+	}
+
+	return result
+}
+
+// pathPrefixes returns the path with and without the query and the prefixes formed from its first
+// components.
+func pathPrefixes(path, query string) []string {
+	var result []string
+	if query != "" {
+		result = append(result, path+"?"+query)
+	}
+	result = append(result, path, "/")
+
+	components := strings.Split(strings.Trim(path, "/"), "/")
+	prefix := "/"
+	for i := 0; i < len(components)-1 && i < maxPathPrefixes-1; i++ {
+		prefix += components[i] + "/"
+		result = append(result, prefix)
+	}
+
+	return result
+}
@@ -0,0 +1,254 @@
+package threats
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"lynkly-backend/internal/logging"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReloadInterval = time.Minute
+	minHashPrefixLength   = 4
+)
+
+// Match is the threat list on which a URL was found.
+type Match struct {
+	// List is the name of the list file without its extension, e.g. "phishing" for phishing.txt
+	List string `json:"list"`
+	// Expression is the listed domain or the URL expression whose hash is listed
+	Expression string `json:"expression"`
+}
+
+type Params struct {
+	Logger logging.Logger
+	// DomainLists are files with one domain per line. A listed domain covers its subdomains. Lines may
+	// be in the hosts file format, e.g. "0.0.0.0 phishing.example", and "#" starts a comment.
+	DomainLists []string
+	// HashPrefixLists are files with one hex encoded SHA-256 hash prefix of 4 to 32 bytes per line,
+	// computed over the URL expressions of the Safe Browsing API, e.g. "phishing.example/login/".
+	HashPrefixLists []string
+	// ReloadInterval is how often the files are checked for changes
+	ReloadInterval time.Duration
+}
+
+type list struct {
+	name    string
+	domains map[string]bool
+	// prefixes are grouped by their length in bytes
+	prefixes map[int]map[string]bool
+}
+
+// Screener looks up URLs in locally loaded threat lists. The lists are reloaded when their files change.
+type Screener struct {
+	logger         logging.Logger
+	domainFiles    []string
+	prefixFiles    []string
+	reloadInterval time.Duration
+
+	mu       sync.RWMutex
+	lists    []*list
+	modTimes map[string]time.Time
+}
+
+// New loads the lists and fails when any of them cannot be read.
+func New(params Params) (*Screener, error) {
+	if params.ReloadInterval <= 0 {
+		params.ReloadInterval = defaultReloadInterval
+	}
+
+	screener := &Screener{
+		logger:         params.Logger,
+		domainFiles:    params.DomainLists,
+		prefixFiles:    params.HashPrefixLists,
+		reloadInterval: params.ReloadInterval,
+	}
+	if err := screener.load(); err != nil {
+		return nil, err
+	}
+
+	return screener, nil
+}
+
+// IsEnabled reports whether any list is configured.
+func (s *Screener) IsEnabled() bool {
+	return len(s.domainFiles)+len(s.prefixFiles) > 0
+}
+
+// Check returns the first list the URL is found on, or nil.
+func (s *Screener) Check(rawURL string) *Match {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.lists) == 0 {
+		return nil
+	}
+
+	host, _, _, ok := canonicalize(rawURL)
+	if ok == false {
+		return nil
+	}
+
+	hashes := make(map[string]string)
+	for _, expression := range expressions(rawURL) {
+		sum := sha256.Sum256([]byte(expression))
+		hashes[expression] = string(sum[:])
+	}
+
+	for _, l := range s.lists {
+		for _, suffix := range hostSuffixes(host) {
+			if l.domains[suffix] {
+				return &Match{List: l.name, Expression: suffix}
+			}
+		}
+
+		for expression, hash := range hashes {
+			for length, prefixes := range l.prefixes {
+				if prefixes[hash[:length]] {
+					return &Match{List: l.name, Expression: expression}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Run reloads the lists whose files changed until the context is cancelled. A list which cannot be
+// reloaded is kept as it is.
+func (s *Screener) Run(ctx context.Context) {
+	if s.IsEnabled() == false {
+		return
+	}
+
+	ticker := time.NewTicker(s.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.changed() == false {
+				continue
+			}
+
+			if err := s.load(); err != nil {
+				s.logger.Error("Failed to reload the threat lists: ", err)
+				continue
+			}
+			s.logger.Info("Reloaded the threat lists")
+		}
+	}
+}
+
+func (s *Screener) changed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, file := range append(append([]string(nil), s.domainFiles...), s.prefixFiles...) {
+		info, err := os.Stat(file)
+		if err != nil || info.ModTime().Equal(s.modTimes[file]) == false {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Screener) load() error {
+	var lists []*list
+	modTimes := make(map[string]time.Time)
+	for _, file := range s.domainFiles {
+		l, modTime, err := loadList(file, parseDomain)
+		if err != nil {
+			return err
+		}
+		lists, modTimes[file] = append(lists, l), modTime
+	}
+	for _, file := range s.prefixFiles {
+		l, modTime, err := loadList(file, parseHashPrefix)
+		if err != nil {
+			return err
+		}
+		lists, modTimes[file] = append(lists, l), modTime
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lists = lists
+	s.modTimes = modTimes
+	return nil
+}
+
+// loadList reads the file and adds each line to the list with the parse function
+func loadList(file string, parse func(l *list, line string) error) (*list, time.Time, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	l := &list{
+		name:     strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)),
+		domains:  make(map[string]bool),
+		prefixes: make(map[int]map[string]bool),
+	}
+
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+
+		if err = parse(l, line); err != nil {
+			return nil, time.Time{}, fmt.Errorf("%s:%d: %w", file, number, err)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return l, info.ModTime(), nil
+}
+
+func parseDomain(l *list, line string) error {
+	fields := strings.Fields(line)
+	domain := strings.Trim(strings.ToLower(fields[len(fields)-1]), ".")
+	if domain == "" || domain == "localhost" {
+		return nil
+	}
+
+	l.domains[domain] = true
+	return nil
+}
+
+func parseHashPrefix(l *list, line string) error {
+	prefix, err := hex.DecodeString(line)
+	if err != nil || len(prefix) < minHashPrefixLength || len(prefix) > sha256.Size {
+		return fmt.Errorf("invalid hash prefix %q - expected 4 to 32 hex encoded bytes", line)
+	}
+
+	if l.prefixes[len(prefix)] == nil {
+		l.prefixes[len(prefix)] = make(map[string]bool)
+	}
+	l.prefixes[len(prefix)][string(prefix)] = true
+	return nil
+}
@@ -0,0 +1,95 @@
+package users
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=1,p=4$") == false {
+		t.Errorf("Expected a PHC string with the argon2id parameters, got %s", hash)
+	}
+
+	if ok, err := VerifyPassword("correct horse", hash); err != nil || ok == false {
+		t.Errorf("Expected the password to match, got %v, %v", ok, err)
+	}
+	if ok, err := VerifyPassword("correct horsE", hash); err != nil || ok {
+		t.Errorf("Expected another password not to match, got %v, %v", ok, err)
+	}
+
+	other, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Error("Expected the hashes of the same password to differ by their salt")
+	}
+}
+
+func TestVerifyPasswordWithStoredParameters(t *testing.T) {
+	// a test vector of the argon2 reference implementation, made with other parameters than the current ones
+	hash := "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+
+	if ok, err := VerifyPassword("password", hash); err != nil || ok == false {
+		t.Errorf("Expected the password to match the hash of its parameters, got %v, %v", ok, err)
+	}
+}
+
+func TestVerifyPasswordRejectsMalformedHashes(t *testing.T) {
+	tests := map[string]string{
+		"empty":         "",
+		"bcrypt":        "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		"argon2i":       "$argon2i$v=19$m=16,t=2,p=1$c29tZXNhbHQ$iRjpnOcJDb8+PJqXZqnbbw",
+		"other version": "$argon2id$v=16$m=16,t=2,p=1$c29tZXNhbHQ$iRjpnOcJDb8+PJqXZqnbbw",
+		"no parameters": "$argon2id$v=19$$c29tZXNhbHQ$iRjpnOcJDb8+PJqXZqnbbw",
+		"invalid salt":  "$argon2id$v=19$m=16,t=2,p=1$c29tZX*hbHQ$iRjpnOcJDb8+PJqXZqnbbw",
+		"empty hash":    "$argon2id$v=19$m=16,t=2,p=1$c29tZXNhbHQ$",
+		"missing part":  "$argon2id$v=19$m=16,t=2,p=1$iRjpnOcJDb8+PJqXZqnbbw",
+	}
+
+	for name, hash := range tests {
+		if ok, err := VerifyPassword("password", hash); err != ErrMalformedHash || ok {
+			t.Errorf("%s: expected ErrMalformedHash, got %v, %v", name, ok, err)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	u := New(Params{Store: NewMemoryStore()})
+
+	user, err := u.Register(" Jane@Example.com ", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "jane@example.com" {
+		t.Errorf("Expected the email to be normalized, got %s", user.Email)
+	}
+
+	if _, err = u.Authenticate("JANE@example.com", "correct horse"); err != nil {
+		t.Errorf("Expected the credentials to be valid, got %v", err)
+	}
+
+	tests := map[string][2]string{
+		"wrong password":    {"jane@example.com", "wrong horse"},
+		"unknown email":     {"john@example.com", "correct horse"},
+		"invalid email":     {"jane", "correct horse"},
+		"too long password": {"jane@example.com", strings.Repeat("a", MaxPasswordLength+1)},
+	}
+	for name, credentials := range tests {
+		if _, err = u.Authenticate(credentials[0], credentials[1]); err != ErrInvalidCredentials {
+			t.Errorf("%s: expected ErrInvalidCredentials, got %v", name, err)
+		}
+	}
+
+	if err = u.Reauthenticate(user.ID, "wrong horse"); err != ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	if err = u.Reauthenticate(user.ID, "correct horse"); err != nil {
+		t.Errorf("Expected the password to be confirmed, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package users
+
+import (
+	"sync"
+)
+
+type Store interface {
+	// Create stores a new user and returns ErrEmailTaken when the email is already registered.
+	Create(user *User) error
+	// Get returns the user with the ID or ErrNotFound.
+	Get(id string) (*User, error)
+	// ByEmail returns the user with the normalized email or ErrNotFound.
+	ByEmail(email string) (*User, error)
+	// ByExternalID returns the user provisioned for the identity provider subject or ErrNotFound.
+	ByExternalID(issuer, subject string) (*User, error)
+	// Update replaces a stored user and returns ErrNotFound when it does not exist.
+	Update(user *User) error
+	// Delete removes the user with the ID and returns ErrNotFound when it does not exist.
+	Delete(id string) error
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{users: make(map[string]User)}
+}
+
+func (s *memoryStore) Create(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Email == user.Email {
+			return ErrEmailTaken
+		}
+	}
+
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *memoryStore) Get(id string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (s *memoryStore) ByEmail(email string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) ByExternalID(issuer, subject string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.ExternalIssuer == issuer && user.ExternalSubject == subject {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) Update(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.ID]; ok == false {
+		return ErrNotFound
+	}
+
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *memoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; ok == false {
+		return ErrNotFound
+	}
+
+	delete(s.users, id)
+	return nil
+}
@@ -0,0 +1,246 @@
+package users
+
+import (
+	"errors"
+	"lynkly-backend/internal/common"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	MinPasswordLength = 8
+	// MaxPasswordLength bounds the work done for hashing a password sent by a client
+	MaxPasswordLength = 128
+	maxEmailLength    = 254
+)
+
+var (
+	ErrNotFound           = errors.New("user not found")
+	ErrEmailTaken         = errors.New("email is already registered")
+	ErrInvalidEmail       = errors.New("invalid email address")
+	ErrInvalidPassword    = errors.New("invalid password - expected between 8 and 128 characters")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrEmailNotVerified is returned when single sign-on would take over a registered email which the
+	// identity provider has not verified.
+	ErrEmailNotVerified = errors.New("email is already registered and is not verified by the identity provider")
+)
+
+// User is an account of the service. Only the argon2id hash of the password is stored. Users
+// provisioned by single sign-on have no password and are identified by their identity provider
+// subject instead.
+type User struct {
+	ID              string    `json:"id"`
+	Email           string    `json:"email"`
+	PasswordHash    string    `json:"-"`
+	ExternalIssuer  string    `json:"-"`
+	ExternalSubject string    `json:"-"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// ExternalIdentity is a user authenticated by an identity provider.
+type ExternalIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type Params struct {
+	Store Store
+}
+
+// Users registers users and verifies their credentials.
+type Users struct {
+	store Store
+	// dummyHash is verified for unknown emails so that the login timing does not reveal which emails
+	// are registered
+	dummyHash string
+}
+
+func New(params Params) *Users {
+	dummyHash, err := HashPassword(common.RandomHex(16))
+	if err != nil {
+		panic(err)
+	}
+
+	return &Users{
+		store:     params.Store,
+		dummyHash: dummyHash,
+	}
+}
+
+func (u *Users) Store() Store {
+	return u.store
+}
+
+// Register creates a user with the email and password.
+func (u *Users) Register(email, password string) (*User, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := hashValidPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	user := &User{
+		ID:           common.NewID(),
+		Email:        email,
+		PasswordHash: passwordHash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err = u.store.Create(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Authenticate returns the user with the email and password or ErrInvalidCredentials.
+func (u *Users) Authenticate(email, password string) (*User, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	user, err := u.store.ByEmail(email)
+	if err == ErrNotFound {
+		_, _ = VerifyPassword(password, u.dummyHash)
+		return nil, ErrInvalidCredentials
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = u.verify(user, password); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Provision returns the user of the external identity, creating it on its first login. The email of
+// the user is kept in sync with the identity provider. An existing user with the same
+// email is linked only when the identity provider has verified the email.
+func (u *Users) Provision(identity ExternalIdentity) (*User, error) {
+	email, err := NormalizeEmail(identity.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	user, err := u.store.ByExternalID(identity.Issuer, identity.Subject)
+	if err == ErrNotFound {
+		user, err = u.store.ByEmail(email)
+		if err == nil && identity.EmailVerified == false {
+			return nil, ErrEmailNotVerified
+		}
+	}
+
+	if err == ErrNotFound {
+		user = &User{
+			ID:              common.NewID(),
+			Email:           email,
+			ExternalIssuer:  identity.Issuer,
+			ExternalSubject: identity.Subject,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		if err = u.store.Create(user); err != nil {
+			return nil, err
+		}
+
+		return user, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// the email is not changed when the identity provider moved it to an address of another user
+	if owner, err := u.store.ByEmail(email); err == ErrNotFound || (err == nil && owner.ID == user.ID) {
+		user.Email = email
+	}
+	user.ExternalIssuer = identity.Issuer
+	user.ExternalSubject = identity.Subject
+	user.UpdatedAt = now
+	if err = u.store.Update(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ChangePassword replaces the password of the user after verifying the current one.
+func (u *Users) ChangePassword(id, currentPassword, newPassword string) error {
+	user, err := u.store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err = u.verify(user, currentPassword); err != nil {
+		return err
+	}
+
+	if user.PasswordHash, err = hashValidPassword(newPassword); err != nil {
+		return err
+	}
+	user.UpdatedAt = time.Now().UTC()
+
+	return u.store.Update(user)
+}
+
+// Reauthenticate verifies the password of the signed in user again, e.g. before the account is deleted.
+func (u *Users) Reauthenticate(id, password string) error {
+	user, err := u.store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	return u.verify(user, password)
+}
+
+func (u *Users) verify(user *User, password string) error {
+	// single sign-on users have no password to log in with
+	if user.PasswordHash == "" || len(password) > MaxPasswordLength {
+		return ErrInvalidCredentials
+	}
+
+	ok, err := VerifyPassword(password, user.PasswordHash)
+	if err != nil {
+		return err
+	}
+
+	if ok == false {
+		return ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+// NormalizeEmail validates a bare email address and lowercases it, so that it can be compared.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || len(email) > maxEmailLength {
+		return "", ErrInvalidEmail
+	}
+
+	// display names such as "Jane <jane@example.com>" are not accepted
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", ErrInvalidEmail
+	}
+
+	return email, nil
+}
+
+func hashValidPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return "", ErrInvalidPassword
+	}
+
+	return HashPassword(password)
+}
@@ -0,0 +1,384 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/destinations"
+	"lynkly-backend/internal/logging"
+	"lynkly-backend/internal/models/lhttp"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMaxAttempts    = 8
+	defaultInitialBackoff = 10 * time.Second
+	defaultMaxBackoff     = time.Hour
+	defaultPollInterval   = time.Second
+	defaultWorkers        = 4
+	defaultTimeout        = 10 * time.Second
+	defaultRetention      = 7 * 24 * time.Hour
+	// cleanupInterval is how often finished deliveries are pruned and stale deliveries are reclaimed
+	cleanupInterval = time.Minute
+	// staleAfter is how long a delivery may stay in progress before it is assumed to be lost, e.g. by a
+	// crash, and is queued again. It is well above the timeout of the default client.
+	staleAfter = 5 * time.Minute
+)
+
+var ErrNotDead = errors.New("only dead deliveries can be retried")
+
+type Params struct {
+	Logger logging.Logger
+	Store  Store
+	// Client sends the deliveries. It defaults to a client with a 10 seconds timeout, which refuses to
+	// connect to addresses which are not public unless AllowPrivateNetworks is set.
+	Client         *http.Client
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	PollInterval   time.Duration
+	Workers        int
+	// Retention is how long succeeded and dead deliveries are kept. It defaults to 7 days.
+	Retention time.Duration
+	// AllowPrivateNetworks accepts receivers on loopback, private and link-local addresses, e.g. for
+	// local testing
+	AllowPrivateNetworks bool
+}
+
+// Dispatcher delivers events to the webhook subscriptions of a workspace. Failed deliveries are
+// retried with exponential backoff and moved to the dead-letter list after the last attempt.
+type Dispatcher struct {
+	logger               logging.Logger
+	store                Store
+	client               *http.Client
+	maxAttempts          int
+	initialBackoff       time.Duration
+	maxBackoff           time.Duration
+	pollInterval         time.Duration
+	retention            time.Duration
+	workers              chan struct{}
+	wake                 chan struct{}
+	allowPrivateNetworks bool
+}
+
+func New(params Params) *Dispatcher {
+	d := &Dispatcher{
+		logger:               params.Logger,
+		store:                params.Store,
+		client:               params.Client,
+		maxAttempts:          params.MaxAttempts,
+		initialBackoff:       params.InitialBackoff,
+		maxBackoff:           params.MaxBackoff,
+		pollInterval:         params.PollInterval,
+		retention:            params.Retention,
+		wake:                 make(chan struct{}, 1),
+		allowPrivateNetworks: params.AllowPrivateNetworks,
+	}
+
+	if d.client == nil && d.allowPrivateNetworks {
+		d.client = &http.Client{Timeout: defaultTimeout}
+	} else if d.client == nil {
+		d.client = newPublicClient()
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = defaultMaxAttempts
+	}
+	if d.initialBackoff <= 0 {
+		d.initialBackoff = defaultInitialBackoff
+	}
+	if d.maxBackoff <= 0 {
+		d.maxBackoff = defaultMaxBackoff
+	}
+	if d.pollInterval <= 0 {
+		d.pollInterval = defaultPollInterval
+	}
+	if d.retention <= 0 {
+		d.retention = defaultRetention
+	}
+	if params.Workers <= 0 {
+		params.Workers = defaultWorkers
+	}
+	d.workers = make(chan struct{}, params.Workers)
+
+	return d
+}
+
+func (d *Dispatcher) Store() Store {
+	return d.store
+}
+
+// CheckURL returns ErrInvalidURL or ErrPrivateNetwork when the URL cannot receive webhooks. Host names
+// are not resolved here, the addresses they resolve to are checked on every delivery instead.
+func (d *Dispatcher) CheckURL(rawURL string) error {
+	parsedUrl, err := url.Parse(rawURL)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsedUrl.Hostname()), ".")
+	if d.allowPrivateNetworks == false && destinations.IsPublicHost(host) == false {
+		return ErrPrivateNetwork
+	}
+
+	return nil
+}
+
+// Dispatch queues a delivery of the event for every subscription of the workspace accepting it.
+func (d *Dispatcher) Dispatch(workspaceID string, eventType EventType, data interface{}) error {
+	subscriptions, err := d.store.Subscriptions(workspaceID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	event := Event{
+		ID:          common.NewID(),
+		Type:        eventType,
+		WorkspaceID: workspaceID,
+		CreatedAt:   now,
+		Data:        data,
+	}
+
+	var payload []byte
+	for _, subscription := range subscriptions {
+		if subscription.Accepts(eventType) == false {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = jsoniter.Marshal(event); err != nil {
+				return err
+			}
+		}
+
+		err = d.store.SaveDelivery(&Delivery{
+			ID:             common.NewID(),
+			SubscriptionID: subscription.ID,
+			WorkspaceID:    workspaceID,
+			EventID:        event.ID,
+			EventType:      eventType,
+			Status:         DeliveryPending,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			NextAttemptAt:  now,
+			Payload:        payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if payload != nil {
+		d.notify()
+	}
+
+	return nil
+}
+
+// Retry moves a dead delivery back to the queue with a fresh set of attempts.
+func (d *Dispatcher) Retry(delivery *Delivery) error {
+	if delivery.Status != DeliveryDead {
+		return ErrNotDead
+	}
+
+	delivery.Status = DeliveryPending
+	delivery.Attempts = 0
+	delivery.UpdatedAt = time.Now().UTC()
+	delivery.NextAttemptAt = delivery.UpdatedAt
+	if err := d.store.SaveDelivery(delivery); err != nil {
+		return err
+	}
+
+	d.notify()
+	return nil
+}
+
+// Run sends the due deliveries until the context is cancelled. It also queues the deliveries which
+// got stuck in progress again and prunes the finished deliveries past their retention.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(cleanupInterval)
+	defer cleanup.Stop()
+
+	d.cleanUp(time.Now().UTC())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-cleanup.C:
+			d.cleanUp(now.UTC())
+		case <-ticker.C:
+		case <-d.wake:
+		}
+
+		now := time.Now().UTC()
+		deliveries, err := d.store.DueDeliveries(now)
+		if err != nil {
+			d.logger.Error("Failed to load due webhook deliveries: ", err)
+			continue
+		}
+
+		for _, delivery := range deliveries {
+			delivery.Status = DeliveryInProgress
+			delivery.UpdatedAt = now
+			if err = d.store.SaveDelivery(delivery); err != nil {
+				d.logger.Error("Failed to update webhook delivery: ", err)
+				continue
+			}
+
+			d.workers <- struct{}{}
+			go func(delivery *Delivery) {
+				defer func() { <-d.workers }()
+				d.deliver(ctx, delivery)
+			}(delivery)
+		}
+	}
+}
+
+// cleanUp queues the stale deliveries again and deletes the finished deliveries past their retention.
+// A stale delivery may have reached its receiver already, receivers can tell the repeated delivery by
+// its DeliveryHeader.
+func (d *Dispatcher) cleanUp(now time.Time) {
+	stale, err := d.store.StaleDeliveries(now.Add(-staleAfter))
+	if err != nil {
+		d.logger.Error("Failed to load stale webhook deliveries: ", err)
+	}
+	for _, delivery := range stale {
+		delivery.Status = DeliveryPending
+		delivery.LastError = "delivery was interrupted"
+		delivery.UpdatedAt = now
+		delivery.NextAttemptAt = now
+		if err = d.store.SaveDelivery(delivery); err != nil {
+			d.logger.Error("Failed to update webhook delivery: ", err)
+		}
+	}
+	if len(stale) > 0 {
+		d.logger.Warn("Queued ", len(stale), " stale webhook deliveries again")
+		d.notify()
+	}
+
+	deleted, err := d.store.DeleteFinishedDeliveries(now.Add(-d.retention))
+	if err != nil {
+		d.logger.Error("Failed to delete finished webhook deliveries: ", err)
+	} else if deleted > 0 {
+		d.logger.Debug("Deleted ", deleted, " finished webhook deliveries")
+	}
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *Delivery) {
+	delivery.Attempts++
+
+	subscription, err := d.store.Subscription(delivery.SubscriptionID)
+	if err == nil {
+		delivery.ResponseStatus, err = d.send(ctx, subscription, delivery)
+	}
+
+	now := time.Now().UTC()
+	delivery.UpdatedAt = now
+	switch {
+	case err == nil:
+		delivery.Status = DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case errors.Is(err, ErrNotFound) || errors.Is(err, ErrPrivateNetwork) || delivery.Attempts >= d.maxAttempts:
+		delivery.Status = DeliveryDead
+		delivery.LastError = err.Error()
+	default:
+		delivery.Status = DeliveryPending
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+
+	if err = d.store.SaveDelivery(delivery); err != nil {
+		d.logger.Error("Failed to update webhook delivery: ", err)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, subscription *Subscription, delivery *Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set(lhttp.ContentTypeHeader, lhttp.ContentAppJSON)
+	req.Header.Set(EventHeader, string(delivery.EventType))
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt, doubling with every failed attempt.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.initialBackoff
+	for i := 1; i < attempts && backoff < d.maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > d.maxBackoff {
+		return d.maxBackoff
+	}
+
+	return backoff
+}
+
+// newPublicClient returns a client which refuses to connect to addresses which are not public. The
+// address is checked after the host name is resolved, so a receiver cannot reach the internal network
+// by pointing its name to an internal address after the subscription was created.
+func newPublicClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: defaultTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || destinations.IsPublicIP(ip) == false {
+				return ErrPrivateNetwork
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: defaultTimeout,
+		// no proxy, the dialer would check the address of the proxy instead of the receiver
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: defaultTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,290 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"lynkly-backend/internal/logging"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// receiver is a webhook endpoint which verifies the signatures of the deliveries. It responds with
+// 500 Internal Server Error to as many signed requests as set in failures before accepting them.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	received []string
+	errors   []error
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	payload, _ := io.ReadAll(r.Body)
+	if err := Verify(testSecret, r.Header.Get(SignatureHeader), payload, time.Minute, time.Now()); err != nil {
+		rc.errors = append(rc.errors, err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	rc.requests++
+	if rc.requests <= rc.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rc.received = append(rc.received, r.Header.Get(DeliveryHeader))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newTestDispatcher(t *testing.T, receiverURL string, maxAttempts int) *Dispatcher {
+	t.Helper()
+
+	dispatcher := New(Params{
+		Logger:               logging.NewLogger("webhooks_test"),
+		Store:                NewMemoryStore(),
+		MaxAttempts:          maxAttempts,
+		InitialBackoff:       10 * time.Millisecond,
+		MaxBackoff:           20 * time.Millisecond,
+		PollInterval:         5 * time.Millisecond,
+		AllowPrivateNetworks: true,
+	})
+
+	err := dispatcher.Store().CreateSubscription(&Subscription{
+		ID:          "subscription",
+		WorkspaceID: "workspace",
+		URL:         receiverURL,
+		Secret:      testSecret,
+		CreatedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go dispatcher.Run(ctx)
+
+	return dispatcher
+}
+
+func waitForDelivery(t *testing.T, store Store, status DeliveryStatus) *Delivery {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries, err := store.Deliveries("subscription")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) == 1 && deliveries[0].Status == status {
+			return deliveries[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("Delivery did not reach the %s status", status)
+	return nil
+}
+
+func TestDispatcherRetriesUntilDelivered(t *testing.T) {
+	rc := &receiver{failures: 2}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	dispatcher := newTestDispatcher(t, server.URL, 5)
+	if err := dispatcher.Dispatch("workspace", LinkCreated, map[string]string{"code": "abc"}); err != nil {
+		t.Fatal(err)
+	}
+
+	delivery := waitForDelivery(t, dispatcher.Store(), DeliverySucceeded)
+	if delivery.Attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", delivery.Attempts)
+	}
+	if delivery.DeliveredAt == nil {
+		t.Error("Expected the delivery time to be set")
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.errors) > 0 {
+		t.Errorf("Receiver rejected signatures: %v", rc.errors)
+	}
+	if len(rc.received) != 1 || rc.received[0] != delivery.ID {
+		t.Errorf("Expected delivery %s to be received once, got %v", delivery.ID, rc.received)
+	}
+}
+
+func TestDispatcherMovesExhaustedDeliveriesToDeadLetters(t *testing.T) {
+	rc := &receiver{failures: 3}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	dispatcher := newTestDispatcher(t, server.URL, 2)
+	if err := dispatcher.Dispatch("workspace", LinkClicked, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	delivery := waitForDelivery(t, dispatcher.Store(), DeliveryDead)
+	if delivery.Attempts != 2 || delivery.ResponseStatus != http.StatusInternalServerError {
+		t.Errorf("Expected 2 attempts ending with status 500, got %d attempts and status %d", delivery.Attempts, delivery.ResponseStatus)
+	}
+
+	dead, err := dispatcher.Store().DeadDeliveries("workspace")
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("Expected one dead delivery, got %v, %v", dead, err)
+	}
+
+	// the third request fails as well, the fourth one succeeds
+	if err = dispatcher.Retry(delivery); err != nil {
+		t.Fatal(err)
+	}
+	waitForDelivery(t, dispatcher.Store(), DeliverySucceeded)
+
+	if err = dispatcher.Retry(delivery); errors.Is(err, ErrNotDead) == false {
+		t.Errorf("Expected ErrNotDead, got %v", err)
+	}
+}
+
+func TestDispatcherSkipsUnsubscribedEvents(t *testing.T) {
+	dispatcher := New(Params{Store: NewMemoryStore()})
+	err := dispatcher.Store().CreateSubscription(&Subscription{
+		ID:          "subscription",
+		WorkspaceID: "workspace",
+		URL:         "https://example.com/hook",
+		Events:      []EventType{LinkCreated},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = dispatcher.Dispatch("workspace", LinkClicked, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, _ := dispatcher.Store().Deliveries("subscription")
+	if len(deliveries) != 0 {
+		t.Errorf("Expected no deliveries, got %d", len(deliveries))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	payload := []byte(`{"id":"event"}`)
+	now := time.Now()
+	header := Sign(testSecret, now, payload)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		payload []byte
+		now     time.Time
+		valid   bool
+	}{
+		{"valid", testSecret, header, payload, now, true},
+		{"wrong secret", "other-secret", header, payload, now, false},
+		{"tampered payload", testSecret, header, []byte(`{"id":"other"}`), now, false},
+		{"expired", testSecret, header, payload, now.Add(10 * time.Minute), false},
+		{"malformed header", testSecret, "v1=abc", payload, now, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Verify(test.secret, test.header, test.payload, 5*time.Minute, test.now)
+			if test.valid && err != nil {
+				t.Errorf("Expected a valid signature, got %v", err)
+			} else if test.valid == false && errors.Is(err, ErrInvalidSignature) == false {
+				t.Errorf("Expected ErrInvalidSignature, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url                  string
+		allowPrivateNetworks bool
+		expected             error
+	}{
+		{"https://example.com/hook", false, nil},
+		{"ftp://example.com/hook", false, ErrInvalidURL},
+		{"https:///hook", false, ErrInvalidURL},
+		{"http://localhost:8080/hook", false, ErrPrivateNetwork},
+		{"http://127.0.0.1/hook", false, ErrPrivateNetwork},
+		{"http://2130706433/hook", false, ErrPrivateNetwork},
+		{"http://[::1]/hook", false, ErrPrivateNetwork},
+		{"http://10.1.2.3/hook", false, ErrPrivateNetwork},
+		{"http://169.254.169.254/latest/meta-data", false, ErrPrivateNetwork},
+		{"http://127.0.0.1/hook", true, nil},
+	}
+
+	for _, test := range tests {
+		dispatcher := New(Params{AllowPrivateNetworks: test.allowPrivateNetworks})
+		if err := dispatcher.CheckURL(test.url); err != test.expected {
+			t.Errorf("CheckURL(%q) = %v, expected %v", test.url, err, test.expected)
+		}
+	}
+}
+
+func TestPublicClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("The request should not have reached the receiver")
+	}))
+	defer server.Close()
+
+	// the check happens at dial time, after the name is resolved
+	_, err := newPublicClient().Get(server.URL)
+	if errors.Is(err, ErrPrivateNetwork) == false {
+		t.Errorf("Expected ErrPrivateNetwork, got %v", err)
+	}
+}
+
+func TestCleanUp(t *testing.T) {
+	dispatcher := New(Params{
+		Logger:    logging.NewLogger("webhooks_test"),
+		Store:     NewMemoryStore(),
+		Retention: time.Hour,
+	})
+	now := time.Now().UTC()
+
+	deliveries := []*Delivery{
+		{ID: "stale", Status: DeliveryInProgress, UpdatedAt: now.Add(-time.Hour)},
+		{ID: "in-progress", Status: DeliveryInProgress, UpdatedAt: now},
+		{ID: "old-succeeded", Status: DeliverySucceeded, UpdatedAt: now.Add(-2 * time.Hour)},
+		{ID: "old-dead", Status: DeliveryDead, UpdatedAt: now.Add(-2 * time.Hour)},
+		{ID: "recent-dead", Status: DeliveryDead, UpdatedAt: now},
+		{ID: "old-pending", Status: DeliveryPending, UpdatedAt: now.Add(-2 * time.Hour)},
+	}
+	for _, delivery := range deliveries {
+		if err := dispatcher.Store().SaveDelivery(delivery); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dispatcher.cleanUp(now)
+
+	expected := map[string]DeliveryStatus{
+		"stale":         DeliveryPending,
+		"in-progress":   DeliveryInProgress,
+		"old-succeeded": "",
+		"old-dead":      "",
+		"recent-dead":   DeliveryDead,
+		"old-pending":   DeliveryPending,
+	}
+	for id, status := range expected {
+		delivery, err := dispatcher.Store().Delivery(id)
+		if status == "" && err != ErrNotFound {
+			t.Errorf("Expected delivery %s to be deleted, got %v", id, err)
+		} else if status != "" && (err != nil || delivery.Status != status) {
+			t.Errorf("Expected delivery %s to be %s, got %v, %v", id, status, delivery, err)
+		}
+	}
+}
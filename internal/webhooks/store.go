@@ -0,0 +1,172 @@
+package webhooks
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	CreateSubscription(subscription *Subscription) error
+	// Subscription returns the subscription with the ID or ErrNotFound.
+	Subscription(id string) (*Subscription, error)
+	Subscriptions(workspaceID string) ([]*Subscription, error)
+	DeleteSubscription(id string) error
+	SaveDelivery(delivery *Delivery) error
+	// Delivery returns the delivery with the ID or ErrNotFound.
+	Delivery(id string) (*Delivery, error)
+	// Deliveries returns the deliveries of the subscription, newest first.
+	Deliveries(subscriptionID string) ([]*Delivery, error)
+	// DueDeliveries returns the pending deliveries whose next attempt is due by now.
+	DueDeliveries(now time.Time) ([]*Delivery, error)
+	// DeadDeliveries returns the dead-letter list of the workspace, newest first.
+	DeadDeliveries(workspaceID string) ([]*Delivery, error)
+	// StaleDeliveries returns the deliveries still in progress which were last updated before the time.
+	StaleDeliveries(updatedBefore time.Time) ([]*Delivery, error)
+	// DeleteFinishedDeliveries deletes the succeeded and dead deliveries which were last updated before
+	// the time and returns their number.
+	DeleteFinishedDeliveries(updatedBefore time.Time) (int, error)
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu            sync.RWMutex
+	subscriptions map[string]Subscription
+	deliveries    map[string]Delivery
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		subscriptions: make(map[string]Subscription),
+		deliveries:    make(map[string]Delivery),
+	}
+}
+
+func (s *memoryStore) CreateSubscription(subscription *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscriptions[subscription.ID] = *subscription
+	return nil
+}
+
+func (s *memoryStore) Subscription(id string) (*Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subscription, ok := s.subscriptions[id]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	return &subscription, nil
+}
+
+func (s *memoryStore) Subscriptions(workspaceID string) ([]*Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Subscription
+	for _, subscription := range s.subscriptions {
+		if subscription.WorkspaceID == workspaceID {
+			subscription := subscription
+			result = append(result, &subscription)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+func (s *memoryStore) DeleteSubscription(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscriptions[id]; ok == false {
+		return ErrNotFound
+	}
+
+	delete(s.subscriptions, id)
+	return nil
+}
+
+func (s *memoryStore) SaveDelivery(delivery *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (s *memoryStore) Delivery(id string) (*Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	delivery, ok := s.deliveries[id]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	return &delivery, nil
+}
+
+func (s *memoryStore) Deliveries(subscriptionID string) ([]*Delivery, error) {
+	return s.filterDeliveries(func(delivery *Delivery) bool {
+		return delivery.SubscriptionID == subscriptionID
+	}), nil
+}
+
+func (s *memoryStore) DueDeliveries(now time.Time) ([]*Delivery, error) {
+	return s.filterDeliveries(func(delivery *Delivery) bool {
+		return delivery.Status == DeliveryPending && delivery.NextAttemptAt.After(now) == false
+	}), nil
+}
+
+func (s *memoryStore) DeadDeliveries(workspaceID string) ([]*Delivery, error) {
+	return s.filterDeliveries(func(delivery *Delivery) bool {
+		return delivery.WorkspaceID == workspaceID && delivery.Status == DeliveryDead
+	}), nil
+}
+
+func (s *memoryStore) StaleDeliveries(updatedBefore time.Time) ([]*Delivery, error) {
+	return s.filterDeliveries(func(delivery *Delivery) bool {
+		return delivery.Status == DeliveryInProgress && delivery.UpdatedAt.Before(updatedBefore)
+	}), nil
+}
+
+func (s *memoryStore) DeleteFinishedDeliveries(updatedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for id, delivery := range s.deliveries {
+		if delivery.IsFinished() && delivery.UpdatedAt.Before(updatedBefore) {
+			delete(s.deliveries, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (s *memoryStore) filterDeliveries(match func(delivery *Delivery) bool) []*Delivery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Delivery
+	for _, delivery := range s.deliveries {
+		delivery := delivery
+		if match(&delivery) {
+			result = append(result, &delivery)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return result
+}
@@ -0,0 +1,157 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type EventType string
+
+const (
+	LinkCreated EventType = "link.created"
+	LinkUpdated EventType = "link.updated"
+	LinkDeleted EventType = "link.deleted"
+	LinkExpired EventType = "link.expired"
+	LinkClicked EventType = "link.clicked"
+	// LinkDisabled is sent when a link stops redirecting, e.g. because its destination is malicious
+	LinkDisabled EventType = "link.disabled"
+	// LinkQuarantined is sent when the visitors of a link have to confirm a warning before the redirect
+	LinkQuarantined EventType = "link.quarantined"
+	// LinkRestored is sent when a disabled or quarantined link redirects again
+	LinkRestored EventType = "link.restored"
+)
+
+var eventTypes = map[EventType]bool{
+	LinkCreated:     true,
+	LinkUpdated:     true,
+	LinkDeleted:     true,
+	LinkExpired:     true,
+	LinkClicked:     true,
+	LinkDisabled:    true,
+	LinkQuarantined: true,
+	LinkRestored:    true,
+}
+
+func (t EventType) IsValid() bool {
+	return eventTypes[t]
+}
+
+// headers sent with every delivery
+const (
+	EventHeader     = "X-Lynkly-Event"
+	DeliveryHeader  = "X-Lynkly-Delivery"
+	SignatureHeader = "X-Lynkly-Signature"
+)
+
+var (
+	ErrNotFound         = errors.New("webhook not found")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidURL       = errors.New("invalid webhook URL - expected an http or https URL")
+	ErrPrivateNetwork   = errors.New("webhook receivers on loopback, private or reserved addresses are not allowed")
+)
+
+// Subscription is a webhook endpoint of a workspace. It receives the events listed in Events,
+// or all events when Events is empty.
+type Subscription struct {
+	ID          string      `json:"id"`
+	WorkspaceID string      `json:"workspaceId"`
+	URL         string      `json:"url"`
+	Events      []EventType `json:"events"`
+	Secret      string      `json:"secret,omitempty"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
+
+func (s *Subscription) Accepts(eventType EventType) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+
+	for _, event := range s.Events {
+		if event == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Event is the JSON payload posted to the subscriptions.
+type Event struct {
+	ID          string      `json:"id"`
+	Type        EventType   `json:"type"`
+	WorkspaceID string      `json:"workspaceId"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	Data        interface{} `json:"data"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending    DeliveryStatus = "pending"
+	DeliveryInProgress DeliveryStatus = "in_progress"
+	DeliverySucceeded  DeliveryStatus = "succeeded"
+	// DeliveryDead marks deliveries which exhausted their attempts. They form the dead-letter list
+	// and are only attempted again when retried explicitly.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// Delivery is a single event sent, or to be sent, to a subscription.
+type Delivery struct {
+	ID             string         `json:"id"`
+	SubscriptionID string         `json:"subscriptionId"`
+	WorkspaceID    string         `json:"workspaceId"`
+	EventID        string         `json:"eventId"`
+	EventType      EventType      `json:"eventType"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	ResponseStatus int            `json:"responseStatus,omitempty"`
+	LastError      string         `json:"lastError,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	NextAttemptAt  time.Time      `json:"nextAttemptAt"`
+	DeliveredAt    *time.Time     `json:"deliveredAt,omitempty"`
+	Payload        []byte         `json:"-"`
+}
+
+// IsFinished reports whether the delivery is not going to be attempted again unless it is retried.
+func (d *Delivery) IsFinished() bool {
+	return d.Status == DeliverySucceeded || d.Status == DeliveryDead
+}
+
+// Sign returns the value of the SignatureHeader for the payload sent at the timestamp. The signature
+// is the hex encoded HMAC-SHA256 of "<unix timestamp>.<payload>" keyed with the subscription secret.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), computeSignature(secret, timestamp.Unix(), payload))
+}
+
+// Verify checks a SignatureHeader value against the payload. It is meant for receivers of webhooks
+// and rejects signatures older than tolerance to prevent replays.
+func Verify(secret, header string, payload []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp int64
+	var signature string
+	if _, err := fmt.Sscanf(header, "t=%d,v1=%s", &timestamp, &signature); err != nil {
+		return ErrInvalidSignature
+	}
+
+	if now.Sub(time.Unix(timestamp, 0)) > tolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := computeSignature(secret, timestamp, payload)
+	if hmac.Equal([]byte(signature), []byte(expected)) == false {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func computeSignature(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,259 @@
+package workspaces
+
+import (
+	"sort"
+	"sync"
+)
+
+type Store interface {
+	CreateWorkspace(workspace *Workspace) error
+	// Workspace returns the workspace with the ID or ErrNotFound.
+	Workspace(id string) (*Workspace, error)
+	// UpdateWorkspace replaces the workspace and returns ErrNotFound when it does not exist.
+	UpdateWorkspace(workspace *Workspace) error
+	// DeleteWorkspace removes the workspace with its members and invitations and returns ErrNotFound
+	// when it does not exist.
+	DeleteWorkspace(id string) error
+
+	// SaveMember creates or replaces the membership of the user in the workspace.
+	SaveMember(member *Member) error
+	// Member returns the membership of the user in the workspace or ErrMemberNotFound.
+	Member(workspaceID, userID string) (*Member, error)
+	// Members returns the members of the workspace, oldest first.
+	Members(workspaceID string) ([]*Member, error)
+	// Memberships returns the memberships of the user, oldest first.
+	Memberships(userID string) ([]*Member, error)
+	// DeleteMember removes the membership and returns ErrMemberNotFound when it does not exist.
+	DeleteMember(workspaceID, userID string) error
+
+	CreateInvitation(invitation *Invitation) error
+	// Invitation returns the invitation with the ID or ErrInvitationNotFound.
+	Invitation(id string) (*Invitation, error)
+	// InvitationByTokenHash returns the invitation with the token hash or ErrInvitationNotFound.
+	InvitationByTokenHash(tokenHash string) (*Invitation, error)
+	// Invitations returns the invitations of the workspace, oldest first.
+	Invitations(workspaceID string) ([]*Invitation, error)
+	UpdateInvitation(invitation *Invitation) error
+	// DeleteInvitation removes the invitation and returns ErrInvitationNotFound when it does not exist.
+	DeleteInvitation(id string) error
+}
+
+type memberKey struct {
+	workspaceID string
+	userID      string
+}
+
+// TODO: [Zdravko Donev] Replace with a persistent store after we get the DB
+type memoryStore struct {
+	mu          sync.RWMutex
+	workspaces  map[string]Workspace
+	members     map[memberKey]Member
+	invitations map[string]Invitation
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		workspaces:  make(map[string]Workspace),
+		members:     make(map[memberKey]Member),
+		invitations: make(map[string]Invitation),
+	}
+}
+
+func (s *memoryStore) CreateWorkspace(workspace *Workspace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.workspaces[workspace.ID] = *workspace
+	return nil
+}
+
+func (s *memoryStore) Workspace(id string) (*Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	workspace, ok := s.workspaces[id]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	return &workspace, nil
+}
+
+func (s *memoryStore) UpdateWorkspace(workspace *Workspace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.workspaces[workspace.ID]; ok == false {
+		return ErrNotFound
+	}
+
+	s.workspaces[workspace.ID] = *workspace
+	return nil
+}
+
+func (s *memoryStore) DeleteWorkspace(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.workspaces[id]; ok == false {
+		return ErrNotFound
+	}
+
+	delete(s.workspaces, id)
+	for key := range s.members {
+		if key.workspaceID == id {
+			delete(s.members, key)
+		}
+	}
+	for invitationID, invitation := range s.invitations {
+		if invitation.WorkspaceID == id {
+			delete(s.invitations, invitationID)
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStore) SaveMember(member *Member) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.workspaces[member.WorkspaceID]; ok == false {
+		return ErrNotFound
+	}
+
+	s.members[memberKey{member.WorkspaceID, member.UserID}] = *member
+	return nil
+}
+
+func (s *memoryStore) Member(workspaceID, userID string) (*Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	member, ok := s.members[memberKey{workspaceID, userID}]
+	if ok == false {
+		return nil, ErrMemberNotFound
+	}
+
+	return &member, nil
+}
+
+func (s *memoryStore) Members(workspaceID string) ([]*Member, error) {
+	return s.filterMembers(func(member Member) bool {
+		return member.WorkspaceID == workspaceID
+	}), nil
+}
+
+func (s *memoryStore) Memberships(userID string) ([]*Member, error) {
+	return s.filterMembers(func(member Member) bool {
+		return member.UserID == userID
+	}), nil
+}
+
+func (s *memoryStore) filterMembers(keep func(member Member) bool) []*Member {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Member
+	for _, member := range s.members {
+		if keep(member) {
+			member := member
+			result = append(result, &member)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result
+}
+
+func (s *memoryStore) DeleteMember(workspaceID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memberKey{workspaceID, userID}
+	if _, ok := s.members[key]; ok == false {
+		return ErrMemberNotFound
+	}
+
+	delete(s.members, key)
+	return nil
+}
+
+func (s *memoryStore) CreateInvitation(invitation *Invitation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.invitations[invitation.ID] = *invitation
+	return nil
+}
+
+func (s *memoryStore) Invitation(id string) (*Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	invitation, ok := s.invitations[id]
+	if ok == false {
+		return nil, ErrInvitationNotFound
+	}
+
+	return &invitation, nil
+}
+
+func (s *memoryStore) InvitationByTokenHash(tokenHash string) (*Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, invitation := range s.invitations {
+		if invitation.TokenHash == tokenHash {
+			return &invitation, nil
+		}
+	}
+
+	return nil, ErrInvitationNotFound
+}
+
+func (s *memoryStore) Invitations(workspaceID string) ([]*Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Invitation
+	for _, invitation := range s.invitations {
+		if invitation.WorkspaceID == workspaceID {
+			invitation := invitation
+			result = append(result, &invitation)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+func (s *memoryStore) UpdateInvitation(invitation *Invitation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invitations[invitation.ID]; ok == false {
+		return ErrInvitationNotFound
+	}
+
+	s.invitations[invitation.ID] = *invitation
+	return nil
+}
+
+func (s *memoryStore) DeleteInvitation(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invitations[id]; ok == false {
+		return ErrInvitationNotFound
+	}
+
+	delete(s.invitations, id)
+	return nil
+}
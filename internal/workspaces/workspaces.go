@@ -0,0 +1,333 @@
+package workspaces
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"lynkly-backend/internal/auth"
+	"lynkly-backend/internal/common"
+	"lynkly-backend/internal/origins"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInvitationTTL = 7 * 24 * time.Hour
+	maxNameLength        = 100
+	maxAllowedOrigins    = 20
+)
+
+var (
+	ErrNotFound           = errors.New("workspace not found")
+	ErrMemberNotFound     = errors.New("member not found")
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrInvalidName        = errors.New("invalid name - expected up to 100 characters")
+	ErrInvalidRole        = errors.New("invalid role - expected owner, admin, editor or viewer")
+	ErrLastOwner          = errors.New("the last owner cannot leave the workspace or lose the owner role")
+	ErrAlreadyMember      = errors.New("user is already a member of the workspace")
+	ErrInvitationExpired  = errors.New("invitation has expired")
+	ErrInvitationEmail    = errors.New("invitation was sent to another email")
+	ErrTooManyOrigins     = errors.New("too many allowed origins - expected up to 20")
+)
+
+// Workspace is a tenant of the service. Links, API keys, webhooks and privacy settings belong to a
+// workspace and are shared by its members according to their roles.
+type Workspace struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	// RequireTwoFactor restricts the owners and admins who logged in without two-factor authentication
+	// to no role in the workspace
+	RequireTwoFactor bool `json:"requireTwoFactor"`
+	// AllowedOrigins are the origins of the sites of the workspace which may call the routes of the
+	// workspace in the management API from a browser. They may start with a wildcard, e.g.
+	// "https://*.example.com".
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+}
+
+type Member struct {
+	WorkspaceID string    `json:"workspaceId"`
+	UserID      string    `json:"userId"`
+	Role        auth.Role `json:"role"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Invitation lets the user with the email join the workspace. Only the SHA-256 hash of the invitation
+// token is stored, the token itself is shown once on creation.
+type Invitation struct {
+	ID          string     `json:"id"`
+	WorkspaceID string     `json:"workspaceId"`
+	Email       string     `json:"email"`
+	Role        auth.Role  `json:"role"`
+	InvitedBy   string     `json:"invitedBy"`
+	TokenHash   string     `json:"-"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	AcceptedAt  *time.Time `json:"acceptedAt,omitempty"`
+}
+
+type Params struct {
+	Store         Store
+	InvitationTTL time.Duration
+}
+
+// Workspaces manages the workspaces, their members and invitations.
+type Workspaces struct {
+	store         Store
+	invitationTTL time.Duration
+}
+
+func New(params Params) *Workspaces {
+	if params.InvitationTTL <= 0 {
+		params.InvitationTTL = defaultInvitationTTL
+	}
+
+	return &Workspaces{
+		store:         params.Store,
+		invitationTTL: params.InvitationTTL,
+	}
+}
+
+func (w *Workspaces) Store() Store {
+	return w.store
+}
+
+// Create creates a workspace owned by the user.
+func (w *Workspaces) Create(name, ownerID string) (*Workspace, error) {
+	return w.CreateWithID(common.NewID(), name, ownerID)
+}
+
+// CreateWithID creates a workspace with a well-known ID. The workspace has no members when ownerID
+// is empty.
+func (w *Workspaces) CreateWithID(id, name, ownerID string) (*Workspace, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return nil, ErrInvalidName
+	}
+
+	workspace := &Workspace{
+		ID:        id,
+		Name:      name,
+		CreatedBy: ownerID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := w.store.CreateWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	if ownerID != "" {
+		if _, err := w.SetRole(workspace.ID, ownerID, auth.RoleOwner); err != nil {
+			return nil, err
+		}
+	}
+
+	return workspace, nil
+}
+
+// SetAllowedOrigins replaces the allowed origins of the workspace after validating them.
+func (w *Workspaces) SetAllowedOrigins(workspaceID string, patterns []string) (*Workspace, error) {
+	if len(patterns) > maxAllowedOrigins {
+		return nil, ErrTooManyOrigins
+	}
+
+	normalized := make([]string, 0, len(patterns))
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if err := origins.Validate(pattern); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, pattern)
+		}
+		if seen[pattern] == false {
+			seen[pattern] = true
+			normalized = append(normalized, pattern)
+		}
+	}
+
+	workspace, err := w.store.Workspace(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace.AllowedOrigins = normalized
+	if err = w.store.UpdateWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+// AllowsOrigin reports whether the workspace allows the origin. Unknown workspaces allow no origin.
+func (w *Workspaces) AllowsOrigin(workspaceID, origin string) (bool, error) {
+	workspace, err := w.store.Workspace(workspaceID)
+	if err == ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return origins.MatchAny(workspace.AllowedOrigins, origin), nil
+}
+
+// DefaultWorkspace returns the workspace the user joined first, which is used for the requests not
+// addressing a workspace.
+func (w *Workspaces) DefaultWorkspace(userID string) (*Member, error) {
+	memberships, err := w.store.Memberships(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(memberships) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return memberships[0], nil
+}
+
+// SetRole adds the user to the workspace or changes the role of an existing member. The last owner
+// cannot lose the owner role.
+func (w *Workspaces) SetRole(workspaceID, userID string, role auth.Role) (*Member, error) {
+	if role.IsValid() == false {
+		return nil, ErrInvalidRole
+	}
+
+	now := time.Now().UTC()
+	member, err := w.store.Member(workspaceID, userID)
+	if err == ErrMemberNotFound {
+		member = &Member{
+			WorkspaceID: workspaceID,
+			UserID:      userID,
+			CreatedAt:   now,
+		}
+	} else if err != nil {
+		return nil, err
+	} else if member.Role == auth.RoleOwner && role != auth.RoleOwner {
+		if err = w.checkOtherOwner(workspaceID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	member.Role = role
+	member.UpdatedAt = now
+	if err = w.store.SaveMember(member); err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// RemoveMember removes the user from the workspace. The last owner cannot leave.
+func (w *Workspaces) RemoveMember(workspaceID, userID string) error {
+	member, err := w.store.Member(workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err = w.checkLeave(member); err != nil {
+		return err
+	}
+
+	return w.store.DeleteMember(workspaceID, userID)
+}
+
+// CheckRemoveMember returns ErrLastOwner when the user is the last owner of the workspace and so
+// cannot be removed, without removing it.
+func (w *Workspaces) CheckRemoveMember(workspaceID, userID string) error {
+	member, err := w.store.Member(workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	return w.checkLeave(member)
+}
+
+func (w *Workspaces) checkLeave(member *Member) error {
+	if member.Role != auth.RoleOwner {
+		return nil
+	}
+
+	return w.checkOtherOwner(member.WorkspaceID, member.UserID)
+}
+
+func (w *Workspaces) checkOtherOwner(workspaceID, userID string) error {
+	members, err := w.store.Members(workspaceID)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if member.Role == auth.RoleOwner && member.UserID != userID {
+			return nil
+		}
+	}
+
+	return ErrLastOwner
+}
+
+// Invite creates an invitation for the email and returns it together with the token, which cannot be
+// recovered later.
+func (w *Workspaces) Invite(workspaceID, email string, role auth.Role, invitedBy string) (*Invitation, string, error) {
+	if role.IsValid() == false {
+		return nil, "", ErrInvalidRole
+	}
+
+	token := common.RandomHex(32)
+	now := time.Now().UTC()
+	invitation := &Invitation{
+		ID:          common.NewID(),
+		WorkspaceID: workspaceID,
+		Email:       email,
+		Role:        role,
+		InvitedBy:   invitedBy,
+		TokenHash:   hashToken(token),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(w.invitationTTL),
+	}
+	if err := w.store.CreateInvitation(invitation); err != nil {
+		return nil, "", err
+	}
+
+	return invitation, token, nil
+}
+
+// Accept adds the user to the workspace of the invitation. The invitation is bound to the email it
+// was sent to and can be accepted once.
+func (w *Workspaces) Accept(token, userID, email string) (*Member, error) {
+	invitation, err := w.store.InvitationByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if invitation.AcceptedAt != nil {
+		return nil, ErrInvitationNotFound
+	}
+
+	now := time.Now().UTC()
+	if now.After(invitation.ExpiresAt) {
+		return nil, ErrInvitationExpired
+	}
+
+	if invitation.Email != email {
+		return nil, ErrInvitationEmail
+	}
+
+	if _, err = w.store.Member(invitation.WorkspaceID, userID); err == nil {
+		return nil, ErrAlreadyMember
+	} else if err != ErrMemberNotFound {
+		return nil, err
+	}
+
+	invitation.AcceptedAt = &now
+	if err = w.store.UpdateInvitation(invitation); err != nil {
+		return nil, err
+	}
+
+	return w.SetRole(invitation.WorkspaceID, userID, invitation.Role)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}